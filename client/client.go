@@ -3,39 +3,93 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
 
 	"github.com/anevsky/cachego/util"
 	"github.com/parnurzeal/gorequest"
 )
 
+// Max redirects followed by a request
+const maxRedirects = 10
+
 type CLIENT struct {
 	Url    string
 	APIUrl string
 	agent  *gorequest.SuperAgent
+	// API token sent instead of credentials when set
+	token string
+	// Client of streams, the agent serves other requests
+	httpClient *http.Client
 }
 
 type Credentials struct {
 	Username, Password string
 }
 
+// Credentials of the user a server starts with by default
+var DefaultCredentials = Credentials{
+	"alex",
+	"secret",
+}
+
 func Create() CLIENT {
+	return CreateWithCredentials(DefaultCredentials)
+}
+
+// Create client authenticating as the given user
+func CreateWithCredentials(credentials Credentials) CLIENT {
 	request := gorequest.New()
-	request.BasicAuth = Credentials{
-		"alex",
-		"secret",
-	}
+	request.BasicAuth = credentials
+	// Cluster nodes redirect requests for keys they don't own,
+	// credentials are kept even if the node is on another host
+	request.RedirectPolicy(func(req gorequest.Request, via []gorequest.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		req.Header.Set("Authorization", via[0].Header.Get("Authorization"))
+		return nil
+	})
 
 	cli := CLIENT{
-		agent: request,
+		agent:      request,
+		httpClient: http.DefaultClient,
 	}
 
 	return cli
 }
 
+// Create client authenticating by API token, see CreateToken
+func CreateWithToken(token string) CLIENT {
+	cli := CreateWithCredentials(Credentials{})
+	cli.token = token
+
+	return cli
+}
+
+// Start request to the API path, authenticated by token or credentials
+func (cli *CLIENT) request(method, path string) *gorequest.SuperAgent {
+	agent := cli.agent.CustomMethod(method, cli.Url+cli.APIUrl+path)
+	if cli.token != "" {
+		agent.Set("Authorization", "Bearer "+cli.token)
+	}
+
+	return agent
+}
+
+// Authenticate request made without the agent
+func (cli *CLIENT) authorize(req *http.Request) {
+	if cli.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cli.token)
+		return
+	}
+
+	req.SetBasicAuth(cli.agent.BasicAuth.Username, cli.agent.BasicAuth.Password)
+}
+
 func (cli *CLIENT) Len() (result int, errs []error) {
 	var dto util.LenDTO
-	resp, body, errs := cli.agent.
-		Get(cli.Url + cli.APIUrl + "/len").
+	resp, body, errs := cli.request(gorequest.GET, "/len").
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -56,8 +110,7 @@ func (cli *CLIENT) Len() (result int, errs []error) {
 
 func (cli *CLIENT) Keys() (result []string, errs []error) {
 	var dto util.KeysDTO
-	resp, body, errs := cli.agent.
-		Get(cli.Url + cli.APIUrl + "/keys").
+	resp, body, errs := cli.request(gorequest.GET, "/keys").
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -78,8 +131,7 @@ func (cli *CLIENT) Keys() (result []string, errs []error) {
 
 func (cli *CLIENT) Stats() (result util.Stats, errs []error) {
 	var dto util.StatsDTO
-	resp, body, errs := cli.agent.
-		Get(cli.Url + cli.APIUrl + "/stats").
+	resp, body, errs := cli.request(gorequest.GET, "/stats").
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -98,10 +150,53 @@ func (cli *CLIENT) Stats() (result util.Stats, errs []error) {
 	return dto.Stats, errs
 }
 
+// Save snapshot of the server cache to disk
+func (cli *CLIENT) Save() (errs []error) {
+	var dto util.BasicDTO
+	resp, body, errs := cli.request(gorequest.POST, "/save").
+		EndStruct(&dto)
+
+	if errs != nil {
+		return errs
+	}
+
+	if resp == nil || body == nil {
+		return []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return []error{err}
+	}
+
+	return errs
+}
+
+// Compact append-only log of the server
+func (cli *CLIENT) RewriteAppendLog() (errs []error) {
+	var dto util.BasicDTO
+	resp, body, errs := cli.request(gorequest.POST, "/rewrite").
+		EndStruct(&dto)
+
+	if errs != nil {
+		return errs
+	}
+
+	if resp == nil || body == nil {
+		return []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return []error{err}
+	}
+
+	return errs
+}
+
 func (cli *CLIENT) GetString(key string) (result string, errs []error) {
 	var dto util.StringDTO
-	resp, body, errs := cli.agent.
-		Get(cli.Url + cli.APIUrl + "/get/" + key).
+	resp, body, errs := cli.request(gorequest.GET, "/get/"+key).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -122,8 +217,7 @@ func (cli *CLIENT) GetString(key string) (result string, errs []error) {
 
 func (cli *CLIENT) GetInt(key string) (result int, errs []error) {
 	var dto util.IntDTO
-	resp, body, errs := cli.agent.
-		Get(cli.Url + cli.APIUrl + "/get/" + key).
+	resp, body, errs := cli.request(gorequest.GET, "/get/"+key).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -144,8 +238,7 @@ func (cli *CLIENT) GetInt(key string) (result int, errs []error) {
 
 func (cli *CLIENT) GetListElement(key string, v int) (result string, errs []error) {
 	var dto util.StringDTO
-	resp, body, errs := cli.agent.
-		Post(cli.Url + cli.APIUrl + "/list/element/" + key).
+	resp, body, errs := cli.request(gorequest.POST, "/list/element/"+key).
 		Send(util.IntDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -167,8 +260,7 @@ func (cli *CLIENT) GetListElement(key string, v int) (result string, errs []erro
 
 func (cli *CLIENT) GetDictElement(key, v string) (result string, errs []error) {
 	var dto util.StringDTO
-	resp, body, errs := cli.agent.
-		Post(cli.Url + cli.APIUrl + "/dict/element/" + key).
+	resp, body, errs := cli.request(gorequest.POST, "/dict/element/"+key).
 		Send(util.StringDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -190,8 +282,7 @@ func (cli *CLIENT) GetDictElement(key, v string) (result string, errs []error) {
 
 func (cli *CLIENT) HasKey(key string) (result bool, errs []error) {
 	var dto util.BoolDTO
-	resp, body, errs := cli.agent.
-		Get(cli.Url + cli.APIUrl + "/key/" + key).
+	resp, body, errs := cli.request(gorequest.GET, "/key/"+key).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -210,11 +301,10 @@ func (cli *CLIENT) HasKey(key string) (result bool, errs []error) {
 	return dto.Value, errs
 }
 
-func (cli *CLIENT) SetString(key, v string) (errs []error) {
+func (cli *CLIENT) SetString(key, v string, ttl ...int) (errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Post(cli.Url + cli.APIUrl + "/string/" + key).
-		Send(util.StringDTO{Value: v}).
+	resp, body, errs := cli.request(gorequest.POST, "/string/"+key).
+		Send(util.StringDTO{Value: v, TTL: optionalTTL(ttl)}).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -233,13 +323,12 @@ func (cli *CLIENT) SetString(key, v string) (errs []error) {
 	return errs
 }
 
-func (cli *CLIENT) SetInt(key string, v int) (errs []error) {
+func (cli *CLIENT) SetInt(key string, v int, ttl ...int) (errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Post(cli.Url+cli.APIUrl+"/int/"+key).
+	resp, body, errs := cli.request(gorequest.POST, "/int/"+key).
 		Set("Notes", "gorequst is coming!"). // Header
 		//Send(`{"value":"` + strconv.Itoa(v) + `"}`). // JSON
-		Send(util.IntDTO{Value: v}). // JSON
+		Send(util.IntDTO{Value: v, TTL: optionalTTL(ttl)}). // JSON
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -258,11 +347,10 @@ func (cli *CLIENT) SetInt(key string, v int) (errs []error) {
 	return errs
 }
 
-func (cli *CLIENT) SetList(key string, v util.List) (errs []error) {
+func (cli *CLIENT) SetList(key string, v util.List, ttl ...int) (errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Post(cli.Url + cli.APIUrl + "/list/" + key).
-		Send(util.ListDTO{Value: v}).
+	resp, body, errs := cli.request(gorequest.POST, "/list/"+key).
+		Send(util.ListDTO{Value: v, TTL: optionalTTL(ttl)}).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -281,11 +369,10 @@ func (cli *CLIENT) SetList(key string, v util.List) (errs []error) {
 	return errs
 }
 
-func (cli *CLIENT) SetDict(key string, v util.Dict) (errs []error) {
+func (cli *CLIENT) SetDict(key string, v util.Dict, ttl ...int) (errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Post(cli.Url + cli.APIUrl + "/dict/" + key).
-		Send(util.DictDTO{Value: v}).
+	resp, body, errs := cli.request(gorequest.POST, "/dict/"+key).
+		Send(util.DictDTO{Value: v, TTL: optionalTTL(ttl)}).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -306,8 +393,7 @@ func (cli *CLIENT) SetDict(key string, v util.Dict) (errs []error) {
 
 func (cli *CLIENT) UpdateString(key, v string) (result string, errs []error) {
 	var dto util.StringDTO
-	resp, body, errs := cli.agent.
-		Put(cli.Url + cli.APIUrl + "/string/" + key).
+	resp, body, errs := cli.request(gorequest.PUT, "/string/"+key).
 		Send(util.StringDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -329,8 +415,7 @@ func (cli *CLIENT) UpdateString(key, v string) (result string, errs []error) {
 
 func (cli *CLIENT) UpdateInt(key string, v int) (result int, errs []error) {
 	var dto util.IntDTO
-	resp, body, errs := cli.agent.
-		Put(cli.Url + cli.APIUrl + "/int/" + key).
+	resp, body, errs := cli.request(gorequest.PUT, "/int/"+key).
 		Send(util.IntDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -352,8 +437,7 @@ func (cli *CLIENT) UpdateInt(key string, v int) (result int, errs []error) {
 
 func (cli *CLIENT) UpdateList(key string, v util.List) (result util.List, errs []error) {
 	var dto util.ListDTO
-	resp, body, errs := cli.agent.
-		Put(cli.Url + cli.APIUrl + "/list/" + key).
+	resp, body, errs := cli.request(gorequest.PUT, "/list/"+key).
 		Send(util.ListDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -375,8 +459,7 @@ func (cli *CLIENT) UpdateList(key string, v util.List) (result util.List, errs [
 
 func (cli *CLIENT) UpdateDict(key string, v util.Dict) (result util.Dict, errs []error) {
 	var dto util.DictDTO
-	resp, body, errs := cli.agent.
-		Put(cli.Url + cli.APIUrl + "/dict/" + key).
+	resp, body, errs := cli.request(gorequest.PUT, "/dict/"+key).
 		Send(util.DictDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -398,8 +481,7 @@ func (cli *CLIENT) UpdateDict(key string, v util.Dict) (result util.Dict, errs [
 
 func (cli *CLIENT) AppendToList(key, v string) (errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Put(cli.Url + cli.APIUrl + "/list/element/" + key).
+	resp, body, errs := cli.request(gorequest.PUT, "/list/element/"+key).
 		Send(util.StringDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -421,8 +503,7 @@ func (cli *CLIENT) AppendToList(key, v string) (errs []error) {
 
 func (cli *CLIENT) Increment(key string) (result int, errs []error) {
 	var dto util.IntDTO
-	resp, body, errs := cli.agent.
-		Put(cli.Url + cli.APIUrl + "/int/increment/" + key).
+	resp, body, errs := cli.request(gorequest.PUT, "/int/increment/"+key).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -443,8 +524,7 @@ func (cli *CLIENT) Increment(key string) (result int, errs []error) {
 
 func (cli *CLIENT) Remove(key string) (result int, errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Delete(cli.Url + cli.APIUrl + "/remove/" + key).
+	resp, body, errs := cli.request(gorequest.DELETE, "/remove/"+key).
 		EndStruct(&dto)
 
 	if errs != nil {
@@ -465,8 +545,7 @@ func (cli *CLIENT) Remove(key string) (result int, errs []error) {
 
 func (cli *CLIENT) RemoveFromList(key, v string) (result int, errs []error) {
 	var dto util.IntDTO
-	resp, body, errs := cli.agent.
-		Delete(cli.Url + cli.APIUrl + "/list/element/" + key).
+	resp, body, errs := cli.request(gorequest.DELETE, "/list/element/"+key).
 		Send(util.StringDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -488,8 +567,7 @@ func (cli *CLIENT) RemoveFromList(key, v string) (result int, errs []error) {
 
 func (cli *CLIENT) RemoveFromDict(key, v string) (result int, errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Delete(cli.Url + cli.APIUrl + "/dict/element/" + key).
+	resp, body, errs := cli.request(gorequest.DELETE, "/dict/element/"+key).
 		Send(util.StringDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -511,8 +589,7 @@ func (cli *CLIENT) RemoveFromDict(key, v string) (result int, errs []error) {
 
 func (cli *CLIENT) SetTTL(key string, v int) (result int, errs []error) {
 	var dto util.BasicDTO
-	resp, body, errs := cli.agent.
-		Post(cli.Url + cli.APIUrl + "/ttl/" + key).
+	resp, body, errs := cli.request(gorequest.POST, "/ttl/"+key).
 		Send(util.IntDTO{Value: v}).
 		EndStruct(&dto)
 
@@ -532,6 +609,388 @@ func (cli *CLIENT) SetTTL(key string, v int) (result int, errs []error) {
 	return dto.ErrorCode, errs
 }
 
+func (cli *CLIENT) GetTTL(key string) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.GET, "/ttl/"+key).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) Persist(key string) (result bool, errs []error) {
+	var dto util.BoolDTO
+	resp, body, errs := cli.request(gorequest.DELETE, "/ttl/"+key).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return false, errs
+	}
+
+	if resp == nil || body == nil {
+		return false, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return false, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ExpireAt(key string, v int) (result int, errs []error) {
+	var dto util.BasicDTO
+	resp, body, errs := cli.request(gorequest.POST, "/expireat/"+key).
+		Send(util.IntDTO{Value: v}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.ErrorCode, errs
+}
+
+func (cli *CLIENT) SAdd(key string, members ...string) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.PUT, "/set/element/"+key).
+		Send(util.ListDTO{Value: members}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) SRem(key string, members ...string) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.DELETE, "/set/element/"+key).
+		Send(util.ListDTO{Value: members}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) SIsMember(key, member string) (result bool, errs []error) {
+	var dto util.BoolDTO
+	resp, body, errs := cli.request(gorequest.POST, "/set/element/"+key).
+		Send(util.StringDTO{Value: member}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return false, errs
+	}
+
+	if resp == nil || body == nil {
+		return false, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return false, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) SMembers(key string) (result util.Set, errs []error) {
+	var dto util.SetDTO
+	resp, body, errs := cli.request(gorequest.GET, "/set/"+key).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	if resp == nil || body == nil {
+		return nil, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) SInter(keys ...string) (result util.Set, errs []error) {
+	return cli.combineSets("/sets/inter", keys)
+}
+
+func (cli *CLIENT) SUnion(keys ...string) (result util.Set, errs []error) {
+	return cli.combineSets("/sets/union", keys)
+}
+
+func (cli *CLIENT) SDiff(keys ...string) (result util.Set, errs []error) {
+	return cli.combineSets("/sets/diff", keys)
+}
+
+func (cli *CLIENT) combineSets(path string, keys []string) (result util.Set, errs []error) {
+	var dto util.SetDTO
+	resp, body, errs := cli.request(gorequest.POST, path).
+		Send(util.ListDTO{Value: keys}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	if resp == nil || body == nil {
+		return nil, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZAdd(key string, members ...util.ZMember) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.PUT, "/zset/element/"+key).
+		Send(util.ZSetDTO{Value: members}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZIncrBy(key string, increment float64, member string) (result float64, errs []error) {
+	var dto util.FloatDTO
+	resp, body, errs := cli.request(gorequest.PUT, "/zset/increment/"+key).
+		Send(util.ZMemberDTO{Value: util.ZMember{Member: member, Score: increment}}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return 0, errs
+	}
+
+	if resp == nil || body == nil {
+		return 0, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZRem(key string, members ...string) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.DELETE, "/zset/element/"+key).
+		Send(util.ListDTO{Value: members}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZRemRangeByScore(key string, min, max float64) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.DELETE, "/zset/score/"+key).
+		Send(scoreRange(min, max)).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZScore(key, member string) (result float64, errs []error) {
+	var dto util.FloatDTO
+	resp, body, errs := cli.request(gorequest.POST, "/zset/element/"+key).
+		Send(util.StringDTO{Value: member}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return 0, errs
+	}
+
+	if resp == nil || body == nil {
+		return 0, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZRank(key, member string) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.POST, "/zset/rank/"+key).
+		Send(util.StringDTO{Value: member}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return -1, errs
+	}
+
+	if resp == nil || body == nil {
+		return -1, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return -1, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZRange(key string, start, stop int) (result util.ZSet, errs []error) {
+	var dto util.ZSetDTO
+	resp, body, errs := cli.request(gorequest.POST, "/zset/range/"+key).
+		Send(util.RangeDTO{Start: start, Stop: stop}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	if resp == nil || body == nil {
+		return nil, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+func (cli *CLIENT) ZRangeByScore(key string, min, max float64) (result util.ZSet, errs []error) {
+	var dto util.ZSetDTO
+	resp, body, errs := cli.request(gorequest.POST, "/zset/score/"+key).
+		Send(scoreRange(min, max)).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	if resp == nil || body == nil {
+		return nil, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+// Infinite bounds are sent as missing ones
+func scoreRange(min, max float64) util.ScoreRangeDTO {
+	var dto util.ScoreRangeDTO
+	if !math.IsInf(min, 0) {
+		dto.Min = &min
+	}
+	if !math.IsInf(max, 0) {
+		dto.Max = &max
+	}
+
+	return dto
+}
+
+func optionalTTL(ttl []int) int {
+	if len(ttl) == 0 {
+		return 0
+	}
+
+	return ttl[0]
+}
+
 func checkBasicError(body []byte) error {
 	var resultRaw util.BasicDTO
 	err := json.Unmarshal(body, &resultRaw)
@@ -540,7 +999,7 @@ func checkBasicError(body []byte) error {
 	}
 
 	if resultRaw.ErrorCode != 0 {
-		return fmt.Errorf(resultRaw.ErrorMessage)
+		return util.DecodeError(resultRaw.ErrorCode, resultRaw.ErrorMessage)
 	}
 
 	return nil
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Watch sets, updates, removals, expirations and evictions of keys starting with prefix
+// Events come until stop is called or the server drops the watch, the channel is closed then
+func (cli *CLIENT) Watch(prefix string) (events <-chan util.Event, stop func(), errs []error) {
+	out := make(chan util.Event, subscriberBuffer)
+	stop, errs = cli.stream("/watch", url.Values{"prefix": {prefix}}, func(ctx context.Context, event string, data []byte) bool {
+		if event == "error" {
+			return false
+		}
+
+		var e util.Event
+		if json.Unmarshal(data, &e) != nil {
+			return false
+		}
+
+		select {
+		case out <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(out) })
+
+	if errs != nil {
+		return nil, nil, errs
+	}
+
+	return out, stop, nil
+}
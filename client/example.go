@@ -2,6 +2,8 @@ package client
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/anevsky/cachego/util"
 )
@@ -101,6 +103,55 @@ func testClient() {
 
 	v20, errs := cli.SetTTL("lll", 7500)
 	printInfo(v20, errs)
+
+	v21, errs := cli.GetTTL("lll")
+	printInfo(v21, errs)
+
+	v22, errs := cli.Persist("lll")
+	printInfo(v22, errs)
+
+	v23, errs := cli.ExpireAt("lll", int(time.Now().Add(time.Minute).UnixNano()/int64(time.Millisecond)))
+	printInfo(v23, errs)
+
+	//
+
+	v24, errs := cli.SAdd("ttt", "m1", "m2", "m3")
+	printInfo(v24, errs)
+
+	v25, errs := cli.SAdd("ttt2", "m2", "m3", "m4")
+	printInfo(v25, errs)
+
+	v26, errs := cli.SIsMember("ttt", "m2")
+	printInfo(v26, errs)
+
+	v27, errs := cli.SInter("ttt", "ttt2")
+	printInfo(v27, errs)
+
+	v28, errs := cli.SRem("ttt", "m1")
+	printInfo(v28, errs)
+
+	v29, errs := cli.SMembers("ttt")
+	printInfo(v29, errs)
+
+	//
+
+	v30, errs := cli.ZAdd("zzz", util.ZMember{Member: "m1", Score: 10}, util.ZMember{Member: "m2", Score: 20.5})
+	printInfo(v30, errs)
+
+	v31, errs := cli.ZIncrBy("zzz", 15, "m1")
+	printInfo(v31, errs)
+
+	v32, errs := cli.ZRank("zzz", "m1")
+	printInfo(v32, errs)
+
+	v33, errs := cli.ZRange("zzz", 0, -1)
+	printInfo(v33, errs)
+
+	v34, errs := cli.ZRangeByScore("zzz", 20, math.Inf(1))
+	printInfo(v34, errs)
+
+	v35, errs := cli.ZRemRangeByScore("zzz", math.Inf(-1), 21)
+	printInfo(v35, errs)
 }
 
 func printInfo(value interface{}, errs []error) {
@@ -116,6 +167,10 @@ func printInfo(value interface{}, errs []error) {
 			fmt.Printf("#Result: %v \n", v)
 		case util.Dict:
 			fmt.Printf("#Result: %v \n", v)
+		case util.Set:
+			fmt.Printf("#Result: %v \n", v.Members())
+		case util.ZSet:
+			fmt.Printf("#Result: %v \n", v)
 		default:
 			fmt.Printf("#Result: %v \n", v)
 		}
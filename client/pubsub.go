@@ -0,0 +1,140 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/parnurzeal/gorequest"
+)
+
+// Messages buffered by a subscriber before it stops reading the stream
+const subscriberBuffer = 1024
+
+// Send message to subscribers of the channel
+// Returns number of deliveries
+func (cli *CLIENT) Publish(channel, v string) (result int, errs []error) {
+	var dto util.IntDTO
+	resp, body, errs := cli.request(gorequest.POST, "/publish/"+url.PathEscape(channel)).
+		Send(util.StringDTO{Value: v}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return 0, errs
+	}
+
+	if resp == nil || body == nil {
+		return 0, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	return dto.Value, errs
+}
+
+// Subscribe to channels, messages come until stop is called or the server drops the subscription
+// The channel of messages is closed then
+func (cli *CLIENT) Subscribe(channels ...string) (messages <-chan util.Message, stop func(), errs []error) {
+	return cli.subscribe(url.Values{"channel": channels})
+}
+
+// Subscribe to channels matching glob patterns, e.g. news.*
+func (cli *CLIENT) PSubscribe(patterns ...string) (messages <-chan util.Message, stop func(), errs []error) {
+	return cli.subscribe(url.Values{"pattern": patterns})
+}
+
+func (cli *CLIENT) subscribe(query url.Values) (messages <-chan util.Message, stop func(), errs []error) {
+	out := make(chan util.Message, subscriberBuffer)
+	stop, errs = cli.stream("/subscribe", query, func(ctx context.Context, event string, data []byte) bool {
+		if event != "message" {
+			return false
+		}
+
+		var m util.Message
+		if json.Unmarshal(data, &m) != nil {
+			return false
+		}
+
+		select {
+		case out <- m:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, func() { close(out) })
+
+	if errs != nil {
+		return nil, nil, errs
+	}
+
+	return out, stop, nil
+}
+
+// Read Server-Sent Events in the background until handle returns false or stop is called
+// done is called when reading is over
+func (cli *CLIENT) stream(path string, query url.Values, handle func(ctx context.Context, event string, data []byte) bool, done func()) (stop func(), errs []error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req, err := http.NewRequest(http.MethodGet, cli.Url+cli.APIUrl+path+"?"+query.Encode(), nil)
+	if err != nil {
+		cancel()
+		return nil, []error{err}
+	}
+	req = req.WithContext(ctx)
+	cli.authorize(req)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := cli.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, []error{err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		defer cancel()
+
+		var dto util.BasicDTO
+		if err := json.NewDecoder(resp.Body).Decode(&dto); err != nil || dto.ErrorCode == 0 {
+			return nil, []error{fmt.Errorf("%s: %s", path, resp.Status)}
+		}
+		return nil, []error{util.DecodeError(dto.ErrorCode, dto.ErrorMessage)}
+	}
+
+	go func() {
+		defer done()
+		defer cancel()
+		defer resp.Body.Close()
+
+		r := bufio.NewReader(resp.Body)
+		event := ""
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if !handle(ctx, event, []byte(strings.TrimPrefix(line, "data: "))) {
+					return
+				}
+			case line == "":
+				event = ""
+			}
+		}
+	}()
+
+	return cancel, nil
+}
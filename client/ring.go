@@ -0,0 +1,105 @@
+package client
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// Default number of virtual nodes per server
+const defaultVirtualNodes = 160
+
+// Consistent hash ring
+// Every node owns many points on the ring, a key belongs to the node owning
+// the first point at or after the hash of the key. Adding or removing a node
+// moves only the keys between its points and their predecessors.
+type ring struct {
+	virtualNodes int
+	points       []uint32
+	owners       map[uint32]string
+	nodes        map[string]bool
+}
+
+func newRing(virtualNodes int) *ring {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+
+	return &ring{
+		virtualNodes: virtualNodes,
+		owners:       map[uint32]string{},
+		nodes:        map[string]bool{},
+	}
+}
+
+func ringHash(s string) uint32 {
+	return crc32.ChecksumIEEE([]byte(s))
+}
+
+func (r *ring) add(node string) {
+	if r.nodes[node] {
+		return
+	}
+	r.nodes[node] = true
+
+	for i := 0; i < r.virtualNodes; i++ {
+		point := ringHash(strconv.Itoa(i) + "-" + node)
+		// On collision the point stays with the smallest node, so it doesn't depend on the order of adds
+		if owner, taken := r.owners[point]; taken && owner < node {
+			continue
+		}
+		r.owners[point] = node
+	}
+
+	r.sortPoints()
+}
+
+func (r *ring) remove(node string) {
+	if !r.nodes[node] {
+		return
+	}
+	delete(r.nodes, node)
+
+	// Rebuild to give collided points back to the remaining nodes
+	r.owners = map[uint32]string{}
+	nodes := r.nodes
+	r.nodes = map[string]bool{}
+	for n := range nodes {
+		r.add(n)
+	}
+	r.sortPoints()
+}
+
+func (r *ring) sortPoints() {
+	r.points = r.points[:0]
+	for point := range r.owners {
+		r.points = append(r.points, point)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Node owning the key, empty if the ring is empty
+func (r *ring) get(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	hash := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.owners[r.points[i]]
+}
+
+// Nodes in sorted order
+func (r *ring) list() []string {
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	return nodes
+}
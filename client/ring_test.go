@@ -0,0 +1,81 @@
+package client
+
+import (
+	"strconv"
+	"testing"
+)
+
+func ringOf(nodes ...string) *ring {
+	r := newRing(defaultVirtualNodes)
+	for _, node := range nodes {
+		r.add(node)
+	}
+
+	return r
+}
+
+func TestRingBalance(t *testing.T) {
+	t.Log("Testing keys are spread evenly over nodes...")
+
+	nodes := []string{"http://10.0.0.1:8027", "http://10.0.0.2:8027", "http://10.0.0.3:8027", "http://10.0.0.4:8027"}
+	r := ringOf(nodes...)
+
+	const keys = 100000
+	owned := map[string]int{}
+	for i := 0; i < keys; i++ {
+		owned[r.get("key"+strconv.Itoa(i))]++
+	}
+
+	for _, node := range nodes {
+		share := float64(owned[node]) / keys
+		if share < 0.15 || share > 0.35 {
+			t.Errorf("Expected about 25%% of keys on %s, but it was %.1f%% instead.", node, share*100)
+		}
+	}
+}
+
+func TestRingMoves(t *testing.T) {
+	t.Log("Testing adding and removing nodes moves few keys...")
+
+	before := ringOf("http://10.0.0.1:8027", "http://10.0.0.2:8027", "http://10.0.0.3:8027")
+	after := ringOf("http://10.0.0.1:8027", "http://10.0.0.2:8027", "http://10.0.0.3:8027", "http://10.0.0.4:8027")
+
+	const keys = 100000
+	moved := 0
+	for i := 0; i < keys; i++ {
+		key := "key" + strconv.Itoa(i)
+		from, to := before.get(key), after.get(key)
+		if from != to {
+			moved++
+			if to != "http://10.0.0.4:8027" {
+				t.Fatalf("Expected '%s' to move only to the new node, but it moved to %s instead.", key, to)
+			}
+		}
+	}
+
+	if share := float64(moved) / keys; share > 0.35 {
+		t.Errorf("Expected about 25%% of keys to move, but it was %.1f%% instead.", share*100)
+	}
+
+	// Removing the node restores the previous placement
+	after.remove("http://10.0.0.4:8027")
+	for i := 0; i < keys; i++ {
+		key := "key" + strconv.Itoa(i)
+		if before.get(key) != after.get(key) {
+			t.Fatalf("Expected '%s' on %s, but it was on %s instead.", key, before.get(key), after.get(key))
+		}
+	}
+}
+
+func TestRingEmpty(t *testing.T) {
+	t.Log("Testing empty ring...")
+
+	r := ringOf("a")
+	r.remove("a")
+	if node := r.get("key"); node != "" {
+		t.Errorf("Expected no node, but it was %s instead.", node)
+	}
+	if len(r.list()) != 0 {
+		t.Errorf("Expected no nodes, but it was %v instead.", r.list())
+	}
+}
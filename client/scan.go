@@ -0,0 +1,95 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/parnurzeal/gorequest"
+)
+
+// Scanner Iterator over keys of the server, fetched page by page
+// Keys present during the whole scan are returned exactly once
+type Scanner struct {
+	cli     *CLIENT
+	options util.ScanOptions
+	cursor  uint64
+	started bool
+	keys    []string
+	key     string
+	errs    []error
+}
+
+// Iterate over keys matching options
+//
+//	it := cli.Scan(util.ScanOptions{Match: "user:*"})
+//	for it.Next() {
+//		fmt.Println(it.Key())
+//	}
+//	if errs := it.Errs(); errs != nil {
+//		...
+//	}
+func (cli *CLIENT) Scan(options util.ScanOptions) *Scanner {
+	return &Scanner{cli: cli, options: options}
+}
+
+// Advance to the next key, returns false when the scan is over or failed
+func (it *Scanner) Next() bool {
+	for len(it.keys) == 0 {
+		if it.errs != nil || (it.started && it.cursor == 0) {
+			return false
+		}
+
+		it.keys, it.cursor, it.errs = it.cli.ScanPage(it.cursor, it.options)
+		it.started = true
+	}
+
+	it.key = it.keys[0]
+	it.keys = it.keys[1:]
+
+	return true
+}
+
+// Current key
+func (it *Scanner) Key() string {
+	return it.key
+}
+
+// Errors which stopped the scan
+func (it *Scanner) Errs() []error {
+	return it.errs
+}
+
+// Get page of keys after cursor, start with cursor 0
+// Returns the cursor of the next page, 0 when the scan is over
+func (cli *CLIENT) ScanPage(cursor uint64, options util.ScanOptions) (result []string, next uint64, errs []error) {
+	query := url.Values{"cursor": {strconv.FormatUint(cursor, 10)}}
+	if options.Match != "" {
+		query.Set("match", options.Match)
+	}
+	if options.Count > 0 {
+		query.Set("count", strconv.Itoa(options.Count))
+	}
+	if options.Type != "" {
+		query.Set("type", options.Type)
+	}
+
+	var dto util.ScanDTO
+	resp, body, errs := cli.request(gorequest.GET, "/scan?"+query.Encode()).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return nil, 0, errs
+	}
+
+	if resp == nil || body == nil {
+		return nil, 0, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return nil, 0, []error{err}
+	}
+
+	return dto.Keys, dto.Cursor, errs
+}
@@ -0,0 +1,449 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// SHARDED Client spreading keys over several servers with consistent hashing
+// Keys, Len and Stats are gathered from all servers,
+// multi-key set operations fetch sets from their servers and combine them locally.
+type SHARDED struct {
+	sync.RWMutex
+	APIUrl string
+	// Client of a node, authenticated the same way for all of them
+	create func() CLIENT
+	ring   *ring
+	nodes  map[string]*CLIENT
+}
+
+// Create client for servers by urls, e.g. http://localhost:8027
+func CreateSharded(urls ...string) *SHARDED {
+	return CreateShardedWithCredentials(DefaultCredentials, urls...)
+}
+
+// Create client for servers by urls authenticating as the given user on all of them
+func CreateShardedWithCredentials(credentials Credentials, urls ...string) *SHARDED {
+	return createSharded(func() CLIENT { return CreateWithCredentials(credentials) }, urls)
+}
+
+// Create client for servers by urls authenticating by API token on all of them
+func CreateShardedWithToken(token string, urls ...string) *SHARDED {
+	return createSharded(func() CLIENT { return CreateWithToken(token) }, urls)
+}
+
+func createSharded(create func() CLIENT, urls []string) *SHARDED {
+	cli := &SHARDED{
+		APIUrl: "/v1",
+		create: create,
+		ring:   newRing(defaultVirtualNodes),
+		nodes:  map[string]*CLIENT{},
+	}
+
+	for _, url := range urls {
+		cli.AddNode(url)
+	}
+
+	return cli
+}
+
+// Add server, only keys falling between its points on the ring move to it
+func (cli *SHARDED) AddNode(url string) {
+	cli.Lock()
+	defer cli.Unlock()
+
+	if _, ok := cli.nodes[url]; ok {
+		return
+	}
+
+	node := cli.create()
+	node.Url = url
+	node.APIUrl = cli.APIUrl
+
+	cli.nodes[url] = &node
+	cli.ring.add(url)
+}
+
+// Remove server, only its keys move to other servers
+func (cli *SHARDED) RemoveNode(url string) {
+	cli.Lock()
+	defer cli.Unlock()
+
+	delete(cli.nodes, url)
+	cli.ring.remove(url)
+}
+
+// Urls of servers in sorted order
+func (cli *SHARDED) Nodes() []string {
+	cli.RLock()
+	defer cli.RUnlock()
+
+	return cli.ring.list()
+}
+
+// Client of the server owning the key
+// Requests fail with an error if there are no servers
+func (cli *SHARDED) Node(key string) *CLIENT {
+	cli.RLock()
+	defer cli.RUnlock()
+
+	if node, ok := cli.nodes[cli.ring.get(key)]; ok {
+		return node
+	}
+
+	node := cli.create()
+	return &node
+}
+
+// Call fn for every server in parallel, returns errors of all calls
+func (cli *SHARDED) each(fn func(node *CLIENT) []error) (errs []error) {
+	cli.RLock()
+	nodes := make([]*CLIENT, 0, len(cli.nodes))
+	for _, node := range cli.nodes {
+		nodes = append(nodes, node)
+	}
+	cli.RUnlock()
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *CLIENT) {
+			defer wg.Done()
+
+			if nodeErrs := fn(node); nodeErrs != nil {
+				mutex.Lock()
+				errs = append(errs, nodeErrs...)
+				mutex.Unlock()
+			}
+		}(node)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// Total number of objects on all servers
+func (cli *SHARDED) Len() (result int, errs []error) {
+	var mutex sync.Mutex
+	errs = cli.each(func(node *CLIENT) []error {
+		v, errs := node.Len()
+		mutex.Lock()
+		result += v
+		mutex.Unlock()
+		return errs
+	})
+
+	return result, errs
+}
+
+// Keys of all servers
+func (cli *SHARDED) Keys() (result []string, errs []error) {
+	var mutex sync.Mutex
+	result = []string{}
+	errs = cli.each(func(node *CLIENT) []error {
+		v, errs := node.Keys()
+		mutex.Lock()
+		result = append(result, v...)
+		mutex.Unlock()
+		return errs
+	})
+
+	return result, errs
+}
+
+// Stats summed over all servers
+func (cli *SHARDED) Stats() (result util.Stats, errs []error) {
+	var mutex sync.Mutex
+	result.KeysByType = map[string]int{}
+	errs = cli.each(func(node *CLIENT) []error {
+		v, errs := node.Stats()
+		if errs == nil {
+			mutex.Lock()
+			addStats(&result, v)
+			mutex.Unlock()
+		}
+		return errs
+	})
+
+	return result, errs
+}
+
+func addStats(total *util.Stats, stats util.Stats) {
+	total.MemoryAlloc += stats.MemoryAlloc
+	total.MemoryTotalAlloc += stats.MemoryTotalAlloc
+	total.MemoryHeapAlloc += stats.MemoryHeapAlloc
+	total.MemoryHeapSys += stats.MemoryHeapSys
+	total.MemoryHeapObjects += stats.MemoryHeapObjects
+	total.MemoryMallocs += stats.MemoryMallocs
+	total.MemoryFrees += stats.MemoryFrees
+	total.GCPauseTotalNs += stats.GCPauseTotalNs
+	total.NumGC += stats.NumGC
+	total.Gets += stats.Gets
+	total.Hits += stats.Hits
+	total.Misses += stats.Misses
+	total.Sets += stats.Sets
+	total.Deletes += stats.Deletes
+	total.Expirations += stats.Expirations
+	total.Evictions += stats.Evictions
+	total.WrongType += stats.WrongType
+	total.Keys += stats.Keys
+	total.UsedBytes += stats.UsedBytes
+	for name, n := range stats.KeysByType {
+		total.KeysByType[name] += n
+	}
+
+	// Servers are expected to share the policy, a mix is reported as such
+	if total.EvictionPolicy == "" {
+		total.EvictionPolicy = stats.EvictionPolicy
+	} else if total.EvictionPolicy != stats.EvictionPolicy {
+		total.EvictionPolicy = "mixed"
+	}
+}
+
+// Save snapshots on all servers
+func (cli *SHARDED) Save() (errs []error) {
+	return cli.each(func(node *CLIENT) []error {
+		return node.Save()
+	})
+}
+
+// Compact append-only logs on all servers
+func (cli *SHARDED) RewriteAppendLog() (errs []error) {
+	return cli.each(func(node *CLIENT) []error {
+		return node.RewriteAppendLog()
+	})
+}
+
+// Set operations across servers are not atomic
+func (cli *SHARDED) SInter(keys ...string) (result util.Set, errs []error) {
+	return cli.combineSets(keys, (*CLIENT).SInter, func(result, set util.Set, first bool) util.Set {
+		if first {
+			return set
+		}
+
+		for member := range result {
+			if !set.Has(member) {
+				delete(result, member)
+			}
+		}
+
+		return result
+	})
+}
+
+func (cli *SHARDED) SUnion(keys ...string) (result util.Set, errs []error) {
+	return cli.combineSets(keys, (*CLIENT).SUnion, func(result, set util.Set, first bool) util.Set {
+		for member := range set {
+			result[member] = struct{}{}
+		}
+
+		return result
+	})
+}
+
+func (cli *SHARDED) SDiff(keys ...string) (result util.Set, errs []error) {
+	return cli.combineSets(keys, (*CLIENT).SDiff, func(result, set util.Set, first bool) util.Set {
+		if first {
+			return set
+		}
+
+		for member := range set {
+			delete(result, member)
+		}
+
+		return result
+	})
+}
+
+// Run set operation on the server when it owns all keys,
+// otherwise fetch sets one by one and fold them
+func (cli *SHARDED) combineSets(keys []string,
+	remote func(node *CLIENT, keys ...string) (util.Set, []error),
+	combine func(result, set util.Set, first bool) util.Set) (result util.Set, errs []error) {
+	if len(keys) == 0 {
+		return util.Set{}, nil
+	}
+
+	node := cli.Node(keys[0])
+	sameNode := true
+	for _, key := range keys[1:] {
+		if cli.Node(key) != node {
+			sameNode = false
+			break
+		}
+	}
+	if sameNode {
+		return remote(node, keys...)
+	}
+
+	result = util.Set{}
+	for i, key := range keys {
+		// Union of a single key is its set or an empty one if key is missing
+		set, errs := cli.Node(key).SUnion(key)
+		if errs != nil {
+			return nil, errs
+		}
+
+		result = combine(result, set, i == 0)
+	}
+
+	return result, nil
+}
+
+func (cli *SHARDED) GetString(key string) (result string, errs []error) {
+	return cli.Node(key).GetString(key)
+}
+
+func (cli *SHARDED) GetInt(key string) (result int, errs []error) {
+	return cli.Node(key).GetInt(key)
+}
+
+func (cli *SHARDED) GetListElement(key string, v int) (result string, errs []error) {
+	return cli.Node(key).GetListElement(key, v)
+}
+
+func (cli *SHARDED) GetDictElement(key, v string) (result string, errs []error) {
+	return cli.Node(key).GetDictElement(key, v)
+}
+
+func (cli *SHARDED) HasKey(key string) (result bool, errs []error) {
+	return cli.Node(key).HasKey(key)
+}
+
+func (cli *SHARDED) SetString(key, v string, ttl ...int) (errs []error) {
+	return cli.Node(key).SetString(key, v, ttl...)
+}
+
+func (cli *SHARDED) SetInt(key string, v int, ttl ...int) (errs []error) {
+	return cli.Node(key).SetInt(key, v, ttl...)
+}
+
+func (cli *SHARDED) SetList(key string, v util.List, ttl ...int) (errs []error) {
+	return cli.Node(key).SetList(key, v, ttl...)
+}
+
+func (cli *SHARDED) SetDict(key string, v util.Dict, ttl ...int) (errs []error) {
+	return cli.Node(key).SetDict(key, v, ttl...)
+}
+
+func (cli *SHARDED) UpdateString(key, v string) (result string, errs []error) {
+	return cli.Node(key).UpdateString(key, v)
+}
+
+func (cli *SHARDED) UpdateInt(key string, v int) (result int, errs []error) {
+	return cli.Node(key).UpdateInt(key, v)
+}
+
+func (cli *SHARDED) UpdateList(key string, v util.List) (result util.List, errs []error) {
+	return cli.Node(key).UpdateList(key, v)
+}
+
+func (cli *SHARDED) UpdateDict(key string, v util.Dict) (result util.Dict, errs []error) {
+	return cli.Node(key).UpdateDict(key, v)
+}
+
+func (cli *SHARDED) AppendToList(key, v string) (errs []error) {
+	return cli.Node(key).AppendToList(key, v)
+}
+
+func (cli *SHARDED) Increment(key string) (result int, errs []error) {
+	return cli.Node(key).Increment(key)
+}
+
+func (cli *SHARDED) Remove(key string) (result int, errs []error) {
+	return cli.Node(key).Remove(key)
+}
+
+func (cli *SHARDED) RemoveFromList(key, v string) (result int, errs []error) {
+	return cli.Node(key).RemoveFromList(key, v)
+}
+
+func (cli *SHARDED) RemoveFromDict(key, v string) (result int, errs []error) {
+	return cli.Node(key).RemoveFromDict(key, v)
+}
+
+func (cli *SHARDED) SetTTL(key string, v int) (result int, errs []error) {
+	return cli.Node(key).SetTTL(key, v)
+}
+
+func (cli *SHARDED) GetTTL(key string) (result int, errs []error) {
+	return cli.Node(key).GetTTL(key)
+}
+
+func (cli *SHARDED) Persist(key string) (result bool, errs []error) {
+	return cli.Node(key).Persist(key)
+}
+
+func (cli *SHARDED) ExpireAt(key string, v int) (result int, errs []error) {
+	return cli.Node(key).ExpireAt(key, v)
+}
+
+func (cli *SHARDED) SAdd(key string, members ...string) (result int, errs []error) {
+	return cli.Node(key).SAdd(key, members...)
+}
+
+func (cli *SHARDED) SRem(key string, members ...string) (result int, errs []error) {
+	return cli.Node(key).SRem(key, members...)
+}
+
+func (cli *SHARDED) SIsMember(key, member string) (result bool, errs []error) {
+	return cli.Node(key).SIsMember(key, member)
+}
+
+func (cli *SHARDED) SMembers(key string) (result util.Set, errs []error) {
+	return cli.Node(key).SMembers(key)
+}
+
+func (cli *SHARDED) ZAdd(key string, members ...util.ZMember) (result int, errs []error) {
+	return cli.Node(key).ZAdd(key, members...)
+}
+
+func (cli *SHARDED) ZIncrBy(key string, increment float64, member string) (result float64, errs []error) {
+	return cli.Node(key).ZIncrBy(key, increment, member)
+}
+
+func (cli *SHARDED) ZRem(key string, members ...string) (result int, errs []error) {
+	return cli.Node(key).ZRem(key, members...)
+}
+
+func (cli *SHARDED) ZRemRangeByScore(key string, min, max float64) (result int, errs []error) {
+	return cli.Node(key).ZRemRangeByScore(key, min, max)
+}
+
+func (cli *SHARDED) ZScore(key, member string) (result float64, errs []error) {
+	return cli.Node(key).ZScore(key, member)
+}
+
+func (cli *SHARDED) ZRank(key, member string) (result int, errs []error) {
+	return cli.Node(key).ZRank(key, member)
+}
+
+func (cli *SHARDED) ZRange(key string, start, stop int) (result util.ZSet, errs []error) {
+	return cli.Node(key).ZRange(key, start, stop)
+}
+
+func (cli *SHARDED) ZRangeByScore(key string, min, max float64) (result util.ZSet, errs []error) {
+	return cli.Node(key).ZRangeByScore(key, min, max)
+}
+
+func (cli *SHARDED) GetVersion(key string) (result uint64, errs []error) {
+	return cli.Node(key).GetVersion(key)
+}
+
+func (cli *SHARDED) CompareAndSwapString(key, v string, version uint64) (result uint64, errs []error) {
+	return cli.Node(key).CompareAndSwapString(key, v, version)
+}
+
+func (cli *SHARDED) CompareAndSwapInt(key string, v int, version uint64) (result uint64, errs []error) {
+	return cli.Node(key).CompareAndSwapInt(key, v, version)
+}
+
+func (cli *SHARDED) CompareAndSwapList(key string, v util.List, version uint64) (result uint64, errs []error) {
+	return cli.Node(key).CompareAndSwapList(key, v, version)
+}
+
+func (cli *SHARDED) CompareAndSwapDict(key string, v util.Dict, version uint64) (result uint64, errs []error) {
+	return cli.Node(key).CompareAndSwapDict(key, v, version)
+}
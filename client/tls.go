@@ -0,0 +1,87 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSOptions Settings of HTTPS connections to the server
+type TLSOptions struct {
+	// CA bundle verifying the server certificate, system roots if empty
+	CAFile string
+	// Client certificate and key for servers requiring one (mutual TLS)
+	CertFile, KeyFile string
+	// Skip verification of the server certificate, for tests only
+	InsecureSkipVerify bool
+}
+
+// Build TLS config from options
+func (options TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CAFile != "" {
+		data, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: no certificates found", options.CAFile)
+		}
+	}
+
+	if options.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// Connect to the server over HTTPS with options, Url is expected to start with https://
+func (cli *CLIENT) UseTLS(options TLSOptions) error {
+	config, err := options.config()
+	if err != nil {
+		return err
+	}
+
+	cli.useTLS(config)
+	return nil
+}
+
+func (cli *CLIENT) useTLS(config *tls.Config) {
+	cli.agent.TLSClientConfig(config)
+	cli.httpClient = &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+// Connect to all servers over HTTPS with options, including servers added later
+func (cli *SHARDED) UseTLS(options TLSOptions) error {
+	config, err := options.config()
+	if err != nil {
+		return err
+	}
+
+	cli.Lock()
+	defer cli.Unlock()
+
+	create := cli.create
+	cli.create = func() CLIENT {
+		node := create()
+		node.useTLS(config)
+		return node
+	}
+	for _, node := range cli.nodes {
+		node.useTLS(config)
+	}
+
+	return nil
+}
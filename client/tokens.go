@@ -0,0 +1,98 @@
+package client
+
+import (
+	"github.com/anevsky/cachego/util"
+	"github.com/parnurzeal/gorequest"
+)
+
+// Issue API token for user, ttl in milliseconds, 0 - never expires
+// Secret token is in the Token field, it can't be fetched again
+// Token rotation: create a new token, switch clients to it, then expire or revoke the old one
+func (cli *CLIENT) CreateToken(username, name string, ttl int64) (result util.TokenDTO, errs []error) {
+	var dto util.TokenDTO
+	resp, body, errs := cli.request(gorequest.POST, "/tokens").
+		Send(util.TokenDTO{Username: username, Name: name, TTL: ttl}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return util.TokenDTO{}, errs
+	}
+
+	if resp == nil || body == nil {
+		return util.TokenDTO{}, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return util.TokenDTO{}, []error{err}
+	}
+
+	return dto, errs
+}
+
+// Get API tokens without their secrets
+func (cli *CLIENT) Tokens() (result []util.TokenDTO, errs []error) {
+	var dto util.TokensDTO
+	resp, body, errs := cli.request(gorequest.GET, "/tokens").
+		EndStruct(&dto)
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	if resp == nil || body == nil {
+		return nil, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return dto.Tokens, errs
+}
+
+// Let API token expire after ttl in milliseconds
+func (cli *CLIENT) ExpireToken(id string, ttl int) (errs []error) {
+	var dto util.TokenDTO
+	resp, body, errs := cli.request(gorequest.POST, "/tokens/"+id+"/expire").
+		Send(util.IntDTO{Value: ttl}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return errs
+	}
+
+	if resp == nil || body == nil {
+		return []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return []error{err}
+	}
+
+	return errs
+}
+
+// Revoke API token right away
+func (cli *CLIENT) RevokeToken(id string) (errs []error) {
+	var dto util.BasicDTO
+	resp, body, errs := cli.request(gorequest.DELETE, "/tokens/"+id).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return errs
+	}
+
+	if resp == nil || body == nil {
+		return []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return []error{err}
+	}
+
+	return errs
+}
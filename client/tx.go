@@ -0,0 +1,216 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/parnurzeal/gorequest"
+)
+
+// TX Operations sent to the server as one batch by Exec
+// The server runs them atomically, either all of them take effect or none.
+type TX struct {
+	cli      *CLIENT
+	ops      []util.BatchOp
+	decoders []func(raw json.RawMessage) (interface{}, error)
+}
+
+// Start transaction
+func (cli *CLIENT) Multi() *TX {
+	return &TX{cli: cli}
+}
+
+func (tx *TX) queue(op util.BatchOp, value interface{}, decode func(raw json.RawMessage) (interface{}, error)) *TX {
+	if value != nil {
+		op.Value, _ = json.Marshal(value)
+	}
+	tx.ops = append(tx.ops, op)
+	tx.decoders = append(tx.decoders, decode)
+
+	return tx
+}
+
+// Number of queued operations
+func (tx *TX) Len() int {
+	return len(tx.ops)
+}
+
+// Send queued operations to the server
+// Returns results in the order of operations: string, int, float64, bool,
+// util.List or util.Dict as returned by the matching CLIENT method, nil if it returns nothing
+func (tx *TX) Exec() (result []interface{}, errs []error) {
+	var dto util.BatchDTO
+	resp, body, errs := tx.cli.request(gorequest.POST, "/batch").
+		Send(util.BatchDTO{Ops: tx.ops}).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	if resp == nil || body == nil {
+		return nil, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	if len(dto.Results) != len(tx.ops) {
+		return nil, []error{util.ErrorBadRequest}
+	}
+
+	result = make([]interface{}, len(dto.Results))
+	for i, raw := range dto.Results {
+		if result[i], err = tx.decoders[i](raw); err != nil {
+			return nil, []error{err}
+		}
+	}
+
+	return result, errs
+}
+
+func decodeNothing(raw json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func decodeString(raw json.RawMessage) (interface{}, error) {
+	var v string
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+func decodeInt(raw json.RawMessage) (interface{}, error) {
+	var v int
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+func decodeFloat(raw json.RawMessage) (interface{}, error) {
+	var v float64
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+func decodeBool(raw json.RawMessage) (interface{}, error) {
+	var v bool
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+func decodeList(raw json.RawMessage) (interface{}, error) {
+	var v util.List
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+func decodeDict(raw json.RawMessage) (interface{}, error) {
+	var v util.Dict
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+///////////////////////////////////////
+// Queued operations, same as the CLIENT methods
+///////////////////////////////////////
+
+func (tx *TX) GetString(key string) *TX {
+	return tx.queue(util.BatchOp{Op: "get", Key: key}, nil, decodeString)
+}
+
+func (tx *TX) GetInt(key string) *TX {
+	return tx.queue(util.BatchOp{Op: "get", Key: key}, nil, decodeInt)
+}
+
+func (tx *TX) GetListElement(key string, v int) *TX {
+	return tx.queue(util.BatchOp{Op: "get_list_element", Key: key}, v, decodeString)
+}
+
+func (tx *TX) GetDictElement(key, v string) *TX {
+	return tx.queue(util.BatchOp{Op: "get_dict_element", Key: key, Field: v}, nil, decodeString)
+}
+
+func (tx *TX) SetString(key, v string, ttl ...int) *TX {
+	return tx.queue(util.BatchOp{Op: "set_string", Key: key, TTL: optionalTTL(ttl)}, v, decodeNothing)
+}
+
+func (tx *TX) SetInt(key string, v int, ttl ...int) *TX {
+	return tx.queue(util.BatchOp{Op: "set_int", Key: key, TTL: optionalTTL(ttl)}, v, decodeNothing)
+}
+
+func (tx *TX) SetList(key string, v util.List, ttl ...int) *TX {
+	return tx.queue(util.BatchOp{Op: "set_list", Key: key, TTL: optionalTTL(ttl)}, v, decodeNothing)
+}
+
+func (tx *TX) SetDict(key string, v util.Dict, ttl ...int) *TX {
+	return tx.queue(util.BatchOp{Op: "set_dict", Key: key, TTL: optionalTTL(ttl)}, v, decodeNothing)
+}
+
+func (tx *TX) UpdateString(key, v string) *TX {
+	return tx.queue(util.BatchOp{Op: "update_string", Key: key}, v, decodeString)
+}
+
+func (tx *TX) UpdateInt(key string, v int) *TX {
+	return tx.queue(util.BatchOp{Op: "update_int", Key: key}, v, decodeInt)
+}
+
+func (tx *TX) UpdateList(key string, v util.List) *TX {
+	return tx.queue(util.BatchOp{Op: "update_list", Key: key}, v, decodeList)
+}
+
+func (tx *TX) UpdateDict(key string, v util.Dict) *TX {
+	return tx.queue(util.BatchOp{Op: "update_dict", Key: key}, v, decodeDict)
+}
+
+func (tx *TX) AppendToList(key, v string) *TX {
+	return tx.queue(util.BatchOp{Op: "append_to_list", Key: key}, v, decodeNothing)
+}
+
+func (tx *TX) Increment(key string) *TX {
+	return tx.queue(util.BatchOp{Op: "increment", Key: key}, nil, decodeInt)
+}
+
+func (tx *TX) Remove(key string) *TX {
+	return tx.queue(util.BatchOp{Op: "remove", Key: key}, nil, decodeNothing)
+}
+
+func (tx *TX) RemoveFromList(key, v string) *TX {
+	return tx.queue(util.BatchOp{Op: "remove_from_list", Key: key}, v, decodeInt)
+}
+
+func (tx *TX) SetDictElement(key, elementKey, v string) *TX {
+	return tx.queue(util.BatchOp{Op: "set_dict_element", Key: key, Field: elementKey}, v, decodeBool)
+}
+
+func (tx *TX) RemoveFromDict(key, v string) *TX {
+	return tx.queue(util.BatchOp{Op: "remove_from_dict", Key: key, Field: v}, nil, decodeNothing)
+}
+
+func (tx *TX) SetTTL(key string, v int) *TX {
+	return tx.queue(util.BatchOp{Op: "set_ttl", Key: key, TTL: v}, nil, decodeNothing)
+}
+
+func (tx *TX) Persist(key string) *TX {
+	return tx.queue(util.BatchOp{Op: "persist", Key: key}, nil, decodeBool)
+}
+
+func (tx *TX) SAdd(key string, members ...string) *TX {
+	return tx.queue(util.BatchOp{Op: "sadd", Key: key}, members, decodeInt)
+}
+
+func (tx *TX) SRem(key string, members ...string) *TX {
+	return tx.queue(util.BatchOp{Op: "srem", Key: key}, members, decodeInt)
+}
+
+func (tx *TX) ZAdd(key string, members ...util.ZMember) *TX {
+	return tx.queue(util.BatchOp{Op: "zadd", Key: key}, members, decodeInt)
+}
+
+func (tx *TX) ZIncrBy(key string, increment float64, member string) *TX {
+	return tx.queue(util.BatchOp{Op: "zincrby", Key: key, Field: member}, increment, decodeFloat)
+}
+
+func (tx *TX) ZRem(key string, members ...string) *TX {
+	return tx.queue(util.BatchOp{Op: "zrem", Key: key}, members, decodeInt)
+}
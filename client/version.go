@@ -0,0 +1,81 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/parnurzeal/gorequest"
+)
+
+// Get version of value by key, the version grows on every write of the value
+func (cli *CLIENT) GetVersion(key string) (result uint64, errs []error) {
+	var dto util.BasicDTO
+	resp, body, errs := cli.request(gorequest.GET, "/get/"+key).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return 0, errs
+	}
+
+	if resp == nil || body == nil {
+		return 0, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	return parseETag(resp.Header.Get("ETag"))
+}
+
+// Update string only if its version is still the given one
+// Returns the new version, fails with version mismatch if the value was written in between
+func (cli *CLIENT) CompareAndSwapString(key, v string, version uint64) (result uint64, errs []error) {
+	return cli.compareAndSwap("/string/"+key, util.StringDTO{Value: v}, version)
+}
+
+func (cli *CLIENT) CompareAndSwapInt(key string, v int, version uint64) (result uint64, errs []error) {
+	return cli.compareAndSwap("/int/"+key, util.IntDTO{Value: v}, version)
+}
+
+func (cli *CLIENT) CompareAndSwapList(key string, v util.List, version uint64) (result uint64, errs []error) {
+	return cli.compareAndSwap("/list/"+key, util.ListDTO{Value: v}, version)
+}
+
+func (cli *CLIENT) CompareAndSwapDict(key string, v util.Dict, version uint64) (result uint64, errs []error) {
+	return cli.compareAndSwap("/dict/"+key, util.DictDTO{Value: v}, version)
+}
+
+func (cli *CLIENT) compareAndSwap(path string, v interface{}, version uint64) (result uint64, errs []error) {
+	var dto util.BasicDTO
+	resp, body, errs := cli.request(gorequest.PUT, path).
+		Set("If-Match", `"`+strconv.FormatUint(version, 10)+`"`).
+		Send(v).
+		EndStruct(&dto)
+
+	if errs != nil {
+		return 0, errs
+	}
+
+	if resp == nil || body == nil {
+		return 0, []error{util.ErrorResponseOrBodyNil}
+	}
+
+	err := checkBasicError(body)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	return parseETag(resp.Header.Get("ETag"))
+}
+
+func parseETag(tag string) (result uint64, errs []error) {
+	version, err := strconv.ParseUint(strings.Trim(tag, `"`), 10, 64)
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	return version, nil
+}
@@ -1,15 +1,51 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
 
 	"github.com/anevsky/cachego/server"
 )
 
+// cachego [dump-config] [flags]
+// dump-config prints the effective config as JSON, with passwords hidden, instead of starting the server
+// cachego hash-password password prints bcrypt hash for the password_hash setting
 func main() {
+	if len(os.Args) == 3 && os.Args[1] == "hash-password" {
+		hash, err := server.HashPassword(os.Args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(hash)
+		return
+	}
+
+	args, dump := os.Args[1:], false
+	if len(args) > 0 && args[0] == "dump-config" {
+		args, dump = args[1:], true
+	}
+
+	config, err := server.LoadConfig(os.Args[0], args, os.Getenv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if dump {
+		data, _ := json.MarshalIndent(config.Redacted(), "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
 	fmt.Printf("Hello, Go!\n")
 
-	server := server.Create()
+	server := server.CreateWithConfig(config)
 	server.StartUp()
 
 	/*
@@ -5,80 +5,119 @@ import (
 )
 
 func (cache *CACHE) Get(key string) (interface{}, error) {
-	cache.RLock()
-	defer cache.RUnlock()
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
 
-	value, success := cache.data[key]
-	if !success {
-		return "", util.ErrorKeyNotFound
+	return shard.get(key)
+}
+
+// Caller must hold the read lock
+func (s *shard) get(key string) (interface{}, error) {
+	e, success := s.lookup(key)
+	if err := s.counters.read(success); err != nil {
+		return "", err
 	}
 
-	switch v := value.(type) {
+	s.eviction.access(key)
+
+	switch v := e.value.(type) {
 	case int:
 		return v, nil
 	case string:
 		return v, nil
-	case util.List:
-		return v, nil
-	case util.Dict:
-		return v, nil
+	case util.List, util.Dict, util.Set:
+		// Collections are changed in place by writers, callers get a copy
+		return copyValue(v), nil
+	case *sortedSet:
+		return v.members(), nil
 	default:
-		return "", util.ErrorWrongType
+		return "", s.counters.wrongTypeError()
 	}
 }
 
 func (cache *CACHE) GetListElement(key string, index int) (string, error) {
-	cache.RLock()
-	defer cache.RUnlock()
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
 
-	if index < 0 {
-		return "", util.ErrorIndexOutOfBounds
-	}
+	return shard.getListElement(key, index)
+}
 
-	value, success := cache.data[key]
-	if !success {
-		return "", util.ErrorKeyNotFound
+// Caller must hold the read lock
+func (s *shard) getListElement(key string, index int) (string, error) {
+	e, success := s.lookup(key)
+	if err := s.counters.read(success); err != nil {
+		return "", err
 	}
 
-	v, success := value.(util.List)
+	s.eviction.access(key)
+
+	v, success := e.value.(util.List)
 	if !success {
-		return "", util.ErrorWrongType
+		return "", s.counters.wrongTypeError()
 	}
 
-	if index <= len(v)-1 {
+	if index >= 0 && index <= len(v)-1 {
 		return v[index], nil
 	} else {
 		return "", util.ErrorIndexOutOfBounds
 	}
 }
 
+// Get list element, negative index counts from the end
+func (cache *CACHE) ListIndex(key string, index int) (string, error) {
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	if index < 0 {
+		if e, ok := shard.lookup(key); ok {
+			if list, ok := e.value.(util.List); ok {
+				index += len(list)
+			}
+		}
+	}
+
+	return shard.getListElement(key, index)
+}
+
 func (cache *CACHE) GetDictElement(key string, elementKey string) (string, error) {
-	cache.RLock()
-	defer cache.RUnlock()
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
 
-	value, success := cache.data[key]
-	if !success {
-		return "", util.ErrorKeyNotFound
+	return shard.getDictElement(key, elementKey)
+}
+
+// Caller must hold the read lock
+func (s *shard) getDictElement(key string, elementKey string) (string, error) {
+	e, success := s.lookup(key)
+	if err := s.counters.read(success); err != nil {
+		return "", err
 	}
 
-	v, success := value.(util.Dict)
+	s.eviction.access(key)
+
+	v, success := e.value.(util.Dict)
 	if !success {
-		return "", util.ErrorWrongType
+		return "", s.counters.wrongTypeError()
 	}
 
-	e, success := v[elementKey]
+	element, success := v[elementKey]
 	if !success {
 		return "", util.ErrorDictKeyNotFound
 	}
 
-	return e, nil
+	return element, nil
 }
 
 func (cache *CACHE) HasKey(key string) (bool, error) {
-	cache.RLock()
-	defer cache.RUnlock()
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
 
-	if _, ok := cache.data[key]; !ok {
+	if _, ok := shard.lookup(key); !ok {
 		return false, util.ErrorKeyNotFound
 	}
 
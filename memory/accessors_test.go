@@ -40,6 +40,33 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetCopy(t *testing.T) {
+	t.Log("Testing Get returns collections unaffected by later writes...")
+
+	cache := Alloc()
+	cache.SetList("list", util.List{"one", "two"})
+	cache.SetDict("dict", util.Dict{"k1": "v1"})
+	cache.SAdd("set", "m1")
+
+	list, _ := cache.Get("list")
+	dict, _ := cache.Get("dict")
+	set, _ := cache.Get("set")
+
+	cache.RemoveFromList("list", "one")
+	cache.SetDictElement("dict", "k2", "v2")
+	cache.SAdd("set", "m2")
+
+	if l := list.(util.List); len(l) != 2 || l[0] != "one" {
+		t.Errorf("Expected [one two], but it was %v instead.", l)
+	}
+	if d := dict.(util.Dict); len(d) != 1 {
+		t.Errorf("Expected map[k1:v1], but it was %v instead.", d)
+	}
+	if s := set.(util.Set); len(s) != 1 {
+		t.Errorf("Expected [m1], but it was %v instead.", s)
+	}
+}
+
 func TestGetListElement(t *testing.T) {
 	t.Log("Testing Get method...")
 
@@ -62,6 +89,35 @@ func TestGetListElement(t *testing.T) {
 	}
 }
 
+func TestListIndex(t *testing.T) {
+	t.Log("Testing ListIndex method...")
+
+	cache := Alloc()
+	cache.SetList("listTest", util.List{"one", "two", "three"})
+
+	tests := []struct {
+		index    int
+		expected string
+		err      error
+	}{
+		{0, "one", nil},
+		{-1, "three", nil},
+		{-3, "one", nil},
+		{-4, "", util.ErrorIndexOutOfBounds},
+		{3, "", util.ErrorIndexOutOfBounds},
+	}
+
+	for _, test := range tests {
+		if v, err := cache.ListIndex("listTest", test.index); v != test.expected || err != test.err {
+			t.Errorf("Expected %q (%v) at %d, but it was %q (%v) instead.", test.expected, test.err, test.index, v, err)
+		}
+	}
+
+	if _, err := cache.ListIndex("missing", -1); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorKeyNotFound, err)
+	}
+}
+
 func TestGetDictElement(t *testing.T) {
 	t.Log("Testing Get method...")
 
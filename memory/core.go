@@ -2,43 +2,132 @@ package memory
 
 import (
 	"runtime"
-	"sync"
 	"time"
 
 	"github.com/anevsky/cachego/util"
 )
 
 // CACHE In-memory cache with synchronization
+// Keyspace is split over shards, each guarded by its own lock
 type CACHE struct {
-	data map[string]interface{}
-	*sync.RWMutex
+	shards []*shard
 	// @see http://stackoverflow.com/a/19168242/721525
 	// @see https://medium.com/@deckarep/dancing-with-go-s-mutexes-92407ae927bf
+	expiry      *expiry
+	counters    *counters
+	appendLog   *appendLog
+	replication *replication
+	pubsub      *pubsub
+	watchers    *watchers
+	// Limits of the whole cache, victims are chosen across shards
+	eviction *evictor
+}
+
+// Config Cache allocation options, zero value means unbounded cache
+type Config struct {
+	// Maximum number of keys, 0 - unlimited
+	MaxEntries int
+	// Approximate memory budget for keys and values in bytes, 0 - unlimited
+	MaxBytes int64
+	// Eviction policy constructor, e.g. NewLFU, LRU if nil
+	EvictionPolicy func() EvictionPolicy
+	// Period of the background expiry sweep, 100ms if 0
+	SweepInterval time.Duration
+	// Number of independently locked shards, 16 if 0
+	Shards int
+}
+
+// Stored value with its bookkeeping
+type entry struct {
+	value interface{}
+	size  int64
+	// Absolute deadline in unix nanoseconds, 0 - no TTL
+	expireAt int64
+	// Grows on every write of the value, TTL changes keep it
+	version uint64
+}
+
+// Value type name used in stats
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case int:
+		return "int"
+	case string:
+		return "string"
+	case util.List:
+		return "list"
+	case util.Dict:
+		return "dict"
+	case util.Set:
+		return "set"
+	case *sortedSet:
+		return "zset"
+	default:
+		return "unknown"
+	}
 }
 
 func Alloc() CACHE {
+	return AllocWithConfig(Config{})
+}
+
+// Allocate cache bounded by config limits
+// Keys chosen by the eviction policy are removed when a limit is exceeded
+func AllocWithConfig(config Config) CACHE {
+	n := config.Shards
+	if n <= 0 {
+		n = defaultShards
+	}
+
 	cache := CACHE{
-		data:    map[string]interface{}{},
-		RWMutex: new(sync.RWMutex),
+		shards:      make([]*shard, n),
+		expiry:      newExpiry(config),
+		counters:    &counters{},
+		appendLog:   &appendLog{},
+		replication: &replication{},
+		pubsub:      newPubSub(),
+		watchers:    &watchers{},
+		eviction:    newEvictor(config),
+	}
+
+	for i := range cache.shards {
+		cache.shards[i] = newShard(&cache)
 	}
+	cache.expiry.shards = cache.shards
+	cache.appendLog.shards = cache.shards
 
 	return cache
 }
 
 func (cache *CACHE) Len() int {
-	cache.RLock()
-	defer cache.RUnlock()
+	cache.rlockAll()
+	defer cache.runlockAll()
+
+	result := 0
+	for _, s := range cache.shards {
+		result += len(s.data)
+	}
 
-	return len(cache.data)
+	return result
 }
 
 func (cache *CACHE) Keys() []string {
-	cache.RLock()
-	defer cache.RUnlock()
+	cache.rlockAll()
+	defer cache.runlockAll()
 
-	result := make([]string, 0, len(cache.data))
-	for key := range cache.data {
-		result = append(result, key)
+	size := 0
+	for _, s := range cache.shards {
+		size += len(s.data)
+	}
+
+	at := now()
+	result := make([]string, 0, size)
+	for _, s := range cache.shards {
+		for key, e := range s.data {
+			if !e.expired(at) {
+				result = append(result, key)
+			}
+		}
 	}
 
 	return result
@@ -58,12 +147,39 @@ func (cache *CACHE) Stats() util.Stats {
 		MemoryFrees:       memStats.Frees,
 		GCPauseTotalNs:    memStats.PauseTotalNs,
 		NumGC:             memStats.NumGC,
+		EvictionPolicy:    cache.eviction.name(),
+		KeysByType:        map[string]int{},
+	}
+
+	cache.counters.fill(&stats)
+
+	cache.rlockAll()
+	defer cache.runlockAll()
+
+	stats.UsedBytes = cache.eviction.usedBytes()
+	for _, s := range cache.shards {
+		stats.Keys += len(s.data)
+		for name, n := range s.types {
+			if n > 0 {
+				stats.KeysByType[name] += n
+			}
+		}
 	}
 
 	return stats
 }
 
+// Set TTL (time-to-live) in milliseconds, 0 is ignored
 func (cache *CACHE) SetTTL(key string, ttl int) error {
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.setTTL(key, ttl)
+}
+
+// Caller must hold the write lock
+func (s *shard) setTTL(key string, ttl int) error {
 	if ttl < 0 {
 		return util.ErrorInvalidTTLValue
 	}
@@ -72,11 +188,104 @@ func (cache *CACHE) SetTTL(key string, ttl int) error {
 		return nil
 	}
 
-	time.AfterFunc(time.Millisecond*time.Duration(ttl), func() {
-		cache.Lock()
-		delete(cache.data, key)
-		cache.Unlock()
-	})
+	e, ok := s.lookup(key)
+	if !ok {
+		return util.ErrorKeyNotFound
+	}
+
+	expireAt := now() + int64(time.Millisecond)*int64(ttl)
+	s.setExpiry(key, e, expireAt)
+	s.record("expireat", key, nil, expireAt)
+
+	return nil
+}
+
+// Absolute deadline for an optional TTL in milliseconds, 0 - no TTL
+func deadlineAfter(ttl []int) (int64, error) {
+	if len(ttl) == 0 || ttl[0] == 0 {
+		return 0, nil
+	}
+
+	if ttl[0] < 0 {
+		return 0, util.ErrorInvalidTTLValue
+	}
+
+	return now() + int64(time.Millisecond)*int64(ttl[0]), nil
+}
+
+// Get remaining TTL in milliseconds, -1 if key has no TTL
+func (cache *CACHE) GetTTL(key string) (int, error) {
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	e, ok := shard.lookup(key)
+	if !ok {
+		return 0, util.ErrorKeyNotFound
+	}
+
+	if e.expireAt == 0 {
+		return -1, nil
+	}
+
+	remaining := e.expireAt - now()
+	ms := int64(time.Millisecond)
+
+	return int((remaining + ms - 1) / ms), nil
+}
+
+// Remove TTL, returns false if key had no TTL
+func (cache *CACHE) Persist(key string) (bool, error) {
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.persist(key)
+}
+
+// Caller must hold the write lock
+func (s *shard) persist(key string) (bool, error) {
+	e, ok := s.lookup(key)
+	if !ok {
+		return false, util.ErrorKeyNotFound
+	}
+
+	if e.expireAt == 0 {
+		return false, nil
+	}
+
+	s.setExpiry(key, e, 0)
+	s.record("persist", key, nil, 0)
+
+	return true, nil
+}
+
+// Expire key at unix timestamp in milliseconds
+// Key is removed right away if the timestamp is in the past
+func (cache *CACHE) ExpireAt(key string, timestamp int) error {
+	if timestamp < 0 {
+		return util.ErrorInvalidTTLValue
+	}
+
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	e, ok := shard.lookup(key)
+	if !ok {
+		return util.ErrorKeyNotFound
+	}
+
+	expireAt := int64(time.Millisecond) * int64(timestamp)
+	if expireAt <= now() {
+		shard.delete(key, util.EventExpire)
+		cache.counters.expire()
+		shard.record("del", key, nil, 0)
+		return nil
+	}
+
+	shard.setExpiry(key, e, expireAt)
+	shard.record("expireat", key, nil, expireAt)
 
 	return nil
 }
@@ -12,7 +12,7 @@ func TestAlloc(t *testing.T) {
 	t.Log("Testing Alloc method...")
 
 	cache := Alloc()
-	if cache.data == nil {
+	if len(cache.shards) == 0 {
 		t.Errorf("Allocation failed.")
 	}
 }
@@ -87,14 +87,14 @@ func TestStats(t *testing.T) {
 		t.Error(err)
 	}
 
-	m := make(map[string]int64)
+	m := make(map[string]interface{})
 	err = json.Unmarshal(stats, &m)
 	if err != nil {
 		t.Error(err)
 	}
 
-	if m["num_gc"] != 0 {
-		t.Errorf("Expected %s, but it was %d instead.", "0", m["num_gc"])
+	if m["num_gc"] != float64(0) {
+		t.Errorf("Expected %s, but it was %v instead.", "0", m["num_gc"])
 	}
 }
 
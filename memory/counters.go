@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"sync/atomic"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Operation counters shared by all shards, updated atomically
+type counters struct {
+	gets        uint64
+	hits        uint64
+	misses      uint64
+	sets        uint64
+	deletes     uint64
+	expirations uint64
+	evictions   uint64
+	wrongType   uint64
+}
+
+// Count a read of a value, returns ErrorKeyNotFound on miss
+func (c *counters) read(hit bool) error {
+	atomic.AddUint64(&c.gets, 1)
+
+	if !hit {
+		atomic.AddUint64(&c.misses, 1)
+		return util.ErrorKeyNotFound
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	return nil
+}
+
+// Count a wrong type access, returns ErrorWrongType
+func (c *counters) wrongTypeError() error {
+	atomic.AddUint64(&c.wrongType, 1)
+
+	return util.ErrorWrongType
+}
+
+func (c *counters) set() {
+	atomic.AddUint64(&c.sets, 1)
+}
+
+func (c *counters) delete() {
+	atomic.AddUint64(&c.deletes, 1)
+}
+
+func (c *counters) expire() {
+	atomic.AddUint64(&c.expirations, 1)
+}
+
+func (c *counters) evict() {
+	atomic.AddUint64(&c.evictions, 1)
+}
+
+// Copy counters into stats
+func (c *counters) fill(stats *util.Stats) {
+	stats.Gets = atomic.LoadUint64(&c.gets)
+	stats.Hits = atomic.LoadUint64(&c.hits)
+	stats.Misses = atomic.LoadUint64(&c.misses)
+	stats.Sets = atomic.LoadUint64(&c.sets)
+	stats.Deletes = atomic.LoadUint64(&c.deletes)
+	stats.Expirations = atomic.LoadUint64(&c.expirations)
+	stats.Evictions = atomic.LoadUint64(&c.evictions)
+	stats.WrongType = atomic.LoadUint64(&c.wrongType)
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestStatsCounters(t *testing.T) {
+	t.Log("Testing Stats counters...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 3})
+	defer cache.Close()
+
+	cache.SetString("stringTest", "hi alex")
+	cache.SetInt("intTest", 123)
+	cache.SetList("listTest", util.List{"one"})
+	cache.SetDict("dictTest", util.Dict{"k1": "v1"})
+
+	cache.Get("listTest")
+	cache.Get("stringTest")
+	cache.GetDictElement("dictTest", "k1")
+	cache.GetListElement("dictTest", 0)
+	cache.Increment("listTest")
+	cache.UpdateInt("dictTest", 1)
+
+	cache.Remove("listTest")
+	cache.Remove("missing")
+
+	cache.SetInt("volatile", 1, 1)
+	time.Sleep(time.Millisecond * 5)
+	cache.SetInt("volatile", 2)
+
+	stats := cache.Stats()
+
+	expected := map[string]uint64{
+		"gets":        4,
+		"hits":        3,
+		"misses":      1,
+		"sets":        6,
+		"deletes":     1,
+		"expirations": 1,
+		"evictions":   1,
+		"wrong_type":  3,
+	}
+	actual := map[string]uint64{
+		"gets":        stats.Gets,
+		"hits":        stats.Hits,
+		"misses":      stats.Misses,
+		"sets":        stats.Sets,
+		"deletes":     stats.Deletes,
+		"expirations": stats.Expirations,
+		"evictions":   stats.Evictions,
+		"wrong_type":  stats.WrongType,
+	}
+
+	for name, v := range expected {
+		if actual[name] != v {
+			t.Errorf("Expected %s %d, but it was %d instead.", name, v, actual[name])
+		}
+	}
+
+	if stats.Keys != 3 {
+		t.Errorf("Expected 3 keys, but it was %d instead.", stats.Keys)
+	}
+
+	if stats.KeysByType["dict"] != 1 || stats.KeysByType["int"] != 2 || len(stats.KeysByType) != 2 {
+		t.Errorf("Expected one dict and two ints, but it was %v instead.", stats.KeysByType)
+	}
+
+	if stats.UsedBytes <= 0 {
+		t.Errorf("Expected used bytes, but it was %d instead.", stats.UsedBytes)
+	}
+}
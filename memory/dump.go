@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Serialized key with its value and TTL, to be restored on another cache
+func (cache *CACHE) Dump(key string) ([]byte, error) {
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	// Not counted as a read, dumps are made by migrations
+	e, success := shard.lookup(key)
+	if !success {
+		return nil, util.ErrorKeyNotFound
+	}
+
+	return encodeOp("set", key, e.value, e.expireAt)
+}
+
+// Store key dumped by Dump, replacing the current value
+func (cache *CACHE) Restore(data []byte) error {
+	var op appendOp
+	if err := json.Unmarshal(data, &op); err != nil || op.Op != "set" {
+		return util.ErrorInvalidDump
+	}
+
+	if err := cache.apply(op); err != nil {
+		return util.ErrorInvalidDump
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestDumpRestore(t *testing.T) {
+	t.Log("Testing dump and restore of keys...")
+
+	source := Alloc()
+	writeAllOps(&source)
+
+	target := Alloc()
+	target.SetString("string", "overwritten")
+	for _, key := range source.Keys() {
+		data, err := source.Dump(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := target.Restore(data); err != nil {
+			t.Error(err)
+		}
+	}
+
+	checkSameContent(t, &source, &target)
+
+	ttl, err := target.GetTTL("int")
+	if err != nil || ttl <= 0 || ttl > 60000 {
+		t.Errorf("Expected ttl of 'int' to be restored, but it was %d (%v) instead.", ttl, err)
+	}
+
+	if _, err := source.Dump("missing"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorKeyNotFound, err)
+	}
+	for _, data := range []string{"", "{", `{"op":"del","key":"string"}`, `{"op":"set","key":"k","type":"bad"}`} {
+		if err := target.Restore([]byte(data)); err != util.ErrorInvalidDump {
+			t.Errorf("Expected %v for %q, but it was %v instead.", util.ErrorInvalidDump, data, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Events buffered for a watcher, it is dropped when it falls further behind
+const watcherBuffer = 1024
+
+// Watchers of keyspace events
+type watchers struct {
+	// Number of open watchers, writers skip events when there are none
+	count int32
+	sync.Mutex
+	open map[*Watcher]bool
+}
+
+// Watcher Keyspace events of keys with a prefix, in the order of writes to each key
+// Writes of a transaction are reported once it commits
+type Watcher struct {
+	watchers *watchers
+	prefix   string
+	events   chan util.Event
+	// Guarded by the watchers lock
+	closed bool
+	err    error
+}
+
+// Watch sets, updates, removals, expirations and evictions of keys starting with prefix
+// Empty prefix watches all keys
+func (cache *CACHE) Watch(prefix string) *Watcher {
+	w := &Watcher{
+		watchers: cache.watchers,
+		prefix:   prefix,
+		events:   make(chan util.Event, watcherBuffer),
+	}
+
+	ws := cache.watchers
+	ws.Lock()
+	defer ws.Unlock()
+
+	if ws.open == nil {
+		ws.open = map[*Watcher]bool{}
+	}
+	ws.open[w] = true
+	atomic.AddInt32(&ws.count, 1)
+
+	return w
+}
+
+// Channel of events, closed by Close or when the watcher falls behind
+func (w *Watcher) Events() <-chan util.Event {
+	return w.events
+}
+
+// Reason the watcher was dropped, nil if it is open or closed by Close
+func (w *Watcher) Err() error {
+	w.watchers.Lock()
+	defer w.watchers.Unlock()
+
+	return w.err
+}
+
+// Stop watching and close the channel of events
+func (w *Watcher) Close() {
+	w.watchers.Lock()
+	defer w.watchers.Unlock()
+
+	w.close(nil)
+}
+
+// Caller must hold the watchers lock
+func (w *Watcher) close(err error) {
+	if w.closed {
+		return
+	}
+
+	delete(w.watchers.open, w)
+	atomic.AddInt32(&w.watchers.count, -1)
+	w.closed = true
+	w.err = err
+	close(w.events)
+}
+
+// Report change of key to watchers
+// Caller must hold the write lock
+func (s *shard) notify(event, key string, value interface{}) {
+	if atomic.LoadInt32(&s.watchers.count) == 0 {
+		return
+	}
+
+	e := util.Event{Type: event, Key: key, ValueType: typeName(value)}
+
+	// Transaction events are reported when it commits
+	if s.tx != nil {
+		s.tx.events = append(s.tx.events, e)
+		return
+	}
+
+	s.watchers.publish(e)
+}
+
+// Queue event for matching watchers without blocking the writer, slow watchers are dropped
+func (ws *watchers) publish(e util.Event) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	for w := range ws.open {
+		if !strings.HasPrefix(e.Key, w.prefix) {
+			continue
+		}
+
+		select {
+		case w.events <- e:
+		default:
+			w.close(util.ErrorWatcherTooSlow)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func nextEvent(t *testing.T, w *Watcher) util.Event {
+	select {
+	case e := <-w.Events():
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected event, but got nothing.")
+		return util.Event{}
+	}
+}
+
+func expectEvents(t *testing.T, w *Watcher, expected ...util.Event) {
+	for _, e := range expected {
+		if actual := nextEvent(t, w); actual != e {
+			t.Errorf("Expected %v, but it was %v instead.", e, actual)
+		}
+	}
+
+	if len(w.Events()) != 0 {
+		t.Errorf("Expected no more events, but it was %v instead.", <-w.Events())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Log("Testing keyspace events...")
+
+	cache := AllocWithConfig(Config{SweepInterval: 10 * time.Millisecond})
+	defer cache.Close()
+
+	w := cache.Watch("user:")
+	defer w.Close()
+
+	cache.SetString("user:1", "alex")
+	cache.UpdateString("user:1", "alexey")
+	cache.SetString("other", "v")
+	cache.SAdd("user:2", "a")
+	cache.SRem("user:2", "a")
+	cache.Remove("user:1")
+	cache.SetInt("user:3", 1, 1)
+
+	expectEvents(t, w,
+		util.Event{Type: util.EventSet, Key: "user:1", ValueType: "string"},
+		util.Event{Type: util.EventUpdate, Key: "user:1", ValueType: "string"},
+		util.Event{Type: util.EventSet, Key: "user:2", ValueType: "set"},
+		util.Event{Type: util.EventRemove, Key: "user:2", ValueType: "set"},
+		util.Event{Type: util.EventRemove, Key: "user:1", ValueType: "string"},
+		util.Event{Type: util.EventSet, Key: "user:3", ValueType: "int"},
+	)
+
+	if e := nextEvent(t, w); e != (util.Event{Type: util.EventExpire, Key: "user:3", ValueType: "int"}) {
+		t.Errorf("Expected expire event, but it was %v instead.", e)
+	}
+
+}
+
+func TestWatchEvict(t *testing.T) {
+	t.Log("Testing keyspace events of evictions...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 2})
+	cache.SetString("k1", "v")
+	cache.SetString("k2", "v")
+
+	w := cache.Watch("")
+	defer w.Close()
+
+	cache.SetString("k3", "v")
+	expectEvents(t, w,
+		util.Event{Type: util.EventSet, Key: "k3", ValueType: "string"},
+		util.Event{Type: util.EventEvict, Key: "k1", ValueType: "string"},
+	)
+}
+
+func TestWatchNoop(t *testing.T) {
+	t.Log("Testing writes changing nothing are not reported...")
+
+	cache := Alloc()
+	cache.SetList("list", util.List{"a"})
+	cache.SetDict("dict", util.Dict{"k1": "v"})
+	cache.SAdd("set", "a")
+	cache.ZAdd("zset", util.ZMember{Member: "a", Score: 1})
+
+	w := cache.Watch("")
+	defer w.Close()
+	sets := cache.Stats().Sets
+	versions := map[string]uint64{}
+	for _, key := range []string{"list", "dict", "set"} {
+		versions[key], _ = cache.Version(key)
+	}
+
+	cache.RemoveFromList("list", "b")
+	cache.SAdd("set", "a")
+	cache.SRem("set", "b")
+	cache.RemoveFromDict("dict", "k2")
+	cache.ZRem("zset", "b")
+	cache.ZRemRangeByScore("zset", 5, 10)
+	cache.ZAdd("zset", util.ZMember{Member: "a", Score: 1})
+	cache.ZAdd("zset", util.ZMember{Member: "a", Score: 2})
+
+	expectEvents(t, w,
+		util.Event{Type: util.EventUpdate, Key: "zset", ValueType: "zset"},
+	)
+	if cache.Stats().Sets != sets+1 {
+		t.Errorf("Expected %d sets, but it was %d instead.", sets+1, cache.Stats().Sets)
+	}
+	for key, version := range versions {
+		if v, _ := cache.Version(key); v != version {
+			t.Errorf("Expected version %d of '%s', but it was %d instead.", version, key, v)
+		}
+	}
+}
+
+func TestWatchTx(t *testing.T) {
+	t.Log("Testing keyspace events of transactions...")
+
+	cache := Alloc()
+	cache.SetInt("counter", 1)
+
+	w := cache.Watch("")
+	defer w.Close()
+
+	cache.Multi().Increment("counter").SetString("s", "v").Increment("s").Exec()
+	cache.Multi().Increment("counter").SetString("s", "v").Exec()
+
+	expectEvents(t, w,
+		util.Event{Type: util.EventUpdate, Key: "counter", ValueType: "int"},
+		util.Event{Type: util.EventSet, Key: "s", ValueType: "string"},
+	)
+}
+
+func TestWatchSlow(t *testing.T) {
+	t.Log("Testing slow watcher is dropped...")
+
+	cache := Alloc()
+	w := cache.Watch("")
+
+	for i := 0; i < watcherBuffer+1; i++ {
+		cache.SetInt("key"+strconv.Itoa(i), i)
+	}
+
+	n := 0
+	for range w.Events() {
+		n++
+	}
+	if n != watcherBuffer {
+		t.Errorf("Expected %d buffered events, but it was %d instead.", watcherBuffer, n)
+	}
+	if err := w.Err(); err != util.ErrorWatcherTooSlow {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorWatcherTooSlow, err)
+	}
+
+	// Writes go on without watchers
+	w.Close()
+	cache.SetInt("key", 1)
+}
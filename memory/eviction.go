@@ -0,0 +1,191 @@
+package memory
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/anevsky/cachego/util"
+)
+
+const (
+	// Approximate per key overhead: map bucket slot, entry and bookkeeping
+	entryOverhead = 64
+	// Size of a string header
+	stringOverhead = 16
+	// Policy name of an unbounded cache
+	noEviction = "none"
+)
+
+// EvictionPolicy Chooses keys to evict when the cache is over its limits
+// Calls are serialized by the cache, implementations don't need to be thread-safe
+type EvictionPolicy interface {
+	// Policy name shown in Stats()
+	Name() string
+	// Key was stored for the first time
+	Add(key string)
+	// Key was read or overwritten
+	Access(key string)
+	// Key was removed from the cache
+	Remove(key string)
+	// Forget and return the key to evict next, except the given one
+	Evict(except string) (string, bool)
+}
+
+// VolatilePolicy Eviction policy which is told when keys get or lose a TTL
+type VolatilePolicy interface {
+	EvictionPolicy
+	SetVolatile(key string, volatile bool)
+}
+
+// Keeps the cache within its limits, shared by all shards
+// Policy is guarded by its own mutex, so readers holding
+// a shard read lock can still mark keys as used
+type evictor struct {
+	// Updated atomically by shards holding their write lock, first for 64-bit alignment
+	entries int64
+	bytes   int64
+	sync.Mutex
+	maxEntries int64
+	maxBytes   int64
+	// nil for unbounded cache
+	policy EvictionPolicy
+}
+
+func newEvictor(config Config) *evictor {
+	ev := &evictor{
+		maxEntries: int64(config.MaxEntries),
+		maxBytes:   config.MaxBytes,
+	}
+
+	if ev.maxEntries > 0 || ev.maxBytes > 0 {
+		if config.EvictionPolicy != nil {
+			ev.policy = config.EvictionPolicy()
+		} else {
+			ev.policy = NewLRU()
+		}
+	}
+
+	return ev
+}
+
+func (ev *evictor) bounded() bool {
+	return ev.policy != nil
+}
+
+func (ev *evictor) name() string {
+	if !ev.bounded() {
+		return noEviction
+	}
+
+	return ev.policy.Name()
+}
+
+// Account for keys and bytes added, negative when removed
+func (ev *evictor) resize(entries, bytes int64) {
+	atomic.AddInt64(&ev.entries, entries)
+	atomic.AddInt64(&ev.bytes, bytes)
+}
+
+func (ev *evictor) usedBytes() int64 {
+	return atomic.LoadInt64(&ev.bytes)
+}
+
+func (ev *evictor) overLimit() bool {
+	if ev.maxEntries > 0 && atomic.LoadInt64(&ev.entries) > ev.maxEntries {
+		return true
+	}
+
+	return ev.maxBytes > 0 && atomic.LoadInt64(&ev.bytes) > ev.maxBytes
+}
+
+func (ev *evictor) add(key string) {
+	if !ev.bounded() {
+		return
+	}
+
+	ev.Lock()
+	defer ev.Unlock()
+
+	ev.policy.Add(key)
+}
+
+func (ev *evictor) access(key string) {
+	if !ev.bounded() {
+		return
+	}
+
+	ev.Lock()
+	defer ev.Unlock()
+
+	ev.policy.Access(key)
+}
+
+func (ev *evictor) remove(key string) {
+	if !ev.bounded() {
+		return
+	}
+
+	ev.Lock()
+	defer ev.Unlock()
+
+	ev.policy.Remove(key)
+}
+
+func (ev *evictor) setVolatile(key string, volatile bool) {
+	if !ev.bounded() {
+		return
+	}
+
+	ev.Lock()
+	defer ev.Unlock()
+
+	if policy, ok := ev.policy.(VolatilePolicy); ok {
+		policy.SetVolatile(key, volatile)
+	}
+}
+
+func (ev *evictor) evict(except string) (string, bool) {
+	if !ev.bounded() {
+		return "", false
+	}
+
+	ev.Lock()
+	defer ev.Unlock()
+
+	return ev.policy.Evict(except)
+}
+
+// Approximate size in bytes of key and value
+func sizeOf(key string, value interface{}) int64 {
+	size := int64(entryOverhead + len(key))
+
+	switch v := value.(type) {
+	case int:
+		size += 8
+	case string:
+		size += int64(len(v))
+	case util.List:
+		for _, element := range v {
+			size += int64(stringOverhead + len(element))
+		}
+	case util.Dict:
+		for k, element := range v {
+			size += int64(2*stringOverhead + len(k) + len(element))
+		}
+	case util.Set:
+		for member := range v {
+			size += memberSize(member)
+		}
+	case *sortedSet:
+		for member := range v.scores {
+			size += zmemberSize(member)
+		}
+	}
+
+	return size
+}
+
+// Approximate size in bytes of a set member
+func memberSize(member string) int64 {
+	return int64(stringOverhead + len(member))
+}
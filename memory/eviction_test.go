@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestEvictMaxEntries(t *testing.T) {
+	t.Log("Testing LRU eviction by entries...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 2})
+
+	cache.SetString("k1", "v1")
+	cache.SetString("k2", "v2")
+	cache.SetString("k3", "v3")
+
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2, but it was %d instead.", cache.Len())
+	}
+
+	_, err := cache.Get("k1")
+	if err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	t.Log("Testing LRU eviction order...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 3})
+
+	cache.SetString("k1", "v1")
+	cache.SetList("k2", util.List{"one", "two"})
+	cache.SetDict("k3", util.Dict{"a": "b"})
+
+	cache.Get("k1")
+	cache.GetListElement("k2", 0)
+	cache.GetDictElement("k3", "a")
+	cache.Get("k1")
+
+	cache.SetInt("k4", 1)
+
+	if _, err := cache.Get("k2"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	for _, key := range []string{"k1", "k3", "k4"} {
+		if _, err := cache.Get(key); err != nil {
+			t.Errorf("Expected key '%s' to stay, but it was %v instead.", key, err)
+		}
+	}
+}
+
+func TestEvictOnAppendToList(t *testing.T) {
+	t.Log("Testing LRU eviction by bytes...")
+
+	cache := AllocWithConfig(Config{MaxBytes: 1080})
+
+	cache.SetString("k1", "v1")
+	cache.SetList("listTest", util.List{})
+	for i := 0; i < 20; i++ {
+		cache.AppendToList("listTest", strings.Repeat("x", 32))
+	}
+
+	if _, err := cache.Get("k1"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	if cache.eviction.bytes > 1080 {
+		t.Errorf("Expected at most 1080 bytes, but it was %d instead.", cache.eviction.bytes)
+	}
+}
+
+func TestEvictKeepsLastWrittenKey(t *testing.T) {
+	t.Log("Testing LRU eviction of an oversized value...")
+
+	cache := AllocWithConfig(Config{MaxBytes: 128})
+
+	cache.SetString("k1", "v1")
+	cache.SetString("big", strings.Repeat("x", 256))
+
+	if cache.Len() != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", cache.Len())
+	}
+
+	if _, err := cache.Get("big"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnboundedAlloc(t *testing.T) {
+	t.Log("Testing unbounded cache...")
+
+	cache := Alloc()
+
+	cache.SetString("stringTest", "hi alex")
+	cache.UpdateString("stringTest", "hi")
+	cache.Remove("stringTest")
+
+	if cache.eviction.bytes != 0 {
+		t.Errorf("Expected 0 bytes, but it was %d instead.", cache.eviction.bytes)
+	}
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+const (
+	// Default period of the background expiry sweep
+	defaultSweepInterval = 100 * time.Millisecond
+	// Maximum number of keys expired under one lock acquisition
+	sweepBatch = 1000
+	// Stale deadlines tolerated before the queue is rebuilt
+	deadlinesSlack = 1024
+)
+
+// Central expiry subsystem
+// Deadlines are stored with entries and checked lazily on read,
+// a background sweeper removes expired keys nobody reads anymore.
+// Overwritten or removed deadlines stay in the queue and are skipped
+// when popped, so a queue per shard serves any number of keys without
+// timers, and one sweeper serves all shards.
+type expiry struct {
+	shards   []*shard
+	interval time.Duration
+	start    sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type deadline struct {
+	at  int64
+	key string
+}
+
+// Min-heap of deadlines
+type deadlineQueue []deadline
+
+func (q deadlineQueue) Len() int            { return len(q) }
+func (q deadlineQueue) Less(i, j int) bool  { return q[i].at < q[j].at }
+func (q deadlineQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *deadlineQueue) Push(x interface{}) { *q = append(*q, x.(deadline)) }
+func (q *deadlineQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+func newExpiry(config Config) *expiry {
+	interval := config.SweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+
+	return &expiry{
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Current time in expiry units
+func now() int64 {
+	return time.Now().UnixNano()
+}
+
+func (e *entry) expired(at int64) bool {
+	return e.expireAt != 0 && e.expireAt <= at
+}
+
+// Set or clear (0) the absolute deadline of a stored entry
+// Caller must hold the write lock
+func (s *shard) setExpiry(key string, e *entry, expireAt int64) {
+	e.expireAt = expireAt
+	s.eviction.setVolatile(key, expireAt != 0)
+
+	if expireAt == 0 {
+		return
+	}
+
+	if len(s.deadlines) > 2*len(s.data)+deadlinesSlack {
+		s.rebuildDeadlines()
+	}
+
+	heap.Push(&s.deadlines, deadline{at: expireAt, key: key})
+	s.expiry.start.Do(func() {
+		go s.expiry.sweep()
+	})
+}
+
+// Drop stale deadlines of overwritten and removed keys
+// Caller must hold the write lock
+func (s *shard) rebuildDeadlines() {
+	deadlines := deadlineQueue{}
+	for key, e := range s.data {
+		if e.expireAt != 0 {
+			deadlines = append(deadlines, deadline{at: e.expireAt, key: key})
+		}
+	}
+
+	heap.Init(&deadlines)
+	s.deadlines = deadlines
+}
+
+// Background sweeper, runs until the cache is closed
+func (ex *expiry) sweep() {
+	ticker := time.NewTicker(ex.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ex.stop:
+			return
+		case <-ticker.C:
+			for _, s := range ex.shards {
+				for s.expireBatch() {
+				}
+			}
+		}
+	}
+}
+
+// Remove a batch of expired keys, returns true if more are due
+func (s *shard) expireBatch() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	at := now()
+	for i := 0; i < sweepBatch; i++ {
+		if s.deadlines.Len() == 0 || s.deadlines[0].at > at {
+			return false
+		}
+
+		item := heap.Pop(&s.deadlines).(deadline)
+		if e, ok := s.data[item.key]; ok && e.expireAt == item.at {
+			s.delete(item.key, util.EventExpire)
+			s.counters.expire()
+		}
+	}
+
+	return true
+}
+
+// Stop the background expiry sweeper and close the append-only log
+// Expired keys are still hidden from readers afterwards
+func (cache *CACHE) Close() {
+	cache.expiry.stopOnce.Do(func() {
+		close(cache.expiry.stop)
+	})
+	cache.CloseAppendLog()
+}
@@ -0,0 +1,253 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestSetTTLTwice(t *testing.T) {
+	t.Log("Testing TTL overwrite...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	cache.SetInt("intTest", 123)
+	cache.SetTTL("intTest", 50)
+	cache.SetTTL("intTest", 500)
+	time.Sleep(time.Millisecond * 100)
+
+	if _, err := cache.Get("intTest"); err != nil {
+		t.Errorf("Expected nil error, but it was %v instead.", err)
+	}
+}
+
+func TestSetTTLRemoveAndSet(t *testing.T) {
+	t.Log("Testing TTL of a removed key...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	cache.SetInt("intTest", 123)
+	cache.SetTTL("intTest", 50)
+	cache.Remove("intTest")
+	cache.SetInt("intTest", 321)
+	time.Sleep(time.Millisecond * 100)
+
+	v, err := cache.Get("intTest")
+	if err != nil {
+		t.Errorf("Expected nil error, but it was %v instead.", err)
+	}
+	if v != 321 {
+		t.Errorf("Expected 321, but it was %v instead.", v)
+	}
+
+	cache.SetTTL("intTest", 50)
+	cache.SetString("intTest", "overwritten")
+	time.Sleep(time.Millisecond * 100)
+
+	if _, err := cache.Get("intTest"); err != nil {
+		t.Errorf("Expected nil error, but it was %v instead.", err)
+	}
+}
+
+func TestSetTTLKeepsOnUpdate(t *testing.T) {
+	t.Log("Testing TTL of an updated key...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	cache.SetInt("intTest", 123)
+	cache.SetTTL("intTest", 50)
+	cache.Increment("intTest")
+	time.Sleep(time.Millisecond * 70)
+
+	if _, err := cache.Get("intTest"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	if _, err := cache.Increment("intTest"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+}
+
+func TestSetTTLMissingKey(t *testing.T) {
+	t.Log("Testing TTL of a missing key...")
+
+	cache := Alloc()
+
+	if err := cache.SetTTL("intTest", 50); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	t.Log("Testing expiry sweep...")
+
+	cache := AllocWithConfig(Config{SweepInterval: 10 * time.Millisecond})
+	defer cache.Close()
+
+	for i := 0; i < 3000; i++ {
+		key := string(rune(i))
+		cache.SetInt(key, i)
+		cache.SetTTL(key, 20)
+	}
+	cache.SetInt("persistent", 1)
+
+	time.Sleep(time.Millisecond * 100)
+
+	n := cache.Len()
+
+	if n != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", n)
+	}
+}
+
+func TestRebuildDeadlines(t *testing.T) {
+	t.Log("Testing stale deadlines cleanup...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	cache.SetInt("intTest", 1)
+	for i := 0; i < 10000; i++ {
+		cache.SetTTL("intTest", 60000)
+	}
+
+	s := cache.shard("intTest")
+	s.RLock()
+	n := len(s.deadlines)
+	s.RUnlock()
+
+	if n > 2+deadlinesSlack {
+		t.Errorf("Expected at most %d deadlines, but it was %d instead.", 2+deadlinesSlack, n)
+	}
+}
+
+func TestGetTTL(t *testing.T) {
+	t.Log("Testing GetTTL method...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	_, err := cache.GetTTL("intTest")
+	if err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	cache.SetInt("intTest", 123)
+	v, err := cache.GetTTL("intTest")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != -1 {
+		t.Errorf("Expected -1, but it was %d instead.", v)
+	}
+
+	cache.SetTTL("intTest", 5000)
+	v, err = cache.GetTTL("intTest")
+	if err != nil {
+		t.Error(err)
+	}
+	if v <= 4900 || v > 5000 {
+		t.Errorf("Expected about 5000, but it was %d instead.", v)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	t.Log("Testing Persist method...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	cache.SetInt("intTest", 123)
+	v, err := cache.Persist("intTest")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != false {
+		t.Errorf("Expected %t, but it was %t instead.", false, v)
+	}
+
+	cache.SetTTL("intTest", 50)
+	v, err = cache.Persist("intTest")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != true {
+		t.Errorf("Expected %t, but it was %t instead.", true, v)
+	}
+
+	time.Sleep(time.Millisecond * 70)
+	if _, err := cache.Get("intTest"); err != nil {
+		t.Errorf("Expected nil error, but it was %v instead.", err)
+	}
+}
+
+func TestExpireAt(t *testing.T) {
+	t.Log("Testing ExpireAt method...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	cache.SetInt("intTest", 123)
+	timestamp := int(time.Now().Add(time.Millisecond*50).UnixNano() / int64(time.Millisecond))
+	if err := cache.ExpireAt("intTest", timestamp); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := cache.Get("intTest"); err != nil {
+		t.Errorf("Expected nil error, but it was %v instead.", err)
+	}
+
+	time.Sleep(time.Millisecond * 70)
+	if _, err := cache.Get("intTest"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	cache.SetInt("intTest", 123)
+	cache.ExpireAt("intTest", 1)
+	if _, err := cache.Get("intTest"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	if err := cache.ExpireAt("intTest", -1); err != util.ErrorInvalidTTLValue {
+		t.Errorf("Expected ErrorInvalidTTLValue, but it was %v instead.", err)
+	}
+}
+
+func TestSetWithTTL(t *testing.T) {
+	t.Log("Testing Set methods with TTL...")
+
+	cache := Alloc()
+	defer cache.Close()
+
+	cache.SetString("stringTest", "hi alex", 50)
+	cache.SetInt("intTest", 123, 50)
+	cache.SetList("listTest", util.List{"one"}, 50)
+	cache.SetDict("dictTest", util.Dict{"k1": "v1"}, 50)
+
+	v, err := cache.GetTTL("listTest")
+	if err != nil {
+		t.Error(err)
+	}
+	if v <= 0 || v > 50 {
+		t.Errorf("Expected about 50, but it was %d instead.", v)
+	}
+
+	time.Sleep(time.Millisecond * 70)
+
+	for _, key := range []string{"stringTest", "intTest", "listTest", "dictTest"} {
+		if _, err := cache.Get(key); err != util.ErrorKeyNotFound {
+			t.Errorf("Expected ErrorKeyNotFound for '%s', but it was %v instead.", key, err)
+		}
+	}
+
+	if err := cache.SetString("stringTest", "hi alex", -1); err != util.ErrorInvalidTTLValue {
+		t.Errorf("Expected ErrorInvalidTTLValue, but it was %v instead.", err)
+	}
+	if _, err := cache.Get("stringTest"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+}
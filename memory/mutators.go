@@ -4,190 +4,563 @@ import (
 	"github.com/anevsky/cachego/util"
 )
 
-func (cache *CACHE) SetString(key, value string) error {
-	cache.Lock()
-	defer cache.Unlock()
+// Set string, optional TTL in milliseconds is applied atomically with the value
+func (cache *CACHE) SetString(key, value string, ttl ...int) error {
+	expireAt, err := deadlineAfter(ttl)
+	if err != nil {
+		return err
+	}
+
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	cache.data[key] = value
+	shard.set(key, value, expireAt)
+	shard.record("set", key, value, expireAt)
 
 	return nil
 }
 
-func (cache *CACHE) SetInt(key string, value int) error {
-	cache.Lock()
-	defer cache.Unlock()
+// Set int with optional TTL in milliseconds
+func (cache *CACHE) SetInt(key string, value int, ttl ...int) error {
+	expireAt, err := deadlineAfter(ttl)
+	if err != nil {
+		return err
+	}
+
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	cache.data[key] = value
+	shard.set(key, value, expireAt)
+	shard.record("set", key, value, expireAt)
 
 	return nil
 }
 
-func (cache *CACHE) SetList(key string, value util.List) error {
-	cache.Lock()
-	defer cache.Unlock()
+// Set list with optional TTL in milliseconds
+func (cache *CACHE) SetList(key string, value util.List, ttl ...int) error {
+	expireAt, err := deadlineAfter(ttl)
+	if err != nil {
+		return err
+	}
+
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	cache.data[key] = value
+	shard.set(key, value, expireAt)
+	shard.record("set", key, value, expireAt)
 
 	return nil
 }
 
-func (cache *CACHE) SetDict(key string, value util.Dict) error {
-	cache.Lock()
-	defer cache.Unlock()
+// Set dict with optional TTL in milliseconds
+func (cache *CACHE) SetDict(key string, value util.Dict, ttl ...int) error {
+	expireAt, err := deadlineAfter(ttl)
+	if err != nil {
+		return err
+	}
+
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	cache.data[key] = value
+	shard.set(key, value, expireAt)
+	shard.record("set", key, value, expireAt)
 
 	return nil
 }
 
 func (cache *CACHE) UpdateString(key, value string) (string, error) {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	oldValue, success := cache.data[key]
+	return shard.updateString(key, value)
+}
+
+// Caller must hold the write lock
+func (s *shard) updateString(key, value string) (string, error) {
+	e, success := s.lookup(key)
 
 	if !success {
 		return "", util.ErrorKeyNotFound
 	}
 
-	cache.data[key] = value
+	oldValue, success := e.value.(string)
+	if !success {
+		return "", s.counters.wrongTypeError()
+	}
+
+	s.store(key, value)
+	s.record("store", key, value, 0)
 
-	return oldValue.(string), nil
+	return oldValue, nil
 }
 
 func (cache *CACHE) UpdateInt(key string, value int) (int, error) {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.updateInt(key, value)
+}
 
-	oldValue, success := cache.data[key]
+// Caller must hold the write lock
+func (s *shard) updateInt(key string, value int) (int, error) {
+	e, success := s.lookup(key)
 
 	if !success {
 		return -1, util.ErrorKeyNotFound
 	}
 
-	cache.data[key] = value
+	oldValue, success := e.value.(int)
+	if !success {
+		return -1, s.counters.wrongTypeError()
+	}
+
+	s.store(key, value)
+	s.record("store", key, value, 0)
 
-	return oldValue.(int), nil
+	return oldValue, nil
 }
 
 func (cache *CACHE) UpdateList(key string, value util.List) (util.List, error) {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.updateList(key, value)
+}
 
-	oldValue, success := cache.data[key]
+// Caller must hold the write lock
+func (s *shard) updateList(key string, value util.List) (util.List, error) {
+	e, success := s.lookup(key)
 
 	if !success {
 		return nil, util.ErrorKeyNotFound
 	}
 
-	cache.data[key] = value
+	oldValue, success := e.value.(util.List)
+	if !success {
+		return nil, s.counters.wrongTypeError()
+	}
 
-	return oldValue.(util.List), nil
+	s.store(key, value)
+	s.record("store", key, value, 0)
+
+	return oldValue, nil
 }
 
 func (cache *CACHE) UpdateDict(key string, value util.Dict) (util.Dict, error) {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	oldValue, success := cache.data[key]
+	return shard.updateDict(key, value)
+}
+
+// Caller must hold the write lock
+func (s *shard) updateDict(key string, value util.Dict) (util.Dict, error) {
+	e, success := s.lookup(key)
 
 	if !success {
 		return nil, util.ErrorKeyNotFound
 	}
 
-	cache.data[key] = value
+	oldValue, success := e.value.(util.Dict)
+	if !success {
+		return nil, s.counters.wrongTypeError()
+	}
+
+	s.store(key, value)
+	s.record("store", key, value, 0)
 
-	return oldValue.(util.Dict), nil
+	return oldValue, nil
 }
 
 func (cache *CACHE) Remove(key string) error {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	delete(cache.data, key)
+	return shard.remove(key)
+}
+
+// Caller must hold the write lock
+func (s *shard) remove(key string) error {
+	if s.delete(key, util.EventRemove) {
+		s.counters.delete()
+		s.record("del", key, nil, 0)
+	}
 
 	return nil
 }
 
+// Remove keys, returns the number of keys which existed
+func (cache *CACHE) RemoveKeys(keys ...string) int {
+	removed := 0
+	for _, key := range keys {
+		shard := cache.shard(key)
+		shard.Lock()
+		if _, ok := shard.lookup(key); ok {
+			shard.remove(key)
+			removed++
+		}
+		shard.Unlock()
+	}
+
+	return removed
+}
+
 func (cache *CACHE) RemoveFromList(key string, value string) (int, error) {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
 
-	list, success := cache.data[key]
+	return shard.removeFromList(key, value)
+}
+
+// Caller must hold the write lock
+func (s *shard) removeFromList(key string, value string) (int, error) {
+	list, success := s.lookup(key)
 	if !success {
 		return 0, util.ErrorKeyNotFound
 	}
 
-	l, success := list.(util.List)
+	l, success := list.value.(util.List)
 	if !success {
-		return 0, util.ErrorWrongType
+		return 0, s.counters.wrongTypeError()
 	}
 
 	index := util.SentinelLinearSearch(l, value)
-	if index != -1 {
-		l = append(l[:index], l[index+1:]...)
+	if index == -1 {
+		return index, nil
 	}
 
-	cache.data[key] = l
+	l = append(l[:index], l[index+1:]...)
+	s.store(key, l)
+	s.record("lrem", key, value, 0)
 
 	return index, nil
 }
 
+// Remove up to count occurrences of value from list, 0 - all of them,
+// negative count removes them from the tail
+// Returns the number of removed elements
+func (cache *CACHE) RemoveCountFromList(key, value string, count int) (int, error) {
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.removeCountFromList(key, value, count)
+}
+
+// Caller must hold the write lock
+func (s *shard) removeCountFromList(key, value string, count int) (int, error) {
+	e, success := s.lookup(key)
+	if !success {
+		return 0, util.ErrorKeyNotFound
+	}
+
+	l, success := e.value.(util.List)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+
+	removed := 0
+	keep := make([]bool, len(l))
+	for i := range l {
+		// Walk from the tail for negative count
+		j := i
+		if count < 0 {
+			j = len(l) - 1 - i
+		}
+		keep[j] = l[j] != value || (limit > 0 && removed >= limit)
+		if !keep[j] {
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	newList := make(util.List, 0, len(l)-removed)
+	for i, element := range l {
+		if keep[i] {
+			newList = append(newList, element)
+		}
+	}
+
+	s.store(key, newList)
+	s.record("store", key, newList, 0)
+
+	return removed, nil
+}
+
 func (cache *CACHE) RemoveFromDict(key string, value string) error {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.removeFromDict(key, value)
+}
 
-	dict, success := cache.data[key]
+// Caller must hold the write lock
+func (s *shard) removeFromDict(key string, value string) error {
+	dict, success := s.lookup(key)
 	if !success {
 		return util.ErrorKeyNotFound
 	}
 
-	d, success := dict.(util.Dict)
+	d, success := dict.value.(util.Dict)
 	if !success {
-		return util.ErrorWrongType
+		return s.counters.wrongTypeError()
+	}
+
+	if _, exists := d[value]; !exists {
+		return nil
 	}
 
 	delete(d, value)
+	s.store(key, d)
+	s.record("hdel", key, value, 0)
 
 	return nil
 }
 
+// Remove dict elements, returns the number of removed elements
+func (cache *CACHE) RemoveDictElements(key string, elementKeys ...string) (int, error) {
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.removeDictElements(key, elementKeys)
+}
+
+// Caller must hold the write lock
+func (s *shard) removeDictElements(key string, elementKeys []string) (int, error) {
+	e, success := s.lookup(key)
+	if !success {
+		return 0, util.ErrorKeyNotFound
+	}
+
+	d, success := e.value.(util.Dict)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	removed := 0
+	for _, elementKey := range elementKeys {
+		if _, exists := d[elementKey]; exists {
+			delete(d, elementKey)
+			s.record("hdel", key, elementKey, 0)
+			removed++
+		}
+	}
+	if removed > 0 {
+		s.store(key, d)
+	}
+
+	return removed, nil
+}
+
+// Set dict element, the dict is created if key is missing
+// Returns true if the element is new
+func (cache *CACHE) SetDictElement(key, elementKey, value string) (bool, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.setDictElement(key, elementKey, value)
+}
+
+// Caller must hold the write lock
+func (s *shard) setDictElement(key, elementKey, value string) (bool, error) {
+	dict, success := s.lookup(key)
+	if !success {
+		s.set(key, util.Dict{elementKey: value}, 0)
+		s.record("hset", key, []string{elementKey, value}, 0)
+		return true, nil
+	}
+
+	d, success := dict.value.(util.Dict)
+	if !success {
+		return false, s.counters.wrongTypeError()
+	}
+
+	_, exists := d[elementKey]
+	d[elementKey] = value
+	s.store(key, d)
+	s.record("hset", key, []string{elementKey, value}, 0)
+
+	return !exists, nil
+}
+
+// Set several dict elements at once, the dict is created if key is missing
+// Returns the number of new elements
+func (cache *CACHE) SetDictElements(key string, elements util.Dict) (int, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.setDictElements(key, elements)
+}
+
+// Caller must hold the write lock
+func (s *shard) setDictElements(key string, elements util.Dict) (int, error) {
+	if len(elements) == 0 {
+		return 0, nil
+	}
+
+	e, success := s.lookup(key)
+	if !success {
+		d := make(util.Dict, len(elements))
+		for elementKey, value := range elements {
+			d[elementKey] = value
+			s.record("hset", key, []string{elementKey, value}, 0)
+		}
+		s.set(key, d, 0)
+		return len(d), nil
+	}
+
+	d, success := e.value.(util.Dict)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	added := 0
+	for elementKey, value := range elements {
+		if _, exists := d[elementKey]; !exists {
+			added++
+		}
+		d[elementKey] = value
+		s.record("hset", key, []string{elementKey, value}, 0)
+	}
+	s.store(key, d)
+
+	return added, nil
+}
+
 func (cache *CACHE) AppendToList(key, value string) error {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.appendToList(key, value)
+}
 
-	list, success := cache.data[key]
+// Caller must hold the write lock
+func (s *shard) appendToList(key, value string) error {
+	list, success := s.lookup(key)
 	if !success {
 		return util.ErrorKeyNotFound
 	}
 
-	l, success := list.(util.List)
+	l, success := list.value.(util.List)
 	if !success {
-		return util.ErrorWrongType
+		return s.counters.wrongTypeError()
 	}
 
 	newList := append(l, value)
 
-	cache.data[key] = newList
+	s.store(key, newList)
+	s.record("rpush", key, value, 0)
 
 	return nil
 }
 
+// Append values to list, the list is created if key is missing
+// Returns the new length of the list
+func (cache *CACHE) PushToList(key string, values ...string) (int, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.pushToList(key, values)
+}
+
+// Caller must hold the write lock
+func (s *shard) pushToList(key string, values []string) (int, error) {
+	e, success := s.lookup(key)
+	if !success {
+		l := append(util.List{}, values...)
+		s.set(key, l, 0)
+		s.record("set", key, l, 0)
+		return len(l), nil
+	}
+
+	l, success := e.value.(util.List)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	newList := append(l, values...)
+	s.store(key, newList)
+	for _, value := range values {
+		s.record("rpush", key, value, 0)
+	}
+
+	return len(newList), nil
+}
+
 func (cache *CACHE) Increment(key string) (int, error) {
-	cache.Lock()
-	defer cache.Unlock()
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.increment(key)
+}
 
-	value, success := cache.data[key]
+// Caller must hold the write lock
+func (s *shard) increment(key string) (int, error) {
+	e, success := s.lookup(key)
 	if !success {
 		return 0, util.ErrorKeyNotFound
 	}
 
-	v, success := value.(int)
+	v, success := e.value.(int)
 	if !success {
-		return 0, util.ErrorWrongType
+		return 0, s.counters.wrongTypeError()
 	}
 
-	cache.data[key] = v + 1
+	s.store(key, v+1)
+	s.record("store", key, v+1, 0)
 
 	return v + 1, nil
 }
+
+// Increment int, missing key is created with 1
+func (cache *CACHE) IncrementOrCreate(key string) (int, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	if _, ok := shard.lookup(key); !ok {
+		shard.set(key, 1, 0)
+		shard.record("set", key, 1, 0)
+		return 1, nil
+	}
+
+	return shard.increment(key)
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/anevsky/cachego/util"
@@ -265,3 +266,105 @@ func TestIncrement(t *testing.T) {
 		t.Errorf("Expected 124, but it was %d instead.", v)
 	}
 }
+
+func TestRemoveKeys(t *testing.T) {
+	t.Log("Testing RemoveKeys method...")
+
+	cache := Alloc()
+	cache.SetString("k1", "v")
+	cache.SetInt("k2", 1)
+
+	if n := cache.RemoveKeys("k1", "k2", "missing"); n != 2 {
+		t.Errorf("Expected 2, but it was %d instead.", n)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected 0, but it was %d instead.", cache.Len())
+	}
+}
+
+func TestRemoveCountFromList(t *testing.T) {
+	t.Log("Testing RemoveCountFromList method...")
+
+	cases := []struct {
+		count    int
+		removed  int
+		expected util.List
+	}{
+		{0, 3, util.List{"b", "c"}},
+		{2, 2, util.List{"b", "c", "a"}},
+		{-2, 2, util.List{"a", "b", "c"}},
+	}
+
+	for _, c := range cases {
+		cache := Alloc()
+		cache.SetList("l", util.List{"a", "b", "a", "c", "a"})
+
+		removed, err := cache.RemoveCountFromList("l", "a", c.count)
+		v, _ := cache.Get("l")
+		if err != nil || removed != c.removed || !reflect.DeepEqual(v, c.expected) {
+			t.Errorf("Expected %d removed and %v for count %d, but it was %d and %v (%v) instead.", c.removed, c.expected, c.count, removed, v, err)
+		}
+	}
+}
+
+func TestRemoveDictElements(t *testing.T) {
+	t.Log("Testing RemoveDictElements method...")
+
+	cache := Alloc()
+	cache.SetDict("d", util.Dict{"f1": "v1", "f2": "v2"})
+
+	if n, err := cache.RemoveDictElements("d", "f1", "f9"); err != nil || n != 1 {
+		t.Errorf("Expected 1, but it was %d (%v) instead.", n, err)
+	}
+	if _, err := cache.RemoveDictElements("missing", "f1"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorKeyNotFound, err)
+	}
+}
+
+func TestSetDictElements(t *testing.T) {
+	t.Log("Testing SetDictElements method...")
+
+	cache := Alloc()
+
+	if n, err := cache.SetDictElements("d", util.Dict{"f1": "v1", "f2": "v2"}); err != nil || n != 2 {
+		t.Errorf("Expected 2, but it was %d (%v) instead.", n, err)
+	}
+	if n, err := cache.SetDictElements("d", util.Dict{"f2": "v3", "f3": "v3"}); err != nil || n != 1 {
+		t.Errorf("Expected 1, but it was %d (%v) instead.", n, err)
+	}
+	if v, _ := cache.Get("d"); !reflect.DeepEqual(v, util.Dict{"f1": "v1", "f2": "v3", "f3": "v3"}) {
+		t.Errorf("Expected map[f1:v1 f2:v3 f3:v3], but it was %v instead.", v)
+	}
+
+	cache.SetInt("i", 1)
+	if _, err := cache.SetDictElements("i", util.Dict{"f1": "v1"}); err != util.ErrorWrongType {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorWrongType, err)
+	}
+}
+
+func TestPushToListAndIncrementOrCreate(t *testing.T) {
+	t.Log("Testing concurrent PushToList and IncrementOrCreate on missing keys...")
+
+	cache := Alloc()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.PushToList("l", "a", "b")
+			cache.IncrementOrCreate("n")
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := cache.Get("l"); len(v.(util.List)) != 100 {
+		t.Errorf("Expected 100 elements, but it was %d instead.", len(v.(util.List)))
+	}
+	if v, _ := cache.Get("n"); v != 50 {
+		t.Errorf("Expected 50, but it was %v instead.", v)
+	}
+	if _, err := cache.IncrementOrCreate("l"); err != util.ErrorWrongType {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorWrongType, err)
+	}
+}
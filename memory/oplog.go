@@ -0,0 +1,499 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Fsync policy of the append-only log
+type Fsync int
+
+const (
+	// Sync once a second, a second of writes can be lost on crash
+	FsyncEverySecond Fsync = iota
+	// Sync after every write
+	FsyncAlways
+	// Leave syncing to the OS
+	FsyncNever
+)
+
+const (
+	// Log is rewritten in the background when it is at least this big
+	appendLogRewriteMinSize = 64 << 20
+	// and has grown by this factor since the last rewrite
+	appendLogRewriteGrowth = 2
+)
+
+// Append-only log of writes
+// Every write is recorded under the lock of its shard, so ops on one key are
+// logged in the order they were applied. Rewrite dumps shards one by one and
+// buffers ops of already dumped shards until the new log replaces the old one,
+// so writers are never blocked for longer than a shard dump.
+type appendLog struct {
+	// Non-zero when writes are recorded, read without the lock
+	enabled int32
+
+	sync.Mutex
+	shards   []*shard
+	path     string
+	fsync    Fsync
+	file     *os.File
+	size     int64
+	baseSize int64
+	dirty    bool
+	// First write error, the log is incomplete after it
+	err error
+
+	rewriting bool
+	// Shards already dumped by the running rewrite
+	cut    map[*shard]bool
+	buffer bytes.Buffer
+
+	stop chan struct{}
+}
+
+// One line of the log
+type appendOp struct {
+	Op    string          `json:"op"`
+	Key   string          `json:"key"`
+	Type  string          `json:"type,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	// Unix timestamp in milliseconds
+	ExpireAt int64 `json:"expire_at,omitempty"`
+}
+
+func encodeOp(op, key string, value interface{}, expireAt int64) ([]byte, error) {
+	record := appendOp{Op: op, Key: key, ExpireAt: expireAt / int64(time.Millisecond)}
+
+	if op == "set" || op == "store" {
+		record.Type = typeName(value)
+	}
+	if z, ok := value.(*sortedSet); ok {
+		value = z.members()
+	}
+	if value != nil {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		record.Value = raw
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(line, '\n'), nil
+}
+
+// Record write to the append-only log and replication feeds
+// Caller must hold the write lock
+func (s *shard) record(op, key string, value interface{}, expireAt int64) {
+	logging := atomic.LoadInt32(&s.appendLog.enabled) != 0
+	feeding := atomic.LoadInt32(&s.replication.feeds) != 0
+	if !logging && !feeding {
+		return
+	}
+
+	line, err := encodeOp(op, key, value, expireAt)
+
+	// Transaction writes are recorded when it commits
+	if s.tx != nil {
+		s.tx.lines = append(s.tx.lines, txLine{s, line, err})
+		return
+	}
+
+	s.emit(line, err)
+}
+
+// Send encoded op to the append-only log and replication feeds
+// Caller must hold the write lock
+func (s *shard) emit(line []byte, err error) {
+	if atomic.LoadInt32(&s.appendLog.enabled) != 0 {
+		s.appendLog.append(s, line, err)
+	}
+	if atomic.LoadInt32(&s.replication.feeds) != 0 && err == nil {
+		s.replication.publish(s, line)
+	}
+}
+
+// Caller must hold the write lock of the shard
+func (l *appendLog) append(s *shard, line []byte, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if err == nil {
+		err = l.write(line)
+	}
+	if err != nil {
+		if l.err == nil {
+			l.err = err
+		}
+		return
+	}
+
+	if l.rewriting && l.cut[s] {
+		l.buffer.Write(line)
+	}
+
+	if !l.rewriting && l.size >= appendLogRewriteMinSize && l.size >= appendLogRewriteGrowth*l.baseSize {
+		l.startRewrite()
+		go l.rewrite()
+	}
+}
+
+// Caller must hold the log lock
+func (l *appendLog) write(line []byte) error {
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+
+	if l.fsync == FsyncAlways {
+		return l.file.Sync()
+	}
+	l.dirty = true
+
+	return nil
+}
+
+// Replay append-only log and record all following writes to it
+// Log is created if missing, a tail truncated by a crash is dropped
+func (cache *CACHE) OpenAppendLog(path string, fsync Fsync) error {
+	l := cache.appendLog
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	offset, err := cache.replay(f)
+	if err == nil {
+		err = f.Truncate(offset)
+	}
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.path = path
+	l.fsync = fsync
+	l.file = f
+	l.size = offset
+	l.baseSize = offset
+	l.err = nil
+	l.stop = make(chan struct{})
+	atomic.StoreInt32(&l.enabled, 1)
+
+	if fsync == FsyncEverySecond {
+		go l.syncEverySecond(l.stop)
+	}
+
+	return nil
+}
+
+// Stop recording writes, sync and close the log
+// Returns the first write error if the log is incomplete
+func (cache *CACHE) CloseAppendLog() error {
+	l := cache.appendLog
+
+	l.Lock()
+	defer l.Unlock()
+
+	if l.file == nil {
+		return l.err
+	}
+
+	atomic.StoreInt32(&l.enabled, 0)
+	close(l.stop)
+
+	err := l.file.Sync()
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+
+	if l.err != nil {
+		return l.err
+	}
+
+	return err
+}
+
+func (l *appendLog) syncEverySecond(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.Lock()
+			f, dirty := l.file, l.dirty
+			l.dirty = false
+			l.Unlock()
+
+			// File may be replaced by a rewrite meanwhile, its data is in the new one then
+			if dirty && f != nil {
+				f.Sync()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Rewrite log with the minimal set of ops producing the current state
+// Writers keep going while the log is rewritten
+func (cache *CACHE) RewriteAppendLog() error {
+	l := cache.appendLog
+
+	l.Lock()
+	if l.file == nil {
+		l.Unlock()
+		return util.ErrorAppendLogClosed
+	}
+	if l.rewriting {
+		l.Unlock()
+		return util.ErrorRewriteInProgress
+	}
+	l.startRewrite()
+	l.Unlock()
+
+	return l.rewrite()
+}
+
+// Caller must hold the log lock
+func (l *appendLog) startRewrite() {
+	l.rewriting = true
+	l.cut = map[*shard]bool{}
+	l.buffer.Reset()
+}
+
+func (l *appendLog) rewrite() (err error) {
+	l.Lock()
+	path := l.path
+	l.Unlock()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".rewrite")
+	if err != nil {
+		l.finishRewrite()
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	var dump bytes.Buffer
+	for _, s := range l.shards {
+		dump.Reset()
+
+		s.RLock()
+		l.Lock()
+		l.cut[s] = true
+		l.Unlock()
+
+		t := now()
+		for key, e := range s.data {
+			if e.expireAt != 0 && e.expired(t) {
+				continue
+			}
+
+			line, encodeErr := encodeOp("set", key, e.value, e.expireAt)
+			if encodeErr != nil {
+				err = encodeErr
+				break
+			}
+			dump.Write(line)
+		}
+		s.RUnlock()
+
+		if err != nil {
+			l.finishRewrite()
+			return err
+		}
+
+		if _, err = tmp.Write(dump.Bytes()); err != nil {
+			l.finishRewrite()
+			return err
+		}
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	defer l.resetRewrite()
+
+	if l.file == nil {
+		return util.ErrorAppendLogClosed
+	}
+
+	// Ops recorded after their shard was dumped
+	if _, err = tmp.Write(l.buffer.Bytes()); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	l.file.Close()
+	l.file = tmp
+	l.size = size
+	l.baseSize = size
+	l.dirty = false
+
+	return nil
+}
+
+func (l *appendLog) finishRewrite() {
+	l.Lock()
+	defer l.Unlock()
+
+	l.resetRewrite()
+}
+
+// Caller must hold the log lock
+func (l *appendLog) resetRewrite() {
+	l.rewriting = false
+	l.cut = nil
+	l.buffer.Reset()
+}
+
+// Apply ops from the log, returns offset of the end of the last complete op
+func (cache *CACHE) replay(r io.Reader) (int64, error) {
+	reader := bufio.NewReader(r)
+
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Incomplete last line is a write cut by a crash
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+
+		var op appendOp
+		if err := json.Unmarshal(line, &op); err != nil {
+			return offset, util.ErrorInvalidAppendLog
+		}
+
+		if err := cache.apply(op); err != nil {
+			return offset, err
+		}
+
+		offset += int64(len(line))
+	}
+}
+
+// Apply single op from the log or the replication feed
+func (cache *CACHE) apply(op appendOp) error {
+	expireAt := op.ExpireAt * int64(time.Millisecond)
+
+	var args []string
+	var members util.ZSet
+	var value interface{}
+	var err error
+
+	switch op.Op {
+	case "set", "store":
+		value, err = decodeValue(op.Type, op.Value)
+	case "rpush", "lrem", "hdel":
+		var v string
+		err = json.Unmarshal(op.Value, &v)
+		args = []string{v}
+	case "hset", "sadd", "srem", "zrem":
+		err = json.Unmarshal(op.Value, &args)
+	case "zadd":
+		err = json.Unmarshal(op.Value, &members)
+	}
+	if err != nil {
+		return util.ErrorInvalidAppendLog
+	}
+
+	shard := cache.shard(op.Key)
+
+	switch op.Op {
+	case "set", "store", "del", "expireat", "persist":
+		defer cache.evictOverLimit(op.Key)
+		shard.Lock()
+		defer shard.Unlock()
+	}
+
+	// Ops below were valid when recorded, so their errors are not expected
+	// Applied ops are recorded again for replicas of replicas
+	switch op.Op {
+	case "ping":
+	case "set":
+		shard.set(op.Key, value, expireAt)
+		shard.record(op.Op, op.Key, value, expireAt)
+	case "store":
+		shard.store(op.Key, value)
+		shard.record(op.Op, op.Key, value, 0)
+	case "del":
+		if shard.delete(op.Key, util.EventRemove) {
+			shard.record(op.Op, op.Key, nil, 0)
+		}
+	case "expireat":
+		if e, ok := shard.lookup(op.Key); ok {
+			shard.setExpiry(op.Key, e, expireAt)
+			shard.record(op.Op, op.Key, nil, expireAt)
+		}
+	case "persist":
+		if e, ok := shard.lookup(op.Key); ok {
+			shard.setExpiry(op.Key, e, 0)
+			shard.record(op.Op, op.Key, nil, 0)
+		}
+	case "rpush":
+		cache.AppendToList(op.Key, args[0])
+	case "lrem":
+		cache.RemoveFromList(op.Key, args[0])
+	case "hset":
+		if len(args) != 2 {
+			return util.ErrorInvalidAppendLog
+		}
+		cache.SetDictElement(op.Key, args[0], args[1])
+	case "hdel":
+		cache.RemoveFromDict(op.Key, args[0])
+	case "sadd":
+		cache.SAdd(op.Key, args...)
+	case "srem":
+		cache.SRem(op.Key, args...)
+	case "zadd":
+		cache.ZAdd(op.Key, members...)
+	case "zrem":
+		cache.ZRem(op.Key, args...)
+	default:
+		return util.ErrorInvalidAppendLog
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func tempAppendLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "cachego")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "cachego.aof"), func() { os.RemoveAll(dir) }
+}
+
+func checkSameContent(t *testing.T, expected, actual *CACHE) {
+	if expected.Len() != actual.Len() {
+		t.Errorf("Expected %d keys, but it was %d instead.", expected.Len(), actual.Len())
+	}
+
+	for _, key := range expected.Keys() {
+		v1, _ := expected.Get(key)
+		v2, err := actual.Get(key)
+		if err != nil || !reflect.DeepEqual(v1, v2) {
+			t.Errorf("Expected %v for '%s', but it was %v (%v) instead.", v1, key, v2, err)
+		}
+	}
+}
+
+func writeAllOps(cache *CACHE) {
+	cache.SetString("string", "v1")
+	cache.UpdateString("string", "v2")
+	cache.SetInt("int", 1, 60000)
+	cache.Increment("int")
+	cache.Increment("int")
+	cache.SetList("list", util.List{"a", "b", "c"})
+	cache.AppendToList("list", "d")
+	cache.RemoveFromList("list", "b")
+	cache.SetDict("dict", util.Dict{"k1": "v1", "k2": "v2"})
+	cache.RemoveFromDict("dict", "k1")
+	cache.SetDictElement("dict", "k3", "v3")
+	cache.SAdd("set", "m1", "m2", "m3")
+	cache.SRem("set", "m2")
+	cache.ZAdd("zset", util.ZMember{Member: "m1", Score: 1}, util.ZMember{Member: "m2", Score: 2})
+	cache.ZIncrBy("zset", 5, "m1")
+	cache.ZRemRangeByScore("zset", 0, 3)
+	cache.SetString("removed", "v")
+	cache.Remove("removed")
+	cache.SetString("persistent", "v", 60000)
+	cache.Persist("persistent")
+	cache.SetString("ttl", "v")
+	cache.SetTTL("ttl", 60000)
+	cache.SetString("expired", "v")
+	cache.ExpireAt("expired", 1)
+}
+
+func TestAppendLogReplay(t *testing.T) {
+	t.Log("Testing append-only log replay...")
+
+	path, cleanup := tempAppendLog(t)
+	defer cleanup()
+
+	for _, fsync := range []Fsync{FsyncAlways, FsyncEverySecond, FsyncNever} {
+		os.Remove(path)
+
+		cache := Alloc()
+		if err := cache.OpenAppendLog(path, fsync); err != nil {
+			t.Fatal(err)
+		}
+		writeAllOps(&cache)
+		if err := cache.CloseAppendLog(); err != nil {
+			t.Error(err)
+		}
+
+		replayed := Alloc()
+		if err := replayed.OpenAppendLog(path, fsync); err != nil {
+			t.Fatal(err)
+		}
+
+		checkSameContent(t, &cache, &replayed)
+
+		if ttl, _ := replayed.GetTTL("ttl"); ttl <= 0 {
+			t.Errorf("Expected ttl, but it was %d instead.", ttl)
+		}
+		if ttl, _ := replayed.GetTTL("persistent"); ttl != -1 {
+			t.Errorf("Expected -1, but it was %d instead.", ttl)
+		}
+
+		replayed.CloseAppendLog()
+	}
+}
+
+func TestAppendLogTruncatedTail(t *testing.T) {
+	t.Log("Testing append-only log with a truncated tail...")
+
+	path, cleanup := tempAppendLog(t)
+	defer cleanup()
+
+	cache := Alloc()
+	cache.OpenAppendLog(path, FsyncAlways)
+	cache.SetString("k1", "v1")
+	cache.CloseAppendLog()
+
+	info, _ := os.Stat(path)
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"op":"set","key":"k2"`)
+	f.Close()
+
+	replayed := Alloc()
+	if err := replayed.OpenAppendLog(path, FsyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.CloseAppendLog()
+
+	if replayed.Len() != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", replayed.Len())
+	}
+
+	truncated, _ := os.Stat(path)
+	if truncated.Size() != info.Size() {
+		t.Errorf("Expected size %d, but it was %d instead.", info.Size(), truncated.Size())
+	}
+}
+
+func TestAppendLogInvalid(t *testing.T) {
+	t.Log("Testing invalid append-only log...")
+
+	path, cleanup := tempAppendLog(t)
+	defer cleanup()
+
+	ioutil.WriteFile(path, []byte("garbage\n"), 0644)
+
+	cache := Alloc()
+	if err := cache.OpenAppendLog(path, FsyncNever); err != util.ErrorInvalidAppendLog {
+		t.Errorf("Expected ErrorInvalidAppendLog, but it was %v instead.", err)
+	}
+
+	if err := cache.RewriteAppendLog(); err != util.ErrorAppendLogClosed {
+		t.Errorf("Expected ErrorAppendLogClosed, but it was %v instead.", err)
+	}
+}
+
+func TestAppendLogRewrite(t *testing.T) {
+	t.Log("Testing append-only log rewrite...")
+
+	path, cleanup := tempAppendLog(t)
+	defer cleanup()
+
+	cache := Alloc()
+	cache.OpenAppendLog(path, FsyncNever)
+	for i := 0; i < 100; i++ {
+		cache.SetInt("counter", i)
+		cache.SetString("key-"+strconv.Itoa(i%10), "v")
+	}
+	writeAllOps(&cache)
+
+	before, _ := os.Stat(path)
+
+	if err := cache.RewriteAppendLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("Expected log to shrink from %d, but it was %d instead.", before.Size(), after.Size())
+	}
+
+	cache.AppendToList("list", "after")
+	cache.CloseAppendLog()
+
+	replayed := Alloc()
+	replayed.OpenAppendLog(path, FsyncNever)
+	defer replayed.CloseAppendLog()
+
+	checkSameContent(t, &cache, &replayed)
+}
+
+func TestAppendLogRewriteConcurrent(t *testing.T) {
+	t.Log("Testing append-only log rewrite with concurrent writers...")
+
+	path, cleanup := tempAppendLog(t)
+	defer cleanup()
+
+	cache := AllocWithConfig(Config{Shards: 4})
+	cache.OpenAppendLog(path, FsyncNever)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			key := "counter-" + strconv.Itoa(w)
+			cache.SetInt(key, 0)
+			for i := 0; i < 2000; i++ {
+				cache.Increment(key)
+				cache.SAdd("set-"+strconv.Itoa(w), strconv.Itoa(i%50))
+			}
+		}(w)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := cache.RewriteAppendLog(); err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+	cache.CloseAppendLog()
+
+	replayed := AllocWithConfig(Config{Shards: 4})
+	replayed.OpenAppendLog(path, FsyncNever)
+	defer replayed.CloseAppendLog()
+
+	checkSameContent(t, &cache, &replayed)
+}
@@ -0,0 +1,381 @@
+package memory
+
+import (
+	"container/list"
+	"math/rand"
+)
+
+// Share of the 2Q recent queue and its ghost history relative to resident keys
+const (
+	twoQueueRecentRatio = 0.25
+	twoQueueGhostRatio  = 0.5
+)
+
+///////////////////////////////////////
+// LRU
+///////////////////////////////////////
+
+// Least recently used keys are evicted first
+type lru struct {
+	// Most recently used keys are in front
+	order *list.List
+	items map[string]*list.Element
+}
+
+func NewLRU() EvictionPolicy {
+	return &lru{
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (p *lru) Name() string {
+	return "lru"
+}
+
+func (p *lru) Add(key string) {
+	p.items[key] = p.order.PushFront(key)
+}
+
+func (p *lru) Access(key string) {
+	if element, ok := p.items[key]; ok {
+		p.order.MoveToFront(element)
+	}
+}
+
+func (p *lru) Remove(key string) {
+	if element, ok := p.items[key]; ok {
+		p.order.Remove(element)
+		delete(p.items, key)
+	}
+}
+
+func (p *lru) Evict(except string) (string, bool) {
+	for element := p.order.Back(); element != nil; element = element.Prev() {
+		if key := element.Value.(string); key != except {
+			p.Remove(key)
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+///////////////////////////////////////
+// LFU
+///////////////////////////////////////
+
+// Least frequently used keys are evicted first,
+// least recently used one among keys with equal frequency
+type lfu struct {
+	// Buckets of *lfuBucket ordered by increasing frequency
+	buckets *list.List
+	items   map[string]*lfuItem
+}
+
+type lfuBucket struct {
+	frequency int
+	// Most recently used keys are in front
+	keys *list.List
+}
+
+type lfuItem struct {
+	bucket  *list.Element
+	element *list.Element
+}
+
+func NewLFU() EvictionPolicy {
+	return &lfu{
+		buckets: list.New(),
+		items:   map[string]*lfuItem{},
+	}
+}
+
+func (p *lfu) Name() string {
+	return "lfu"
+}
+
+func (p *lfu) Add(key string) {
+	bucket := p.buckets.Front()
+	if bucket == nil || bucket.Value.(*lfuBucket).frequency != 1 {
+		bucket = p.buckets.PushFront(&lfuBucket{frequency: 1, keys: list.New()})
+	}
+
+	p.items[key] = &lfuItem{
+		bucket:  bucket,
+		element: bucket.Value.(*lfuBucket).keys.PushFront(key),
+	}
+}
+
+func (p *lfu) Access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	current := item.bucket.Value.(*lfuBucket)
+	next := item.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).frequency != current.frequency+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{frequency: current.frequency + 1, keys: list.New()}, item.bucket)
+	}
+
+	p.unlink(item)
+	item.bucket = next
+	item.element = next.Value.(*lfuBucket).keys.PushFront(key)
+}
+
+func (p *lfu) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		p.unlink(item)
+		delete(p.items, key)
+	}
+}
+
+func (p *lfu) Evict(except string) (string, bool) {
+	for bucket := p.buckets.Front(); bucket != nil; bucket = bucket.Next() {
+		keys := bucket.Value.(*lfuBucket).keys
+		for element := keys.Back(); element != nil; element = element.Prev() {
+			if key := element.Value.(string); key != except {
+				p.Remove(key)
+				return key, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Take item out of its bucket and drop the bucket if it became empty
+func (p *lfu) unlink(item *lfuItem) {
+	bucket := item.bucket.Value.(*lfuBucket)
+	bucket.keys.Remove(item.element)
+	if bucket.keys.Len() == 0 {
+		p.buckets.Remove(item.bucket)
+	}
+}
+
+///////////////////////////////////////
+// 2Q
+///////////////////////////////////////
+
+// Two queue policy: new keys enter a FIFO of recent keys and are promoted
+// to an LRU of frequent keys on second use. Keys evicted from the recent
+// queue are remembered in a ghost history, so they go straight to the
+// frequent queue when stored again. Scans don't flush frequently used keys.
+type twoQueue struct {
+	recent   *list.List
+	frequent *list.List
+	ghosts   *list.List
+	items    map[string]*twoQueueItem
+	ghost    map[string]*list.Element
+}
+
+type twoQueueItem struct {
+	queue   *list.List
+	element *list.Element
+}
+
+func New2Q() EvictionPolicy {
+	return &twoQueue{
+		recent:   list.New(),
+		frequent: list.New(),
+		ghosts:   list.New(),
+		items:    map[string]*twoQueueItem{},
+		ghost:    map[string]*list.Element{},
+	}
+}
+
+func (p *twoQueue) Name() string {
+	return "2q"
+}
+
+func (p *twoQueue) Add(key string) {
+	queue := p.recent
+	if element, ok := p.ghost[key]; ok {
+		p.ghosts.Remove(element)
+		delete(p.ghost, key)
+		queue = p.frequent
+	}
+
+	p.items[key] = &twoQueueItem{queue: queue, element: queue.PushFront(key)}
+}
+
+func (p *twoQueue) Access(key string) {
+	item, ok := p.items[key]
+	if !ok {
+		return
+	}
+
+	if item.queue == p.frequent {
+		p.frequent.MoveToFront(item.element)
+		return
+	}
+
+	p.recent.Remove(item.element)
+	item.queue = p.frequent
+	item.element = p.frequent.PushFront(key)
+}
+
+func (p *twoQueue) Remove(key string) {
+	if item, ok := p.items[key]; ok {
+		item.queue.Remove(item.element)
+		delete(p.items, key)
+	}
+
+	if element, ok := p.ghost[key]; ok {
+		p.ghosts.Remove(element)
+		delete(p.ghost, key)
+	}
+}
+
+func (p *twoQueue) Evict(except string) (string, bool) {
+	resident := p.recent.Len() + p.frequent.Len()
+
+	queues := []*list.List{p.frequent, p.recent}
+	if p.frequent.Len() == 0 || float64(p.recent.Len()) > twoQueueRecentRatio*float64(resident) {
+		queues = []*list.List{p.recent, p.frequent}
+	}
+
+	for _, queue := range queues {
+		for element := queue.Back(); element != nil; element = element.Prev() {
+			key := element.Value.(string)
+			if key == except {
+				continue
+			}
+
+			queue.Remove(element)
+			delete(p.items, key)
+			if queue == p.recent {
+				p.remember(key, resident-1)
+			}
+
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// Add key to the ghost history bounded by the number of resident keys
+func (p *twoQueue) remember(key string, resident int) {
+	p.ghost[key] = p.ghosts.PushFront(key)
+
+	limit := int(twoQueueGhostRatio * float64(resident))
+	if limit < 1 {
+		limit = 1
+	}
+
+	for p.ghosts.Len() > limit {
+		oldest := p.ghosts.Back()
+		p.ghosts.Remove(oldest)
+		delete(p.ghost, oldest.Value.(string))
+	}
+}
+
+///////////////////////////////////////
+// Random
+///////////////////////////////////////
+
+// Random keys are evicted
+type random struct {
+	keys  []string
+	index map[string]int
+}
+
+func NewRandom() EvictionPolicy {
+	return &random{
+		index: map[string]int{},
+	}
+}
+
+func (p *random) Name() string {
+	return "random"
+}
+
+func (p *random) Add(key string) {
+	p.index[key] = len(p.keys)
+	p.keys = append(p.keys, key)
+}
+
+func (p *random) Access(key string) {
+}
+
+func (p *random) Remove(key string) {
+	i, ok := p.index[key]
+	if !ok {
+		return
+	}
+
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.index[p.keys[i]] = i
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
+
+func (p *random) Evict(except string) (string, bool) {
+	if len(p.keys) == 0 || (len(p.keys) == 1 && p.keys[0] == except) {
+		return "", false
+	}
+
+	key := p.keys[rand.Intn(len(p.keys))]
+	for key == except {
+		key = p.keys[rand.Intn(len(p.keys))]
+	}
+
+	p.Remove(key)
+	return key, true
+}
+
+///////////////////////////////////////
+// Volatile LRU
+///////////////////////////////////////
+
+// Least recently used keys with a TTL are evicted first,
+// keys without a TTL are never evicted
+type volatileLRU struct {
+	volatile *lru
+	// Keys without a TTL
+	persistent *lru
+}
+
+func NewVolatileLRU() EvictionPolicy {
+	return &volatileLRU{
+		volatile:   NewLRU().(*lru),
+		persistent: NewLRU().(*lru),
+	}
+}
+
+func (p *volatileLRU) Name() string {
+	return "volatile-lru"
+}
+
+func (p *volatileLRU) Add(key string) {
+	p.persistent.Add(key)
+}
+
+func (p *volatileLRU) Access(key string) {
+	p.volatile.Access(key)
+	p.persistent.Access(key)
+}
+
+func (p *volatileLRU) Remove(key string) {
+	p.volatile.Remove(key)
+	p.persistent.Remove(key)
+}
+
+func (p *volatileLRU) Evict(except string) (string, bool) {
+	return p.volatile.Evict(except)
+}
+
+func (p *volatileLRU) SetVolatile(key string, volatile bool) {
+	from, to := p.persistent, p.volatile
+	if !volatile {
+		from, to = p.volatile, p.persistent
+	}
+
+	if _, ok := from.items[key]; ok {
+		from.Remove(key)
+		to.Add(key)
+	}
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestLFU(t *testing.T) {
+	t.Log("Testing LFU eviction...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 3, EvictionPolicy: NewLFU})
+
+	cache.SetString("k1", "v1")
+	cache.SetString("k2", "v2")
+	cache.SetString("k3", "v3")
+
+	for i := 0; i < 3; i++ {
+		cache.Get("k1")
+		cache.Get("k3")
+	}
+	cache.Get("k2")
+
+	cache.SetString("k4", "v4")
+
+	if _, err := cache.Get("k2"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	cache.SetString("k5", "v5")
+
+	if _, err := cache.Get("k4"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+}
+
+func Test2Q(t *testing.T) {
+	t.Log("Testing 2Q eviction...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 4, EvictionPolicy: New2Q})
+
+	cache.SetString("hot1", "v")
+	cache.SetString("hot2", "v")
+	cache.Get("hot1")
+	cache.Get("hot2")
+
+	// scan of keys used once must not flush the frequently used ones
+	for _, key := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		cache.SetString(key, "v")
+	}
+
+	for _, key := range []string{"hot1", "hot2", "s5"} {
+		if _, err := cache.Get(key); err != nil {
+			t.Errorf("Expected key '%s' to stay, but it was %v instead.", key, err)
+		}
+	}
+
+	if cache.Len() != 4 {
+		t.Errorf("Expected 4, but it was %d instead.", cache.Len())
+	}
+
+	// Keys evicted from the recent queue are remembered and promoted when stored again
+	policy := cache.eviction.policy.(*twoQueue)
+	if _, ok := policy.ghost["s3"]; !ok {
+		t.Fatalf("Expected evicted key 's3' to be a ghost, but ghosts were %v instead.", policy.ghost)
+	}
+
+	cache.SetString("s3", "v")
+	if item, ok := policy.items["s3"]; !ok || item.queue != policy.frequent {
+		t.Errorf("Expected key 's3' in the frequent queue, but it was %v instead.", item)
+	}
+	if _, ok := policy.ghost["s3"]; ok {
+		t.Errorf("Expected promoted key 's3' not to be a ghost anymore.")
+	}
+}
+
+func TestRandom(t *testing.T) {
+	t.Log("Testing random eviction...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 10, EvictionPolicy: NewRandom})
+
+	for i := 0; i < 100; i++ {
+		cache.SetInt(string(rune('a'+i%26))+string(rune('a'+i/26)), i)
+		cache.Remove("aa")
+	}
+
+	if cache.Len() != 10 {
+		t.Errorf("Expected 10, but it was %d instead.", cache.Len())
+	}
+}
+
+func TestVolatileLRU(t *testing.T) {
+	t.Log("Testing volatile-lru eviction...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 2, EvictionPolicy: NewVolatileLRU})
+
+	cache.SetString("persistent", "v")
+	cache.SetString("volatile", "v")
+	cache.SetTTL("volatile", 60000)
+	cache.SetString("k3", "v")
+
+	if _, err := cache.Get("volatile"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+
+	// nothing left to evict, the cache grows over its limit
+	cache.SetString("k4", "v")
+
+	if cache.Len() != 3 {
+		t.Errorf("Expected 3, but it was %d instead.", cache.Len())
+	}
+}
+
+func TestStatsEvictionPolicy(t *testing.T) {
+	t.Log("Testing eviction policy in Stats...")
+
+	cache := Alloc()
+	if cache.Stats().EvictionPolicy != "none" {
+		t.Errorf("Expected none, but it was %s instead.", cache.Stats().EvictionPolicy)
+	}
+
+	cache = AllocWithConfig(Config{MaxBytes: 1024})
+	if cache.Stats().EvictionPolicy != "lru" {
+		t.Errorf("Expected lru, but it was %s instead.", cache.Stats().EvictionPolicy)
+	}
+
+	cache = AllocWithConfig(Config{MaxEntries: 1, EvictionPolicy: New2Q})
+	if cache.Stats().EvictionPolicy != "2q" {
+		t.Errorf("Expected 2q, but it was %s instead.", cache.Stats().EvictionPolicy)
+	}
+}
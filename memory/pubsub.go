@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Messages buffered for a subscriber, it is dropped when it falls further behind
+const subscriberBuffer = 1024
+
+// Subscriptions by channel and by channel pattern
+type pubsub struct {
+	sync.Mutex
+	channels map[string]map[*Subscription]bool
+	patterns map[string]map[*Subscription]bool
+}
+
+func newPubSub() *pubsub {
+	return &pubsub{
+		channels: map[string]map[*Subscription]bool{},
+		patterns: map[string]map[*Subscription]bool{},
+	}
+}
+
+// Subscription Messages of subscribed channels and patterns in the order they were published
+// Messages are not stored, only subscribers present at the moment of publishing get them
+type Subscription struct {
+	pubsub *pubsub
+	// Guarded by the pubsub lock
+	channels map[string]bool
+	patterns map[string]bool
+	messages chan util.Message
+	closed   bool
+	err      error
+}
+
+// Subscribe to channels
+func (cache *CACHE) Subscribe(channels ...string) *Subscription {
+	sub := &Subscription{
+		pubsub:   cache.pubsub,
+		channels: map[string]bool{},
+		patterns: map[string]bool{},
+		messages: make(chan util.Message, subscriberBuffer),
+	}
+	sub.Subscribe(channels...)
+
+	return sub
+}
+
+// Subscribe to channels matching glob patterns, e.g. news.*
+func (cache *CACHE) PSubscribe(patterns ...string) *Subscription {
+	sub := cache.Subscribe()
+	sub.PSubscribe(patterns...)
+
+	return sub
+}
+
+// Send message to subscribers of the channel
+// Returns number of deliveries, a subscriber gets the message once per matching channel or pattern
+func (cache *CACHE) Publish(channel, message string) int {
+	ps := cache.pubsub
+	ps.Lock()
+	defer ps.Unlock()
+
+	deliveries := 0
+	for sub := range ps.channels[channel] {
+		if sub.deliver(util.Message{Channel: channel, Payload: message}) {
+			deliveries++
+		}
+	}
+	for pattern, subs := range ps.patterns {
+		if !util.MatchGlob(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			if sub.deliver(util.Message{Channel: channel, Pattern: pattern, Payload: message}) {
+				deliveries++
+			}
+		}
+	}
+
+	return deliveries
+}
+
+// Number of subscribers of the channel, including pattern ones
+func (cache *CACHE) NumSubscribers(channel string) int {
+	ps := cache.pubsub
+	ps.Lock()
+	defer ps.Unlock()
+
+	subs := map[*Subscription]bool{}
+	for sub := range ps.channels[channel] {
+		subs[sub] = true
+	}
+	for pattern, patternSubs := range ps.patterns {
+		if util.MatchGlob(pattern, channel) {
+			for sub := range patternSubs {
+				subs[sub] = true
+			}
+		}
+	}
+
+	return len(subs)
+}
+
+// Queue message without blocking the publisher, slow subscribers are dropped
+// Caller must hold the pubsub lock
+func (sub *Subscription) deliver(m util.Message) bool {
+	if sub.closed {
+		return false
+	}
+
+	select {
+	case sub.messages <- m:
+		return true
+	default:
+		sub.close(util.ErrorSubscriberTooSlow)
+		return false
+	}
+}
+
+// Channel of messages, closed by Close or when the subscriber falls behind
+func (sub *Subscription) Messages() <-chan util.Message {
+	return sub.messages
+}
+
+// Reason the subscription was dropped, nil if it is open or closed by Close
+func (sub *Subscription) Err() error {
+	sub.pubsub.Lock()
+	defer sub.pubsub.Unlock()
+
+	return sub.err
+}
+
+// Add channels to subscription
+func (sub *Subscription) Subscribe(channels ...string) {
+	sub.pubsub.Lock()
+	defer sub.pubsub.Unlock()
+
+	for _, channel := range channels {
+		sub.add(sub.pubsub.channels, sub.channels, channel)
+	}
+}
+
+// Add channel patterns to subscription
+func (sub *Subscription) PSubscribe(patterns ...string) {
+	sub.pubsub.Lock()
+	defer sub.pubsub.Unlock()
+
+	for _, pattern := range patterns {
+		sub.add(sub.pubsub.patterns, sub.patterns, pattern)
+	}
+}
+
+// Remove channels from subscription, all of them if none given
+func (sub *Subscription) Unsubscribe(channels ...string) {
+	sub.pubsub.Lock()
+	defer sub.pubsub.Unlock()
+
+	sub.removeAll(sub.pubsub.channels, sub.channels, channels)
+}
+
+// Remove channel patterns from subscription, all of them if none given
+func (sub *Subscription) PUnsubscribe(patterns ...string) {
+	sub.pubsub.Lock()
+	defer sub.pubsub.Unlock()
+
+	sub.removeAll(sub.pubsub.patterns, sub.patterns, patterns)
+}
+
+// Stop receiving messages and close the channel of messages
+func (sub *Subscription) Close() {
+	sub.pubsub.Lock()
+	defer sub.pubsub.Unlock()
+
+	sub.close(nil)
+}
+
+// Caller must hold the pubsub lock
+func (sub *Subscription) close(err error) {
+	if sub.closed {
+		return
+	}
+
+	sub.removeAll(sub.pubsub.channels, sub.channels, nil)
+	sub.removeAll(sub.pubsub.patterns, sub.patterns, nil)
+	sub.closed = true
+	sub.err = err
+	close(sub.messages)
+}
+
+// Caller must hold the pubsub lock
+func (sub *Subscription) add(index map[string]map[*Subscription]bool, own map[string]bool, name string) {
+	if sub.closed {
+		return
+	}
+
+	subs, ok := index[name]
+	if !ok {
+		subs = map[*Subscription]bool{}
+		index[name] = subs
+	}
+	subs[sub] = true
+	own[name] = true
+}
+
+// Caller must hold the pubsub lock
+func (sub *Subscription) removeAll(index map[string]map[*Subscription]bool, own map[string]bool, names []string) {
+	if len(names) == 0 {
+		for name := range own {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		if !own[name] {
+			continue
+		}
+		delete(own, name)
+		delete(index[name], sub)
+		if len(index[name]) == 0 {
+			delete(index, name)
+		}
+	}
+}
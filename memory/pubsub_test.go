@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestPubSub(t *testing.T) {
+	t.Log("Testing publish and subscribe...")
+
+	cache := Alloc()
+	sub := cache.Subscribe("news")
+	defer sub.Close()
+	psub := cache.PSubscribe("news.*")
+	defer psub.Close()
+
+	if n := cache.Publish("news", "m1"); n != 1 {
+		t.Errorf("Expected 1 delivery, but it was %d instead.", n)
+	}
+	if n := cache.Publish("news.sport", "m2"); n != 1 {
+		t.Errorf("Expected 1 delivery, but it was %d instead.", n)
+	}
+	if n := cache.Publish("weather", "m3"); n != 0 {
+		t.Errorf("Expected no deliveries, but it was %d instead.", n)
+	}
+
+	expected := util.Message{Channel: "news", Payload: "m1"}
+	if m := <-sub.Messages(); m != expected {
+		t.Errorf("Expected %v, but it was %v instead.", expected, m)
+	}
+	expected = util.Message{Channel: "news.sport", Pattern: "news.*", Payload: "m2"}
+	if m := <-psub.Messages(); m != expected {
+		t.Errorf("Expected %v, but it was %v instead.", expected, m)
+	}
+
+	// Subscription to both the channel and a matching pattern gets the message twice
+	sub.PSubscribe("n*")
+	if n := cache.NumSubscribers("news"); n != 1 {
+		t.Errorf("Expected 1 subscriber, but it was %d instead.", n)
+	}
+	if n := cache.Publish("news", "m4"); n != 2 {
+		t.Errorf("Expected 2 deliveries, but it was %d instead.", n)
+	}
+	<-sub.Messages()
+	<-sub.Messages()
+
+	sub.Unsubscribe()
+	sub.PUnsubscribe("n*")
+	if n := cache.Publish("news", "m5"); n != 0 {
+		t.Errorf("Expected no deliveries, but it was %d instead.", n)
+	}
+
+	psub.Close()
+	if _, ok := <-psub.Messages(); ok {
+		t.Errorf("Expected closed channel of messages.")
+	}
+	if err := psub.Err(); err != nil {
+		t.Errorf("Expected nil, but it was %v instead.", err)
+	}
+	if n := cache.NumSubscribers("news.sport"); n != 0 {
+		t.Errorf("Expected no subscribers, but it was %d instead.", n)
+	}
+}
+
+func TestPubSubSlowSubscriber(t *testing.T) {
+	t.Log("Testing slow subscriber is dropped...")
+
+	cache := Alloc()
+	slow := cache.Subscribe("events")
+	fast := cache.Subscribe("events")
+	defer fast.Close()
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		cache.Publish("events", strconv.Itoa(i))
+		if m := <-fast.Messages(); m.Payload != strconv.Itoa(i) {
+			t.Fatalf("Expected %d, but it was %s instead.", i, m.Payload)
+		}
+	}
+
+	n := 0
+	for range slow.Messages() {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("Expected %d buffered messages, but it was %d instead.", subscriberBuffer, n)
+	}
+	if err := slow.Err(); err != util.ErrorSubscriberTooSlow {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorSubscriberTooSlow, err)
+	}
+}
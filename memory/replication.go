@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Max bytes queued for a replica before it is dropped as too slow
+const feedMaxBytes = 64 << 20
+
+var pingLine = []byte(`{"op":"ping","key":""}` + "\n")
+
+// Marks the end of the dump, replicas swap the loaded copy in on it
+var syncedLine = []byte(`{"op":"synced","key":""}` + "\n")
+
+// Feeds of writes for replicas
+type replication struct {
+	// Number of open feeds, read without the lock
+	feeds int32
+
+	sync.Mutex
+	open map[*Feed]bool
+}
+
+// Feed Stream of ops replicating the cache
+// It starts with a dump of all entries ended by a synced op, followed by every write recorded after it.
+// Shards are dumped one by one, ops of already dumped shards are held back
+// until the dump is over, like in the append-only log rewrite.
+// Writers are never blocked by a feed, a feed that falls behind is dropped.
+type Feed struct {
+	replication *replication
+
+	sync.Mutex
+	cond  *sync.Cond
+	queue [][]byte
+	size  int
+	// Shards dumped so far, nil when the dump is over
+	cut         map[*shard]bool
+	pending     [][]byte
+	pendingSize int
+	err         error
+}
+
+// Open replication feed, caller must close it
+func (cache *CACHE) Feed() *Feed {
+	f := &Feed{replication: cache.replication, cut: map[*shard]bool{}}
+	f.cond = sync.NewCond(f)
+
+	r := cache.replication
+	r.Lock()
+	if r.open == nil {
+		r.open = map[*Feed]bool{}
+	}
+	r.open[f] = true
+	atomic.AddInt32(&r.feeds, 1)
+	r.Unlock()
+
+	go f.dump(cache.shards)
+
+	return f
+}
+
+func (f *Feed) dump(shards []*shard) {
+	var dump bytes.Buffer
+	for _, s := range shards {
+		dump.Reset()
+
+		s.RLock()
+		f.Lock()
+		f.cut[s] = true
+		f.Unlock()
+
+		t := now()
+		for key, e := range s.data {
+			if e.expireAt != 0 && e.expired(t) {
+				continue
+			}
+			if line, err := encodeOp("set", key, e.value, e.expireAt); err == nil {
+				dump.Write(line)
+			}
+		}
+		s.RUnlock()
+
+		lines := bytes.SplitAfter(dump.Bytes(), []byte("\n"))
+
+		f.Lock()
+		// Wait for the replica to catch up instead of queueing the whole dump
+		for f.size > feedMaxBytes && f.err == nil {
+			f.cond.Wait()
+		}
+		if f.err != nil {
+			f.Unlock()
+			return
+		}
+		for _, line := range lines {
+			if len(line) > 0 {
+				f.push(append([]byte(nil), line...))
+			}
+		}
+		f.Unlock()
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.push(syncedLine)
+	for _, line := range f.pending {
+		f.push(line)
+	}
+	f.pending = nil
+	f.pendingSize = 0
+	f.cut = nil
+}
+
+// Caller must hold the feed lock
+func (f *Feed) push(line []byte) {
+	f.queue = append(f.queue, line)
+	f.size += len(line)
+	f.cond.Broadcast()
+}
+
+// Send op to all feeds
+// Caller must hold the write lock of the shard
+func (r *replication) publish(s *shard, line []byte) {
+	r.Lock()
+	defer r.Unlock()
+
+	for f := range r.open {
+		f.publish(s, line)
+	}
+}
+
+func (f *Feed) publish(s *shard, line []byte) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.err != nil {
+		return
+	}
+
+	if f.cut != nil {
+		// Not dumped shards will be dumped with this op applied
+		if f.cut[s] {
+			f.pending = append(f.pending, line)
+			f.pendingSize += len(line)
+		}
+	} else {
+		f.push(line)
+	}
+
+	if f.size > feedMaxBytes && f.cut == nil || f.pendingSize > feedMaxBytes {
+		f.err = util.ErrorReplicaTooSlow
+		f.cond.Broadcast()
+	}
+}
+
+// Next op line, blocks until there is one
+// Returns error when the feed is closed or dropped
+func (f *Feed) Next() ([]byte, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	for len(f.queue) == 0 && f.err == nil {
+		f.cond.Wait()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	line := f.queue[0]
+	f.queue[0] = nil
+	f.queue = f.queue[1:]
+	f.size -= len(line)
+	f.cond.Broadcast()
+
+	return line, nil
+}
+
+// Number of ops ready to be read without blocking
+func (f *Feed) Len() int {
+	f.Lock()
+	defer f.Unlock()
+
+	return len(f.queue)
+}
+
+// Queue a no-op if the feed is idle, so replicas can tell a silent leader from a dead one
+func (f *Feed) Ping() {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.err == nil && f.cut == nil && len(f.queue) == 0 {
+		f.push(pingLine)
+	}
+}
+
+// Stop feeding, pending Next calls return ErrorFeedClosed
+func (f *Feed) Close() {
+	r := f.replication
+	r.Lock()
+	if r.open[f] {
+		delete(r.open, f)
+		atomic.AddInt32(&r.feeds, -1)
+	}
+	r.Unlock()
+
+	f.Lock()
+	defer f.Unlock()
+
+	if f.err == nil {
+		f.err = util.ErrorFeedClosed
+	}
+	f.queue = nil
+	f.size = 0
+	f.pending = nil
+	f.pendingSize = 0
+	f.cond.Broadcast()
+}
+
+// Replace all entries with the ones read from a replication feed
+// The dump is loaded aside and swapped in once complete, so readers never see a partial copy
+// Applies ops until the reader fails, returns its error
+func (cache *CACHE) Follow(r io.Reader) error {
+	loading := AllocWithConfig(Config{Shards: len(cache.shards)})
+	defer loading.Close()
+	target := &loading
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+
+		var op appendOp
+		if err := json.Unmarshal(line, &op); err != nil {
+			return util.ErrorInvalidAppendLog
+		}
+
+		if op.Op == "synced" {
+			cache.replace(&loading)
+			target = cache
+			continue
+		}
+
+		if err := target.apply(op); err != nil {
+			return err
+		}
+	}
+}
+
+// Replace all entries with the live ones of loaded cache at once
+// Keys missing from loaded cache are removed, all others are set again
+func (cache *CACHE) replace(loaded *CACHE) {
+	defer cache.evictOverLimit("")
+	lockShards(cache.shards)
+	defer unlockShards(cache.shards)
+	loaded.rlockAll()
+	defer loaded.runlockAll()
+
+	t := now()
+	for _, s := range cache.shards {
+		for key := range s.data {
+			if e, ok := loaded.shard(key).data[key]; !ok || e.expired(t) {
+				s.delete(key, util.EventRemove)
+				s.record("del", key, nil, 0)
+			}
+		}
+	}
+
+	for _, l := range loaded.shards {
+		for key, e := range l.data {
+			if e.expired(t) {
+				continue
+			}
+			s := cache.shard(key)
+			s.set(key, e.value, e.expireAt)
+			s.record("set", key, e.value, e.expireAt)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package memory
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Stream feed into follower, returns channel with the result of Follow
+func follow(feed *Feed, follower *CACHE) chan error {
+	r, w := io.Pipe()
+	go func() {
+		for {
+			line, err := feed.Next()
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+			if _, err := w.Write(line); err != nil {
+				return
+			}
+		}
+	}()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- follower.Follow(r)
+	}()
+
+	return result
+}
+
+func sameContent(expected, actual *CACHE) bool {
+	if expected.Len() != actual.Len() {
+		return false
+	}
+
+	// Dumps are made under the lock, the follower may be applying writes
+	for _, key := range expected.Keys() {
+		v1, _ := expected.Dump(key)
+		v2, err := actual.Dump(key)
+		if err != nil || !bytes.Equal(v1, v2) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Wait for the follower to catch up with the leader
+func waitSameContent(t *testing.T, leader, follower *CACHE) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !sameContent(leader, follower) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkSameContent(t, leader, follower)
+}
+
+func TestFeed(t *testing.T) {
+	t.Log("Testing replication feed...")
+
+	leader := Alloc()
+	leader.SetString("old", "v")
+	leader.SAdd("oldset", "m1")
+
+	follower := Alloc()
+	follower.SetString("stale", "v")
+
+	feed := leader.Feed()
+	result := follow(feed, &follower)
+
+	waitSameContent(t, &leader, &follower)
+
+	writeAllOps(&leader)
+	waitSameContent(t, &leader, &follower)
+
+	ttl, err := follower.GetTTL("int")
+	if err != nil || ttl <= 0 || ttl > 60000 {
+		t.Errorf("Expected ttl of 'int' to be replicated, but it was %d (%v) instead.", ttl, err)
+	}
+
+	feed.Close()
+	if err := <-result; err != util.ErrorFeedClosed {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorFeedClosed, err)
+	}
+}
+
+func TestFollowSwap(t *testing.T) {
+	t.Log("Testing follower keeps its entries until the dump is complete...")
+
+	follower := Alloc()
+	follower.SetString("stale", "v")
+	follower.SetString("kept", "old")
+
+	r, w := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		result <- follower.Follow(r)
+	}()
+
+	for _, key := range []string{"kept", "new"} {
+		line, _ := encodeOp("set", key, "v", 0)
+		w.Write(line)
+	}
+	w.Write(pingLine)
+	if v, err := follower.Get("stale"); err != nil || v != "v" {
+		t.Errorf("Expected stale entry during the dump, but it was %q (%v) instead.", v, err)
+	}
+	if _, err := follower.Get("new"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected no new entry during the dump, but it was %v instead.", err)
+	}
+
+	w.Write(syncedLine)
+	w.Write(pingLine)
+	if _, err := follower.Get("stale"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected stale entry removed, but it was %v instead.", err)
+	}
+	if v, _ := follower.Get("kept"); v != "v" {
+		t.Errorf("Expected v, but it was %v instead.", v)
+	}
+	if follower.Len() != 2 {
+		t.Errorf("Expected 2 entries, but it was %d instead.", follower.Len())
+	}
+
+	w.CloseWithError(util.ErrorFeedClosed)
+	if err := <-result; err != util.ErrorFeedClosed {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorFeedClosed, err)
+	}
+}
+
+func TestFeedConcurrent(t *testing.T) {
+	t.Log("Testing replication feed with concurrent writers...")
+
+	leader := AllocWithConfig(Config{Shards: 4})
+	for i := 0; i < 1000; i++ {
+		leader.SetInt("key"+strconv.Itoa(i), i)
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			<-start
+			for i := 0; i < 500; i++ {
+				key := "key" + strconv.Itoa((w*500+i)%1000)
+				leader.Increment(key)
+				leader.SAdd("set"+strconv.Itoa(w), strconv.Itoa(i))
+				if i%10 == 0 {
+					leader.Remove(key)
+				}
+			}
+		}(w)
+	}
+
+	follower := Alloc()
+	feed := leader.Feed()
+	close(start)
+	result := follow(feed, &follower)
+
+	wg.Wait()
+	waitSameContent(t, &leader, &follower)
+
+	feed.Close()
+	<-result
+}
+
+func TestFeedClose(t *testing.T) {
+	t.Log("Testing closing of an idle replication feed...")
+
+	cache := Alloc()
+	feed := cache.Feed()
+
+	// Empty cache is dumped at once
+	line, err := feed.Next()
+	if err != nil || string(line) != string(syncedLine) {
+		t.Errorf("Expected end of dump, but it was %q (%v) instead.", line, err)
+	}
+
+	feed.Ping()
+	feed.Ping()
+	line, err = feed.Next()
+	if err != nil || string(line) != string(pingLine) {
+		t.Errorf("Expected ping, but it was %q (%v) instead.", line, err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := feed.Next()
+		done <- err
+	}()
+
+	feed.Close()
+	if err := <-done; err != util.ErrorFeedClosed {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorFeedClosed, err)
+	}
+
+	// Writes after close are not fed
+	cache.SetString("key", "v")
+	if feed.Len() != 0 {
+		t.Errorf("Expected empty feed, but it was %d instead.", feed.Len())
+	}
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"github.com/anevsky/cachego/util"
+)
+
+// Keys returned by a scan call when the count hint is not set
+const defaultScanCount = 10
+
+// Value types accepted by the type filter of Scan
+var scanTypes = map[string]bool{"string": true, "int": true, "list": true, "dict": true, "set": true, "zset": true}
+
+// Next page of keys after cursor, start with cursor 0
+// Returns the cursor of the next page, 0 when the scan is over
+//
+// Cursor holds the shard index in the high 32 bits and the hash of the next key in the low ones,
+// so every key present during the whole scan is returned exactly once, while keys
+// added or removed in between may be missed. Count is a hint: a page may hold a few more keys,
+// or fewer, even none, when Match or Type filter them out.
+func (cache *CACHE) Scan(cursor uint64, options util.ScanOptions) ([]string, uint64, error) {
+	if options.Type != "" && !scanTypes[options.Type] {
+		return nil, 0, util.ErrorUnknownType
+	}
+
+	count := options.Count
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	index := int(cursor >> 32)
+	from := cursor & 0xFFFFFFFF
+
+	result := []string{}
+	for ; index < len(cache.shards); index, from = index+1, 0 {
+		var next uint64
+		var examined int
+		result, examined, next = cache.shards[index].scan(result, from, count, options)
+		count -= examined
+		if next <= 0xFFFFFFFF {
+			return result, uint64(index)<<32 | next, nil
+		}
+		if count <= 0 {
+			index++
+			break
+		}
+	}
+
+	if index >= len(cache.shards) {
+		return result, 0, nil
+	}
+
+	return result, uint64(index) << 32, nil
+}
+
+// Append to keys those with hash from the given one, examining about count keys in hash order
+// Keys sharing a hash are examined together, so a cursor never splits them
+// Returns the number of examined keys and hash of the next key, above 0xFFFFFFFF when the shard is over
+func (s *shard) scan(keys []string, from uint64, count int, options util.ScanOptions) ([]string, int, uint64) {
+	s.RLock()
+	defer s.RUnlock()
+
+	at := now()
+	examined := 0
+	node := s.hashes.firstFrom(float64(from))
+	for ; node != nil; node = node.levels[0].forward {
+		if examined >= count && node.backward != nil && node.backward.score != node.score {
+			return keys, examined, uint64(node.score)
+		}
+		examined++
+
+		key := node.member
+		e := s.data[key]
+		if e.expired(at) {
+			continue
+		}
+		if options.Type != "" && typeName(e.value) != options.Type {
+			continue
+		}
+		if options.Match != "" && !util.MatchGlob(options.Match, key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, examined, 1 << 32
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Collect keys of all pages of the scan
+func scanAll(t *testing.T, cache *CACHE, options util.ScanOptions) map[string]int {
+	seen := map[string]int{}
+	cursor := uint64(0)
+	for pages := 0; ; pages++ {
+		if pages > 10000 {
+			t.Fatal("Expected scan to end.")
+		}
+
+		keys, next, err := cache.Scan(cursor, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+
+		if next == 0 {
+			return seen
+		}
+		cursor = next
+	}
+}
+
+func TestScan(t *testing.T) {
+	t.Log("Testing scan of all keys...")
+
+	cache := Alloc()
+	for i := 0; i < 1000; i++ {
+		cache.SetString("user:"+strconv.Itoa(i), "v")
+	}
+	for i := 0; i < 100; i++ {
+		cache.SetInt("counter:"+strconv.Itoa(i), i)
+	}
+
+	for _, count := range []int{0, 1, 7, 100, 5000} {
+		seen := scanAll(t, &cache, util.ScanOptions{Count: count})
+		if len(seen) != 1100 {
+			t.Errorf("Expected 1100 keys with count %d, but it was %d instead.", count, len(seen))
+		}
+		for key, n := range seen {
+			if n != 1 {
+				t.Errorf("Expected %s once, but it was %d times instead.", key, n)
+			}
+		}
+	}
+
+	seen := scanAll(t, &cache, util.ScanOptions{Match: "user:1?"})
+	if len(seen) != 10 {
+		t.Errorf("Expected 10 keys, but it was %d instead.", len(seen))
+	}
+
+	seen = scanAll(t, &cache, util.ScanOptions{Type: "int", Count: 20})
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 keys, but it was %d instead.", len(seen))
+	}
+
+	seen = scanAll(t, &cache, util.ScanOptions{Type: "dict"})
+	if len(seen) != 0 {
+		t.Errorf("Expected no keys, but it was %d instead.", len(seen))
+	}
+
+	if _, _, err := cache.Scan(0, util.ScanOptions{Type: "blob"}); err != util.ErrorUnknownType {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorUnknownType, err)
+	}
+
+	empty := Alloc()
+	keys, next, err := empty.Scan(0, util.ScanOptions{})
+	if len(keys) != 0 || next != 0 || err != nil {
+		t.Errorf("Expected empty scan, but it was %v, %d, %v instead.", keys, next, err)
+	}
+}
+
+func TestScanConcurrentWrites(t *testing.T) {
+	t.Log("Testing scan returns keys present during the whole scan...")
+
+	cache := Alloc()
+	for i := 0; i < 500; i++ {
+		cache.SetString("old:"+strconv.Itoa(i), "v")
+	}
+
+	seen := map[string]int{}
+	cursor := uint64(0)
+	for i := 0; ; i++ {
+		keys, next, err := cache.Scan(cursor, util.ScanOptions{Count: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+
+		// Keyspace grows and shrinks in between
+		cache.SetString("new:"+strconv.Itoa(i), "v")
+		cache.Remove("new:" + strconv.Itoa(i-1))
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < 500; i++ {
+		if key := "old:" + strconv.Itoa(i); seen[key] != 1 {
+			t.Errorf("Expected %s once, but it was %d times instead.", key, seen[key])
+		}
+	}
+}
+
+func TestScanPageSize(t *testing.T) {
+	t.Log("Testing scan pages hold about count keys...")
+
+	cache := Alloc()
+	for i := 0; i < 10000; i++ {
+		cache.SetInt("key:"+strconv.Itoa(i), i)
+	}
+
+	pages := 0
+	cursor := uint64(0)
+	for {
+		keys, next, _ := cache.Scan(cursor, util.ScanOptions{Count: 100})
+		if next == 0 {
+			break
+		}
+		if len(keys) < 100 || len(keys) > 110 {
+			t.Errorf("Expected about 100 keys, but it was %d instead.", len(keys))
+		}
+		cursor = next
+		pages++
+	}
+
+	if pages < 99 {
+		t.Errorf("Expected at least 99 full pages, but it was %d instead.", pages)
+	}
+}
@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"github.com/anevsky/cachego/util"
+)
+
+// Add members to set, the set is created if key is missing
+// Returns number of added members
+func (cache *CACHE) SAdd(key string, members ...string) (int, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.sAdd(key, members...)
+}
+
+// Caller must hold the write lock
+func (s *shard) sAdd(key string, members ...string) (int, error) {
+	e, success := s.lookup(key)
+	if !success {
+		set := util.NewSet(members...)
+		if len(set) > 0 {
+			s.set(key, set, 0)
+			s.record("sadd", key, members, 0)
+		}
+		return len(set), nil
+	}
+
+	set, success := e.value.(util.Set)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	added := 0
+	size := e.size
+	for _, member := range members {
+		if !set.Has(member) {
+			set[member] = struct{}{}
+			size += memberSize(member)
+			added++
+		}
+	}
+
+	if added == 0 {
+		return 0, nil
+	}
+
+	s.storeSized(key, set, size)
+	s.record("sadd", key, members, 0)
+
+	return added, nil
+}
+
+// Remove members from set, the key is removed with its last member
+// Returns number of removed members
+func (cache *CACHE) SRem(key string, members ...string) (int, error) {
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.sRem(key, members...)
+}
+
+// Caller must hold the write lock
+func (s *shard) sRem(key string, members ...string) (int, error) {
+	e, success := s.lookup(key)
+	if !success {
+		return 0, util.ErrorKeyNotFound
+	}
+
+	set, success := e.value.(util.Set)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	removed := 0
+	size := e.size
+	for _, member := range members {
+		if set.Has(member) {
+			delete(set, member)
+			size -= memberSize(member)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if len(set) == 0 {
+		s.delete(key, util.EventRemove)
+	} else {
+		s.storeSized(key, set, size)
+	}
+	s.record("srem", key, members, 0)
+
+	return removed, nil
+}
+
+func (cache *CACHE) SIsMember(key, member string) (bool, error) {
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	e, success := shard.lookup(key)
+	if err := cache.counters.read(success); err != nil {
+		return false, err
+	}
+
+	shard.eviction.access(key)
+
+	set, success := e.value.(util.Set)
+	if !success {
+		return false, cache.counters.wrongTypeError()
+	}
+
+	return set.Has(member), nil
+}
+
+// Copy of set members
+func (cache *CACHE) SMembers(key string) (util.Set, error) {
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	e, success := shard.lookup(key)
+	if err := cache.counters.read(success); err != nil {
+		return nil, err
+	}
+
+	shard.eviction.access(key)
+
+	set, success := e.value.(util.Set)
+	if !success {
+		return nil, cache.counters.wrongTypeError()
+	}
+
+	return copySet(set), nil
+}
+
+// Members present in all sets, missing keys are empty sets
+func (cache *CACHE) SInter(keys ...string) (util.Set, error) {
+	return cache.combineSets(keys, func(result, set util.Set, first bool) util.Set {
+		if first {
+			return copySet(set)
+		}
+
+		for member := range result {
+			if !set.Has(member) {
+				delete(result, member)
+			}
+		}
+
+		return result
+	})
+}
+
+// Members present in any set, missing keys are empty sets
+func (cache *CACHE) SUnion(keys ...string) (util.Set, error) {
+	return cache.combineSets(keys, func(result, set util.Set, first bool) util.Set {
+		for member := range set {
+			result[member] = struct{}{}
+		}
+
+		return result
+	})
+}
+
+// Members of the first set missing in other sets, missing keys are empty sets
+func (cache *CACHE) SDiff(keys ...string) (util.Set, error) {
+	return cache.combineSets(keys, func(result, set util.Set, first bool) util.Set {
+		if first {
+			return copySet(set)
+		}
+
+		for member := range set {
+			delete(result, member)
+		}
+
+		return result
+	})
+}
+
+// Fold sets stored by keys under read locks of all their shards
+func (cache *CACHE) combineSets(keys []string, combine func(result, set util.Set, first bool) util.Set) (util.Set, error) {
+	shards := cache.shardsOf(keys)
+	rlockShards(shards)
+	defer runlockShards(shards)
+
+	result := util.Set{}
+	for i, key := range keys {
+		set := util.Set{}
+
+		shard := cache.shard(key)
+		if e, ok := shard.lookup(key); ok {
+			v, success := e.value.(util.Set)
+			if !success {
+				return nil, cache.counters.wrongTypeError()
+			}
+			set = v
+			shard.eviction.access(key)
+		}
+
+		result = combine(result, set, i == 0)
+	}
+
+	return result, nil
+}
+
+func copySet(set util.Set) util.Set {
+	result := make(util.Set, len(set))
+	for member := range set {
+		result[member] = struct{}{}
+	}
+
+	return result
+}
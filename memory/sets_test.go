@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestSAdd(t *testing.T) {
+	t.Log("Testing SAdd method...")
+
+	cache := Alloc()
+
+	n, err := cache.SAdd("setTest", "a", "b", "a")
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2, but it was %d instead.", n)
+	}
+
+	n, err = cache.SAdd("setTest", "b", "c")
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", n)
+	}
+
+	v, err := cache.Get("setTest")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(v, util.NewSet("a", "b", "c")) {
+		t.Errorf("Expected [a b c], but it was %v instead.", v)
+	}
+
+	cache.SetString("stringTest", "hi alex")
+	if _, err := cache.SAdd("stringTest", "a"); err != util.ErrorWrongType {
+		t.Errorf("Expected ErrorWrongType, but it was %v instead.", err)
+	}
+}
+
+func TestSRem(t *testing.T) {
+	t.Log("Testing SRem method...")
+
+	cache := Alloc()
+
+	cache.SAdd("setTest", "a", "b")
+
+	n, err := cache.SRem("setTest", "a", "c")
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", n)
+	}
+
+	cache.SRem("setTest", "b")
+	if _, err := cache.Get("setTest"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+}
+
+func TestSIsMember(t *testing.T) {
+	t.Log("Testing SIsMember method...")
+
+	cache := Alloc()
+
+	cache.SAdd("setTest", "a")
+
+	v, err := cache.SIsMember("setTest", "a")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != true {
+		t.Errorf("Expected %t, but it was %t instead.", true, v)
+	}
+
+	v, err = cache.SIsMember("setTest", "b")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != false {
+		t.Errorf("Expected %t, but it was %t instead.", false, v)
+	}
+}
+
+func TestSMembers(t *testing.T) {
+	t.Log("Testing SMembers method...")
+
+	cache := Alloc()
+
+	cache.SAdd("setTest", "a", "b")
+
+	v, err := cache.SMembers("setTest")
+	if err != nil {
+		t.Error(err)
+	}
+
+	v["c"] = struct{}{}
+	n, _ := cache.SAdd("setTest", "c")
+	if n != 1 {
+		t.Errorf("Expected SMembers to return a copy, but 'c' was already added.")
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	t.Log("Testing SInter, SUnion and SDiff methods...")
+
+	cache := Alloc()
+
+	cache.SAdd("s1", "a", "b", "c")
+	cache.SAdd("s2", "b", "c", "d")
+	cache.SAdd("s3", "c", "e")
+
+	v, err := cache.SInter("s1", "s2", "s3")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(v, util.NewSet("c")) {
+		t.Errorf("Expected [c], but it was %v instead.", v.Members())
+	}
+
+	v, err = cache.SUnion("s1", "s3", "missing")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(v, util.NewSet("a", "b", "c", "e")) {
+		t.Errorf("Expected [a b c e], but it was %v instead.", v.Members())
+	}
+
+	v, err = cache.SDiff("s1", "s2")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(v, util.NewSet("a")) {
+		t.Errorf("Expected [a], but it was %v instead.", v.Members())
+	}
+
+	v, err = cache.SInter("s1", "missing")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(v) != 0 {
+		t.Errorf("Expected empty set, but it was %v instead.", v.Members())
+	}
+
+	cache.SetInt("intTest", 1)
+	if _, err := cache.SUnion("s1", "intTest"); err != util.ErrorWrongType {
+		t.Errorf("Expected ErrorWrongType, but it was %v instead.", err)
+	}
+
+	if cache.Stats().KeysByType["set"] != 3 {
+		t.Errorf("Expected 3 sets, but it was %v instead.", cache.Stats().KeysByType)
+	}
+}
@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Default number of shards the keyspace is split over
+const defaultShards = 16
+
+// Part of the keyspace guarded by its own lock
+type shard struct {
+	sync.RWMutex
+	data map[string]*entry
+	// Keys ordered by hash, so scans seek to their cursor
+	hashes   *skiplist
+	eviction *evictor
+	// Number of keys by value type name
+	types map[string]int
+	// Guarded by the shard write lock
+	deadlines   deadlineQueue
+	expiry      *expiry
+	counters    *counters
+	appendLog   *appendLog
+	replication *replication
+	watchers    *watchers
+	// Last version given to an entry, versions are never reused by the shard
+	// Starts at the allocation time in nanoseconds, so a restarted cache doesn't reuse old versions
+	version uint64
+	// Writes of the running transaction, shared by its shards, nil outside of transactions
+	tx *txLog
+}
+
+func newShard(cache *CACHE) *shard {
+	return &shard{
+		data:        map[string]*entry{},
+		hashes:      newSkiplist(),
+		version:     uint64(now()),
+		eviction:    cache.eviction,
+		types:       map[string]int{},
+		expiry:      cache.expiry,
+		counters:    cache.counters,
+		appendLog:   cache.appendLog,
+		replication: cache.replication,
+		watchers:    cache.watchers,
+	}
+}
+
+// Shard owning the key
+func (cache *CACHE) shard(key string) *shard {
+	return cache.shards[cache.shardIndex(key)]
+}
+
+func (cache *CACHE) shardIndex(key string) int {
+	return int(fnv32a(key) % uint32(len(cache.shards)))
+}
+
+// Shards owning the keys without duplicates, in lock order
+func (cache *CACHE) shardsOf(keys []string) []*shard {
+	owned := make([]bool, len(cache.shards))
+	for _, key := range keys {
+		owned[cache.shardIndex(key)] = true
+	}
+
+	result := []*shard{}
+	for i, ok := range owned {
+		if ok {
+			result = append(result, cache.shards[i])
+		}
+	}
+
+	return result
+}
+
+func rlockShards(shards []*shard) {
+	for _, s := range shards {
+		s.RLock()
+	}
+}
+
+func lockShards(shards []*shard) {
+	for _, s := range shards {
+		s.Lock()
+	}
+}
+
+func unlockShards(shards []*shard) {
+	for _, s := range shards {
+		s.Unlock()
+	}
+}
+
+func runlockShards(shards []*shard) {
+	for _, s := range shards {
+		s.RUnlock()
+	}
+}
+
+// Lock all shards for reading in a fixed order to get a consistent view
+func (cache *CACHE) rlockAll() {
+	rlockShards(cache.shards)
+}
+
+func (cache *CACHE) runlockAll() {
+	runlockShards(cache.shards)
+}
+
+// Inlined FNV-1a, hash/fnv allocates on every call
+func fnv32a(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+
+	return hash
+}
+
+// Live entry by key, expired entries are treated as missing
+// Caller must hold the read lock
+func (s *shard) lookup(key string) (*entry, bool) {
+	e, ok := s.data[key]
+	if !ok || (e.expireAt != 0 && e.expired(now())) {
+		return nil, false
+	}
+
+	return e, true
+}
+
+// Store value by key replacing its TTL with the given deadline, 0 - no TTL
+// Caller must hold the write lock
+func (s *shard) set(key string, value interface{}, expireAt int64) {
+	s.write(key, value, sizeOf(key, value), util.EventSet)
+	s.setExpiry(key, s.data[key], expireAt)
+}
+
+// Store value by key keeping its TTL
+// and evict other keys if the shard is over its limits
+// Caller must hold the write lock
+func (s *shard) store(key string, value interface{}) {
+	s.storeSized(key, value, sizeOf(key, value))
+}
+
+// Store value of already known size, so collections growing in place
+// don't have to be measured from scratch
+// Caller must hold the write lock
+func (s *shard) storeSized(key string, value interface{}, size int64) {
+	s.write(key, value, size, util.EventUpdate)
+}
+
+// Store value reported to watchers as the given event
+// Caller must hold the write lock
+func (s *shard) write(key string, value interface{}, size int64, event string) {
+	if _, ok := s.lookup(key); !ok && s.delete(key, util.EventExpire) {
+		s.counters.expire()
+	}
+
+	s.version++
+	if e, ok := s.data[key]; ok {
+		s.eviction.resize(0, size-e.size)
+		s.types[typeName(e.value)]--
+		e.value = value
+		e.size = size
+		e.version = s.version
+		s.eviction.access(key)
+	} else {
+		s.data[key] = &entry{value: value, size: size, version: s.version}
+		s.hashes.insert(float64(fnv32a(key)), key)
+		s.eviction.resize(1, size)
+		s.eviction.add(key)
+	}
+	s.types[typeName(value)]++
+	s.counters.set()
+	s.notify(event, key, value)
+}
+
+// Evict keys other than except while the cache is over its limits
+// Victims may live in any shard, so the caller must not hold shard locks:
+// writers defer it before locking, then it runs once the locks are released.
+// Transactions evict once they are over, so rollback doesn't have to bring victims back.
+func (cache *CACHE) evictOverLimit(except string) {
+	for cache.eviction.overLimit() {
+		victim, ok := cache.eviction.evict(except)
+		if !ok {
+			return
+		}
+
+		s := cache.shard(victim)
+		s.Lock()
+		// Victim may have been removed since the policy gave it up,
+		// drop keeps whatever the policy remembers of it, e.g. 2Q ghosts
+		if s.drop(victim, util.EventEvict) {
+			s.counters.evict()
+			s.record("del", victim, nil, 0)
+		}
+		s.Unlock()
+	}
+}
+
+// Delete key with its bookkeeping, returns false if key is missing
+// Watchers get the given event
+// Caller must hold the write lock
+func (s *shard) delete(key string, event string) bool {
+	if !s.drop(key, event) {
+		return false
+	}
+
+	s.eviction.remove(key)
+
+	return true
+}
+
+// Delete key already forgotten by the eviction policy
+// Caller must hold the write lock
+func (s *shard) drop(key string, event string) bool {
+	e, ok := s.data[key]
+	if !ok {
+		return false
+	}
+
+	delete(s.data, key)
+	s.hashes.delete(float64(fnv32a(key)), key)
+	s.eviction.resize(-1, -e.size)
+	s.types[typeName(e.value)]--
+	s.notify(event, key, e.value)
+
+	return true
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestShards(t *testing.T) {
+	t.Log("Testing keys spread over shards...")
+
+	cache := AllocWithConfig(Config{Shards: 8})
+
+	for i := 0; i < 1000; i++ {
+		cache.SetInt(strconv.Itoa(i), i)
+	}
+
+	for i, s := range cache.shards {
+		if len(s.data) == 0 {
+			t.Errorf("Expected keys in shard %d, but it was empty.", i)
+		}
+	}
+
+	if cache.Len() != 1000 {
+		t.Errorf("Expected 1000, but it was %d instead.", cache.Len())
+	}
+
+	if len(cache.Keys()) != 1000 {
+		t.Errorf("Expected 1000, but it was %d instead.", len(cache.Keys()))
+	}
+}
+
+func TestShardsConcurrentWrites(t *testing.T) {
+	t.Log("Testing concurrent writes over shards...")
+
+	cache := Alloc()
+	cache.SetInt("counter", 0)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				cache.SetInt(fmt.Sprintf("%d-%d", w, i), i)
+				cache.Increment("counter")
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	v, err := cache.Get("counter")
+	if err != nil {
+		t.Error(err)
+	}
+	if v != 800 {
+		t.Errorf("Expected 800, but it was %v instead.", v)
+	}
+
+	if cache.Len() != 801 {
+		t.Errorf("Expected 801, but it was %d instead.", cache.Len())
+	}
+}
+
+func TestShardsLimits(t *testing.T) {
+	t.Log("Testing limits hold for the whole cache...")
+
+	cache := AllocWithConfig(Config{MaxEntries: 100})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				cache.SetInt(fmt.Sprintf("%d-%d", g, i), i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.Len() != 100 {
+		t.Errorf("Expected 100, but it was %d instead.", cache.Len())
+	}
+
+	cache = AllocWithConfig(Config{MaxBytes: 1000})
+	for i := 0; i < 100; i++ {
+		cache.SetInt(strconv.Itoa(i), i)
+	}
+
+	if stats := cache.Stats(); stats.UsedBytes > 1000 || stats.Keys != int(1000/sizeOf("00", 0)) {
+		t.Errorf("Expected %d keys in at most 1000 bytes, but it was %d in %d instead.", 1000/sizeOf("00", 0), stats.Keys, stats.UsedBytes)
+	}
+}
+
+// go test -bench Parallel -cpu 1,2,4,8 ./memory/
+func BenchmarkSetParallel(b *testing.B) {
+	for _, shards := range []int{1, 16, 64} {
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			cache := AllocWithConfig(Config{Shards: shards})
+			keys := benchmarkKeys()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					cache.SetInt(keys[i%len(keys)], i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMixedParallel(b *testing.B) {
+	for _, shards := range []int{1, 16, 64} {
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			cache := AllocWithConfig(Config{Shards: shards})
+			keys := benchmarkKeys()
+			for i, key := range keys {
+				cache.SetInt(key, i)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := keys[i%len(keys)]
+					if i%4 == 0 {
+						cache.Increment(key)
+					} else {
+						cache.Get(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func benchmarkKeys() []string {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+
+	return keys
+}
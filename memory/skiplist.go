@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"math/rand"
+)
+
+const (
+	skiplistMaxLevel = 32
+	// Probability of a node to get one more level
+	skiplistP = 0.25
+)
+
+// Skiplist ordered by score, then by member
+// Every level keeps the span of its forward link, so ranks are O(log n)
+// @see https://github.com/redis/redis/blob/unstable/src/t_zset.c
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	levels   []skiplistLevel
+}
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	// Number of nodes the forward link jumps over
+	span int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{levels: make([]skiplistLevel, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+
+	return level
+}
+
+// Node goes before the given score and member
+func (node *skiplistNode) less(score float64, member string) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+// Insert member, caller makes sure it isn't in the list yet
+func (list *skiplist) insert(score float64, member string) *skiplistNode {
+	update := make([]*skiplistNode, skiplistMaxLevel)
+	rank := make([]int, skiplistMaxLevel)
+
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		if i < list.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.less(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > list.level {
+		for i := list.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = list.header
+			update[i].levels[i].span = list.length
+		}
+		list.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, levels: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+
+	for i := level; i < list.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != list.header {
+		x.backward = update[0]
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		list.tail = x
+	}
+	list.length++
+
+	return x
+}
+
+// Delete member with the given score, returns false if not found
+func (list *skiplist) delete(score float64, member string) bool {
+	update := make([]*skiplistNode, skiplistMaxLevel)
+
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.less(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	list.unlink(x, update)
+
+	return true
+}
+
+func (list *skiplist) unlink(x *skiplistNode, update []*skiplistNode) {
+	for i := 0; i < list.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		list.tail = x.backward
+	}
+
+	for list.level > 1 && list.header.levels[list.level-1].forward == nil {
+		list.level--
+	}
+	list.length--
+}
+
+// 0-based rank of member with the given score, -1 if not found
+func (list *skiplist) rank(score float64, member string) int {
+	rank := 0
+
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for next := x.levels[i].forward; next != nil && !(score < next.score || (score == next.score && member < next.member)); next = x.levels[i].forward {
+			rank += x.levels[i].span
+			x = next
+		}
+		if x != list.header && x.score == score && x.member == member {
+			return rank - 1
+		}
+	}
+
+	return -1
+}
+
+// Node by 0-based rank, nil if out of range
+func (list *skiplist) byRank(rank int) *skiplistNode {
+	if rank < 0 || rank >= list.length {
+		return nil
+	}
+
+	traversed := 0
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank+1 {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// First node with score >= min, nil if none
+func (list *skiplist) firstFrom(min float64) *skiplistNode {
+	x := list.header
+	for i := list.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.score < min {
+			x = x.levels[i].forward
+		}
+	}
+
+	return x.levels[0].forward
+}
@@ -0,0 +1,237 @@
+package memory
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+const (
+	snapshotFormat = "cachego-snapshot"
+	// Bump on incompatible changes of the snapshot layout
+	snapshotVersion = 1
+)
+
+// Snapshot is a header line followed by one JSON line per entry
+type snapshotHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// Unix timestamp in milliseconds
+	Created int64 `json:"created"`
+	Keys    int   `json:"keys"`
+}
+
+type snapshotEntry struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+	// Remaining TTL in milliseconds, 0 - no TTL
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+// Write point-in-time snapshot of all live entries
+// Entries are encoded under read locks of all shards and written after they are released
+func (cache *CACHE) Save(w io.Writer) error {
+	entries, err := cache.snapshot()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+
+	header := snapshotHeader{
+		Format:  snapshotFormat,
+		Version: snapshotVersion,
+		Created: now() / int64(time.Millisecond),
+		Keys:    len(entries),
+	}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func (cache *CACHE) snapshot() ([]snapshotEntry, error) {
+	cache.rlockAll()
+	defer cache.runlockAll()
+
+	t := now()
+	ms := int64(time.Millisecond)
+
+	entries := []snapshotEntry{}
+	for _, s := range cache.shards {
+		for key, e := range s.data {
+			if e.expireAt != 0 && e.expired(t) {
+				continue
+			}
+
+			value := e.value
+			if z, ok := value.(*sortedSet); ok {
+				value = z.members()
+			}
+
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+
+			var ttl int64
+			if e.expireAt != 0 {
+				ttl = (e.expireAt - t + ms - 1) / ms
+			}
+
+			entries = append(entries, snapshotEntry{Key: key, Type: typeName(e.value), Value: raw, TTL: ttl})
+		}
+	}
+
+	return entries, nil
+}
+
+// Read snapshot and store its entries, existing keys are overwritten
+// Nothing is stored if the snapshot is invalid
+func (cache *CACHE) Load(r io.Reader) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	var header snapshotHeader
+	if err := decoder.Decode(&header); err != nil || header.Format != snapshotFormat {
+		return util.ErrorInvalidSnapshot
+	}
+	if header.Version != snapshotVersion {
+		return util.ErrorSnapshotVersion
+	}
+
+	entries := make([]snapshotEntry, 0, header.Keys)
+	values := make([]interface{}, 0, header.Keys)
+	for {
+		var e snapshotEntry
+		err := decoder.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return util.ErrorInvalidSnapshot
+		}
+
+		value, err := decodeValue(e.Type, e.Value)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, e)
+		values = append(values, value)
+	}
+
+	if len(entries) != header.Keys {
+		return util.ErrorInvalidSnapshot
+	}
+
+	for i, e := range entries {
+		var expireAt int64
+		if e.TTL > 0 {
+			expireAt = now() + int64(time.Millisecond)*e.TTL
+		}
+
+		shard := cache.shard(e.Key)
+		shard.Lock()
+		shard.set(e.Key, values[i], expireAt)
+		shard.Unlock()
+		cache.evictOverLimit(e.Key)
+	}
+
+	return nil
+}
+
+// Decode value stored with type name
+func decodeValue(name string, raw json.RawMessage) (interface{}, error) {
+	var err error
+	var value interface{}
+
+	switch name {
+	case "int":
+		var v int
+		err = json.Unmarshal(raw, &v)
+		value = v
+	case "string":
+		var v string
+		err = json.Unmarshal(raw, &v)
+		value = v
+	case "list":
+		var v util.List
+		err = json.Unmarshal(raw, &v)
+		value = v
+	case "dict":
+		var v util.Dict
+		err = json.Unmarshal(raw, &v)
+		value = v
+	case "set":
+		var v util.Set
+		err = json.Unmarshal(raw, &v)
+		value = v
+	case "zset":
+		var v util.ZSet
+		err = json.Unmarshal(raw, &v)
+		z := newSortedSet()
+		for _, m := range v {
+			z.add(m.Member, m.Score)
+		}
+		value = z
+	default:
+		return nil, util.ErrorInvalidSnapshot
+	}
+
+	if err != nil {
+		return nil, util.ErrorInvalidSnapshot
+	}
+
+	return value, nil
+}
+
+// Save snapshot to file atomically
+// Snapshot is written to a temporary file in the same directory and renamed over path
+func (cache *CACHE) SaveFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = cache.Save(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// Load snapshot from file
+func (cache *CACHE) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return cache.Load(f)
+}
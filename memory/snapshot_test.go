@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestSaveLoad(t *testing.T) {
+	t.Log("Testing snapshot save and load...")
+
+	cache := Alloc()
+	cache.SetString("string", "hi alex")
+	cache.SetInt("int", 123, 60000)
+	cache.SetList("list", util.List{"one", "two"})
+	cache.SetDict("dict", util.Dict{"k1": "v1"})
+	cache.SAdd("set", "m1", "m2")
+	cache.ZAdd("zset", util.ZMember{Member: "m1", Score: 1.5}, util.ZMember{Member: "m2", Score: -1})
+	cache.SetInt("expired", 1, 1)
+	time.Sleep(time.Millisecond * 5)
+
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := Alloc()
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != 6 {
+		t.Errorf("Expected 6, but it was %d instead.", loaded.Len())
+	}
+
+	for _, key := range []string{"string", "int", "list", "dict", "set", "zset"} {
+		expected, _ := cache.Get(key)
+		actual, err := loaded.Get(key)
+		if err != nil || !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %v for '%s', but it was %v (%v) instead.", expected, key, actual, err)
+		}
+	}
+
+	ttl, _ := loaded.GetTTL("int")
+	if ttl <= 59000 || ttl > 60000 {
+		t.Errorf("Expected remaining ttl about 60000, but it was %d instead.", ttl)
+	}
+
+	ttl, _ = loaded.GetTTL("string")
+	if ttl != -1 {
+		t.Errorf("Expected -1, but it was %d instead.", ttl)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Log("Testing invalid snapshots...")
+
+	cache := Alloc()
+
+	cases := map[string]error{
+		"garbage": util.ErrorInvalidSnapshot,
+		`{"format":"cachego-snapshot","version":99}`: util.ErrorSnapshotVersion,
+		`{"format":"cachego-snapshot","version":1,"keys":2}
+{"key":"k","type":"int","value":1}`: util.ErrorInvalidSnapshot,
+		`{"format":"cachego-snapshot","version":1,"keys":1}
+{"key":"k","type":"int","value":"one"}`: util.ErrorInvalidSnapshot,
+	}
+
+	for snapshot, expected := range cases {
+		if err := cache.Load(strings.NewReader(snapshot)); err != expected {
+			t.Errorf("Expected %v, but it was %v instead.", expected, err)
+		}
+	}
+
+	if cache.Len() != 0 {
+		t.Errorf("Expected 0, but it was %d instead.", cache.Len())
+	}
+}
+
+func TestSaveFile(t *testing.T) {
+	t.Log("Testing snapshot files...")
+
+	dir, err := ioutil.TempDir("", "cachego")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cachego.snapshot")
+
+	cache := Alloc()
+	cache.SetString("k1", "v1")
+	if err := cache.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.SetString("k2", "v2")
+	if err := cache.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Expected only the snapshot file, but it was %d files instead.", len(files))
+	}
+
+	loaded := Alloc()
+	if err := loaded.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != 2 {
+		t.Errorf("Expected 2, but it was %d instead.", loaded.Len())
+	}
+
+	if err := loaded.LoadFile(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Expected not exist error, but it was %v instead.", err)
+	}
+}
@@ -0,0 +1,326 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Tx Operations queued by Multi and run atomically by Exec
+// Operations run under the write locks of all their shards, so no one sees
+// the state in between. If an operation fails, changes of the previous ones
+// are undone and nothing is written to the append-only log, replicas or watchers.
+type Tx struct {
+	cache *CACHE
+	ops   []txOp
+}
+
+type txOp struct {
+	key string
+	run func(s *shard) (interface{}, error)
+}
+
+// Writes of a running transaction in the order of operations
+type txLog struct {
+	lines  []txLine
+	events []util.Event
+}
+
+type txLine struct {
+	shard *shard
+	line  []byte
+	err   error
+}
+
+// Start transaction
+func (cache *CACHE) Multi() *Tx {
+	return &Tx{cache: cache}
+}
+
+func (tx *Tx) queue(key string, run func(s *shard) (interface{}, error)) *Tx {
+	tx.ops = append(tx.ops, txOp{key, run})
+	return tx
+}
+
+// Number of queued operations
+func (tx *Tx) Len() int {
+	return len(tx.ops)
+}
+
+// Run queued operations, returns their results in order
+// On error none of the operations has any effect, the error tells which one failed
+func (tx *Tx) Exec() ([]interface{}, error) {
+	keys := make([]string, len(tx.ops))
+	for i, op := range tx.ops {
+		keys[i] = op.key
+	}
+
+	shards := tx.cache.shardsOf(keys)
+	defer tx.cache.evictOverLimit("")
+	lockShards(shards)
+	defer unlockShards(shards)
+
+	log := &txLog{}
+	for _, s := range shards {
+		s.tx = log
+	}
+
+	// Entries before the transaction, nil if key was missing
+	undo := map[string]*entry{}
+	results := make([]interface{}, len(tx.ops))
+	var err error
+	for i, op := range tx.ops {
+		s := tx.cache.shard(op.key)
+		if _, saved := undo[op.key]; !saved {
+			undo[op.key] = s.backup(op.key)
+		}
+
+		if results[i], err = op.run(s); err != nil {
+			err = txError(i, err)
+			break
+		}
+	}
+
+	if err != nil {
+		for key, e := range undo {
+			tx.cache.shard(key).restore(key, e)
+		}
+	}
+
+	for _, s := range shards {
+		s.tx = nil
+	}
+
+	if err == nil {
+		for _, l := range log.lines {
+			l.shard.emit(l.line, l.err)
+		}
+		for _, e := range log.events {
+			tx.cache.watchers.publish(e)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Error of operation with its index in the transaction
+func txError(index int, err error) error {
+	if e, ok := err.(util.CacheError); ok {
+		return util.CacheError{What: fmt.Sprintf("Transaction op %d: %s", index, e.What), Code: e.Code}
+	}
+
+	return err
+}
+
+// Copy of live entry, nil if key is missing
+// Caller must hold the write lock
+func (s *shard) backup(key string) *entry {
+	e, ok := s.lookup(key)
+	if !ok {
+		return nil
+	}
+
+	return &entry{value: copyValue(e.value), expireAt: e.expireAt, version: e.version}
+}
+
+// Bring back entry saved by backup
+// Caller must hold the write lock
+func (s *shard) restore(key string, e *entry) {
+	if e == nil {
+		s.delete(key, util.EventRemove)
+		return
+	}
+
+	s.set(key, e.value, e.expireAt)
+	s.data[key].version = e.version
+}
+
+// Deep copy of value, collections are changed in place
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case util.List:
+		return append(util.List(nil), v...)
+	case util.Dict:
+		d := make(util.Dict, len(v))
+		for k, element := range v {
+			d[k] = element
+		}
+		return d
+	case util.Set:
+		return copySet(v)
+	case *sortedSet:
+		z := newSortedSet()
+		for member, score := range v.scores {
+			z.add(member, score)
+		}
+		return z
+	default:
+		return value
+	}
+}
+
+// Store value with optional TTL in milliseconds
+// Caller must hold the write lock
+func (s *shard) setValue(key string, value interface{}, ttl []int) error {
+	expireAt, err := deadlineAfter(ttl)
+	if err != nil {
+		return err
+	}
+
+	s.set(key, value, expireAt)
+	s.record("set", key, value, expireAt)
+
+	return nil
+}
+
+///////////////////////////////////////
+// Queued operations, same as the CACHE methods
+///////////////////////////////////////
+
+func (tx *Tx) Get(key string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.get(key)
+	})
+}
+
+func (tx *Tx) GetListElement(key string, index int) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.getListElement(key, index)
+	})
+}
+
+func (tx *Tx) GetDictElement(key, elementKey string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.getDictElement(key, elementKey)
+	})
+}
+
+func (tx *Tx) SetString(key, value string, ttl ...int) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.setValue(key, value, ttl)
+	})
+}
+
+func (tx *Tx) SetInt(key string, value int, ttl ...int) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.setValue(key, value, ttl)
+	})
+}
+
+func (tx *Tx) SetList(key string, value util.List, ttl ...int) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.setValue(key, value, ttl)
+	})
+}
+
+func (tx *Tx) SetDict(key string, value util.Dict, ttl ...int) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.setValue(key, value, ttl)
+	})
+}
+
+func (tx *Tx) UpdateString(key, value string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.updateString(key, value)
+	})
+}
+
+func (tx *Tx) UpdateInt(key string, value int) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.updateInt(key, value)
+	})
+}
+
+func (tx *Tx) UpdateList(key string, value util.List) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.updateList(key, value)
+	})
+}
+
+func (tx *Tx) UpdateDict(key string, value util.Dict) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.updateDict(key, value)
+	})
+}
+
+func (tx *Tx) Remove(key string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.remove(key)
+	})
+}
+
+func (tx *Tx) Increment(key string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.increment(key)
+	})
+}
+
+func (tx *Tx) AppendToList(key, value string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.appendToList(key, value)
+	})
+}
+
+func (tx *Tx) RemoveFromList(key, value string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.removeFromList(key, value)
+	})
+}
+
+func (tx *Tx) SetDictElement(key, elementKey, value string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.setDictElement(key, elementKey, value)
+	})
+}
+
+func (tx *Tx) RemoveFromDict(key, elementKey string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.removeFromDict(key, elementKey)
+	})
+}
+
+func (tx *Tx) SAdd(key string, members ...string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.sAdd(key, members...)
+	})
+}
+
+func (tx *Tx) SRem(key string, members ...string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.sRem(key, members...)
+	})
+}
+
+func (tx *Tx) ZAdd(key string, members ...util.ZMember) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.zAdd(key, members...)
+	})
+}
+
+func (tx *Tx) ZIncrBy(key string, increment float64, member string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.zIncrBy(key, increment, member)
+	})
+}
+
+func (tx *Tx) ZRem(key string, members ...string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.zRem(key, members...)
+	})
+}
+
+func (tx *Tx) SetTTL(key string, ttl int) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.setTTL(key, ttl)
+	})
+}
+
+func (tx *Tx) Persist(key string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.persist(key)
+	})
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestTxExec(t *testing.T) {
+	t.Log("Testing transaction results...")
+
+	cache := Alloc()
+	cache.SetList("l1", util.List{"a", "b"})
+	cache.SetList("l2", util.List{})
+	cache.SetInt("counter", 1)
+
+	results, err := cache.Multi().
+		RemoveFromList("l1", "a").
+		AppendToList("l2", "a").
+		Increment("counter").
+		SetDictElement("d", "k", "v").
+		Get("l1").
+		Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{0, nil, 2, true, util.List{"b"}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, but it was %v instead.", expected, results)
+	}
+
+	if v, _ := cache.Get("l2"); !reflect.DeepEqual(v, util.List{"a"}) {
+		t.Errorf("Expected [a], but it was %v instead.", v)
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	t.Log("Testing transaction rollback on error...")
+
+	cache := Alloc()
+	cache.SetInt("counter", 1)
+	cache.SetList("list", util.List{"a"})
+	cache.SetString("string", "v", 60000)
+	cache.ZAdd("zset", util.ZMember{Member: "m", Score: 1})
+
+	_, err := cache.Multi().
+		Increment("counter").
+		AppendToList("list", "b").
+		SetDictElement("dict", "k", "v").
+		Remove("string").
+		ZIncrBy("zset", 5, "m").
+		AppendToList("counter", "x").
+		Exec()
+
+	e, ok := err.(util.CacheError)
+	if !ok || e.Code != util.ErrorWrongType.Code {
+		t.Fatalf("Expected wrong type error, but it was %v instead.", err)
+	}
+
+	if v, _ := cache.Get("counter"); v != 1 {
+		t.Errorf("Expected 1, but it was %v instead.", v)
+	}
+	if v, _ := cache.Get("list"); !reflect.DeepEqual(v, util.List{"a"}) {
+		t.Errorf("Expected [a], but it was %v instead.", v)
+	}
+	if ok, _ := cache.HasKey("dict"); ok {
+		t.Errorf("Expected 'dict' to be missing.")
+	}
+	if ttl, err := cache.GetTTL("string"); err != nil || ttl <= 0 {
+		t.Errorf("Expected 'string' with ttl, but it was %d (%v) instead.", ttl, err)
+	}
+	if v, _ := cache.ZScore("zset", "m"); v != 1 {
+		t.Errorf("Expected 1, but it was %v instead.", v)
+	}
+}
+
+func TestTxAppendLog(t *testing.T) {
+	t.Log("Testing only committed transactions are written to append-only log...")
+
+	path, cleanup := tempAppendLog(t)
+	defer cleanup()
+
+	cache := Alloc()
+	cache.OpenAppendLog(path, FsyncNever)
+	cache.SetInt("counter", 1)
+
+	cache.Multi().Increment("counter").SetString("failed", "v").UpdateList("counter", util.List{}).Exec()
+	if _, err := cache.Multi().Increment("counter").SetString("committed", "v").Exec(); err != nil {
+		t.Fatal(err)
+	}
+	cache.CloseAppendLog()
+
+	replayed := Alloc()
+	replayed.OpenAppendLog(path, FsyncNever)
+	defer replayed.CloseAppendLog()
+
+	checkSameContent(t, &cache, &replayed)
+
+	if v, _ := replayed.Get("counter"); v != 2 {
+		t.Errorf("Expected 2, but it was %v instead.", v)
+	}
+}
+
+func TestTxIsolation(t *testing.T) {
+	t.Log("Testing concurrent transactions see no partial state...")
+
+	cache := AllocWithConfig(Config{Shards: 8})
+	cache.SetInt("left", 0)
+	cache.SetList("right", util.List{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				cache.Multi().Increment("left").AppendToList("right", "v").Exec()
+			}
+		}()
+	}
+
+	for i := 0; i < 500; i++ {
+		results, err := cache.Multi().Get("left").Get("right").Exec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if left, right := results[0].(int), len(results[1].(util.List)); left != right {
+			t.Fatalf("Expected the same count in both keys, but it was %d and %d instead.", left, right)
+		}
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"github.com/anevsky/cachego/util"
+)
+
+// Get value with its version, the version grows on every write of the value
+func (cache *CACHE) GetWithVersion(key string) (interface{}, uint64, error) {
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	value, err := shard.get(key)
+	if err != nil {
+		return value, 0, err
+	}
+
+	return value, shard.data[key].version, nil
+}
+
+// Get version of value by key
+func (cache *CACHE) Version(key string) (uint64, error) {
+	shard := cache.shard(key)
+	shard.RLock()
+	defer shard.RUnlock()
+
+	return shard.getVersion(key)
+}
+
+// Caller must hold the read lock
+func (s *shard) getVersion(key string) (uint64, error) {
+	e, ok := s.lookup(key)
+	if !ok {
+		return 0, util.ErrorKeyNotFound
+	}
+
+	return e.version, nil
+}
+
+// Replace string, int, list or dict value only if its version is still the given one
+// Returns the new version or ErrorVersionMismatch if the value was written in between
+func (cache *CACHE) CompareAndSwap(key string, version uint64, value interface{}) (uint64, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	if err := shard.checkVersion(key, version); err != nil {
+		return 0, err
+	}
+
+	if _, err := shard.update(key, value); err != nil {
+		return 0, err
+	}
+
+	return shard.data[key].version, nil
+}
+
+// Caller must hold the read lock
+func (s *shard) checkVersion(key string, version uint64) error {
+	current, err := s.getVersion(key)
+	if err != nil {
+		return err
+	}
+
+	if current != version {
+		return util.ErrorVersionMismatch
+	}
+
+	return nil
+}
+
+// Update value of the same type
+// Caller must hold the write lock
+func (s *shard) update(key string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return s.updateString(key, v)
+	case int:
+		return s.updateInt(key, v)
+	case util.List:
+		return s.updateList(key, v)
+	case util.Dict:
+		return s.updateDict(key, v)
+	default:
+		return nil, util.ErrorWrongType
+	}
+}
+
+// Fail transaction if version of value by key is not the given one
+func (tx *Tx) IfVersion(key string, version uint64) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return nil, s.checkVersion(key, version)
+	})
+}
+
+// Get version of value by key as uint64
+func (tx *Tx) Version(key string) *Tx {
+	return tx.queue(key, func(s *shard) (interface{}, error) {
+		return s.getVersion(key)
+	})
+}
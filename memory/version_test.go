@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestVersion(t *testing.T) {
+	t.Log("Testing versions grow on every write...")
+
+	cache := Alloc()
+	cache.SetString("key", "v1")
+
+	_, v1, err := cache.GetWithVersion("key")
+	if err != nil || v1 == 0 {
+		t.Fatalf("Expected version, but it was %d (%v) instead.", v1, err)
+	}
+
+	cache.UpdateString("key", "v2")
+	v2, _ := cache.Version("key")
+	if v2 <= v1 {
+		t.Errorf("Expected version above %d, but it was %d instead.", v1, v2)
+	}
+
+	cache.SetTTL("key", 60000)
+	if v, _ := cache.Version("key"); v != v2 {
+		t.Errorf("Expected TTL change to keep version %d, but it was %d instead.", v2, v)
+	}
+
+	cache.Remove("key")
+	if _, err := cache.Version("key"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorKeyNotFound, err)
+	}
+
+	cache.SetString("key", "v1")
+	if v3, _ := cache.Version("key"); v3 <= v2 {
+		t.Errorf("Expected recreated key version above %d, but it was %d instead.", v2, v3)
+	}
+
+	// Rolled back transactions keep the version
+	v4, _ := cache.Version("key")
+	cache.Multi().UpdateString("key", "v3").Increment("key").Exec()
+	if v, _ := cache.Version("key"); v != v4 {
+		t.Errorf("Expected version %d after rollback, but it was %d instead.", v4, v)
+	}
+}
+
+func TestVersionRestart(t *testing.T) {
+	t.Log("Testing versions are not reused by a reloaded cache...")
+
+	cache := Alloc()
+	for i := 0; i < 100; i++ {
+		cache.SetInt("key", i)
+	}
+	v1, _ := cache.Version("key")
+
+	reloaded := Alloc()
+	reloaded.SetInt("key", 0)
+	if v2, _ := reloaded.Version("key"); v2 <= v1 {
+		t.Errorf("Expected version above %d, but it was %d instead.", v1, v2)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	t.Log("Testing compare-and-swap...")
+
+	cache := Alloc()
+	cache.SetDict("dict", util.Dict{"k1": "v1"})
+	version, _ := cache.Version("dict")
+
+	newVersion, err := cache.CompareAndSwap("dict", version, util.Dict{"k1": "v2"})
+	if err != nil || newVersion <= version {
+		t.Fatalf("Expected version above %d, but it was %d (%v) instead.", version, newVersion, err)
+	}
+
+	if _, err := cache.CompareAndSwap("dict", version, util.Dict{"k1": "v3"}); err != util.ErrorVersionMismatch {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorVersionMismatch, err)
+	}
+	if v, _ := cache.GetDictElement("dict", "k1"); v != "v2" {
+		t.Errorf("Expected v2, but it was %s instead.", v)
+	}
+
+	if _, err := cache.CompareAndSwap("dict", newVersion, "string"); err != util.ErrorWrongType {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorWrongType, err)
+	}
+	if _, err := cache.CompareAndSwap("missing", 1, "string"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorKeyNotFound, err)
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	t.Log("Testing concurrent compare-and-swap loses no updates...")
+
+	cache := Alloc()
+	cache.SetInt("counter", 0)
+
+	const workers, increments = 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; {
+				value, version, _ := cache.GetWithVersion("counter")
+				if _, err := cache.CompareAndSwap("counter", version, value.(int)+1); err == nil {
+					i++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := cache.Get("counter"); v != workers*increments {
+		t.Errorf("Expected %d, but it was %v instead.", workers*increments, v)
+	}
+}
@@ -0,0 +1,366 @@
+package memory
+
+import (
+	"math"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Sorted set value, the skiplist keeps the order and the map keeps scores
+type sortedSet struct {
+	list   *skiplist
+	scores map[string]float64
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{list: newSkiplist(), scores: map[string]float64{}}
+}
+
+// Set score of member, returns true if member was added
+func (z *sortedSet) add(member string, score float64) bool {
+	old, exists := z.scores[member]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.list.delete(old, member)
+	}
+
+	z.list.insert(score, member)
+	z.scores[member] = score
+
+	return !exists
+}
+
+func (z *sortedSet) remove(member string) bool {
+	score, exists := z.scores[member]
+	if !exists {
+		return false
+	}
+
+	z.list.delete(score, member)
+	delete(z.scores, member)
+
+	return true
+}
+
+// Members with ranks from start to stop inclusive
+func (z *sortedSet) rangeByRank(start, stop int) util.ZSet {
+	length := z.list.length
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+
+	result := util.ZSet{}
+	if start > stop {
+		return result
+	}
+
+	for x := z.list.byRank(start); x != nil && start <= stop; x = x.levels[0].forward {
+		result = append(result, util.ZMember{Member: x.member, Score: x.score})
+		start++
+	}
+
+	return result
+}
+
+// Members with scores from min to max inclusive
+func (z *sortedSet) rangeByScore(min, max float64) util.ZSet {
+	result := util.ZSet{}
+	for x := z.list.firstFrom(min); x != nil && x.score <= max; x = x.levels[0].forward {
+		result = append(result, util.ZMember{Member: x.member, Score: x.score})
+	}
+
+	return result
+}
+
+func (z *sortedSet) members() util.ZSet {
+	return z.rangeByRank(0, -1)
+}
+
+// Approximate size in bytes of a sorted set member
+func zmemberSize(member string) int64 {
+	return memberSize(member) + 8
+}
+
+// Add members or update their scores, the sorted set is created if key is missing
+// Returns number of added members
+func (cache *CACHE) ZAdd(key string, members ...util.ZMember) (int, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.zAdd(key, members...)
+}
+
+// Caller must hold the write lock
+func (s *shard) zAdd(key string, members ...util.ZMember) (int, error) {
+	// NaN is neither below nor above any score, it would break the order
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, util.ErrorInvalidScore
+		}
+	}
+
+	e, success := s.lookup(key)
+	if !success {
+		if len(members) == 0 {
+			return 0, nil
+		}
+
+		z := newSortedSet()
+		for _, m := range members {
+			z.add(m.Member, m.Score)
+		}
+		s.set(key, z, 0)
+		s.record("zadd", key, members, 0)
+		return len(z.scores), nil
+	}
+
+	z, success := e.value.(*sortedSet)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	added, changed := 0, false
+	size := e.size
+	for _, m := range members {
+		if old, exists := z.scores[m.Member]; exists && old == m.Score {
+			continue
+		}
+		changed = true
+		if z.add(m.Member, m.Score) {
+			size += zmemberSize(m.Member)
+			added++
+		}
+	}
+	if !changed {
+		return 0, nil
+	}
+
+	s.storeSized(key, z, size)
+	s.record("zadd", key, members, 0)
+
+	return added, nil
+}
+
+// Increment score of member, the member and the sorted set are created if missing
+// Returns the new score
+func (cache *CACHE) ZIncrBy(key string, increment float64, member string) (float64, error) {
+	shard := cache.shard(key)
+	defer cache.evictOverLimit(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.zIncrBy(key, increment, member)
+}
+
+// Caller must hold the write lock
+func (s *shard) zIncrBy(key string, increment float64, member string) (float64, error) {
+	if math.IsNaN(increment) {
+		return 0, util.ErrorInvalidScore
+	}
+
+	e, success := s.lookup(key)
+	if !success {
+		z := newSortedSet()
+		z.add(member, increment)
+		s.set(key, z, 0)
+		s.record("zadd", key, util.ZSet{{Member: member, Score: increment}}, 0)
+		return increment, nil
+	}
+
+	z, success := e.value.(*sortedSet)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	score, exists := z.scores[member]
+	// Infinities of opposite signs add up to NaN
+	if math.IsNaN(score + increment) {
+		return 0, util.ErrorInvalidScore
+	}
+
+	size := e.size
+	if !exists {
+		size += zmemberSize(member)
+	}
+	score += increment
+	z.add(member, score)
+
+	s.storeSized(key, z, size)
+	s.record("zadd", key, util.ZSet{{Member: member, Score: score}}, 0)
+
+	return score, nil
+}
+
+// Remove members from sorted set, the key is removed with its last member
+// Returns number of removed members
+func (cache *CACHE) ZRem(key string, members ...string) (int, error) {
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	return shard.zRem(key, members...)
+}
+
+// Caller must hold the write lock
+func (s *shard) zRem(key string, members ...string) (int, error) {
+	e, success := s.lookup(key)
+	if !success {
+		return 0, util.ErrorKeyNotFound
+	}
+
+	z, success := e.value.(*sortedSet)
+	if !success {
+		return 0, s.counters.wrongTypeError()
+	}
+
+	removed := 0
+	size := e.size
+	for _, member := range members {
+		if z.remove(member) {
+			size -= zmemberSize(member)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	s.storeZSet(key, z, size)
+	s.record("zrem", key, members, 0)
+
+	return removed, nil
+}
+
+// Remove members with scores from min to max inclusive
+// Returns number of removed members
+func (cache *CACHE) ZRemRangeByScore(key string, min, max float64) (int, error) {
+	shard := cache.shard(key)
+	shard.Lock()
+	defer shard.Unlock()
+
+	e, success := shard.lookup(key)
+	if !success {
+		return 0, util.ErrorKeyNotFound
+	}
+
+	z, success := e.value.(*sortedSet)
+	if !success {
+		return 0, cache.counters.wrongTypeError()
+	}
+
+	matched := z.rangeByScore(min, max)
+	if len(matched) == 0 {
+		return 0, nil
+	}
+
+	size := e.size
+	members := make([]string, len(matched))
+	for i, m := range matched {
+		z.remove(m.Member)
+		size -= zmemberSize(m.Member)
+		members[i] = m.Member
+	}
+
+	shard.storeZSet(key, z, size)
+	shard.record("zrem", key, members, 0)
+
+	return len(matched), nil
+}
+
+func (cache *CACHE) ZScore(key, member string) (float64, error) {
+	z, unlock, err := cache.readZSet(key)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	score, success := z.scores[member]
+	if !success {
+		return 0, util.ErrorMemberNotFound
+	}
+
+	return score, nil
+}
+
+// 0-based rank of member, lowest score first
+func (cache *CACHE) ZRank(key, member string) (int, error) {
+	z, unlock, err := cache.readZSet(key)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	score, success := z.scores[member]
+	if !success {
+		return 0, util.ErrorMemberNotFound
+	}
+
+	return z.list.rank(score, member), nil
+}
+
+// Members with ranks from start to stop inclusive, negative ranks count from the end
+func (cache *CACHE) ZRange(key string, start, stop int) (util.ZSet, error) {
+	z, unlock, err := cache.readZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return z.rangeByRank(start, stop), nil
+}
+
+// Members with scores from min to max inclusive
+func (cache *CACHE) ZRangeByScore(key string, min, max float64) (util.ZSet, error) {
+	z, unlock, err := cache.readZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return z.rangeByScore(min, max), nil
+}
+
+// Sorted set stored by key under read lock of its shard
+// Caller must call unlock when err is nil
+func (cache *CACHE) readZSet(key string) (*sortedSet, func(), error) {
+	shard := cache.shard(key)
+	shard.RLock()
+
+	e, success := shard.lookup(key)
+	if err := cache.counters.read(success); err != nil {
+		shard.RUnlock()
+		return nil, nil, err
+	}
+
+	shard.eviction.access(key)
+
+	z, success := e.value.(*sortedSet)
+	if !success {
+		shard.RUnlock()
+		return nil, nil, cache.counters.wrongTypeError()
+	}
+
+	return z, shard.RUnlock, nil
+}
+
+// Store sorted set, the key is removed with its last member
+func (s *shard) storeZSet(key string, z *sortedSet, size int64) {
+	if len(z.scores) == 0 {
+		s.delete(key, util.EventRemove)
+	} else {
+		s.storeSized(key, z, size)
+	}
+}
@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestSkiplist(t *testing.T) {
+	t.Log("Testing skiplist order and ranks...")
+
+	list := newSkiplist()
+	scores := map[string]float64{}
+	for i := 0; i < 1000; i++ {
+		member := strconv.Itoa(i)
+		scores[member] = float64(rand.Intn(100))
+		list.insert(scores[member], member)
+	}
+	for i := 0; i < 1000; i += 3 {
+		member := strconv.Itoa(i)
+		if !list.delete(scores[member], member) {
+			t.Errorf("Expected member '%s' to be deleted.", member)
+		}
+		delete(scores, member)
+	}
+
+	expected := util.ZSet{}
+	for member, score := range scores {
+		expected = append(expected, util.ZMember{Member: member, Score: score})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score < expected[j].Score
+		}
+		return expected[i].Member < expected[j].Member
+	})
+
+	if list.length != len(expected) {
+		t.Errorf("Expected %d, but it was %d instead.", len(expected), list.length)
+	}
+
+	for i, m := range expected {
+		if rank := list.rank(m.Score, m.Member); rank != i {
+			t.Errorf("Expected rank %d of '%s', but it was %d instead.", i, m.Member, rank)
+		}
+		if x := list.byRank(i); x == nil || x.member != m.Member {
+			t.Errorf("Expected '%s' at rank %d, but it was %v instead.", m.Member, i, x)
+		}
+	}
+
+	if list.rank(1000, "missing") != -1 {
+		t.Errorf("Expected -1, but it was %d instead.", list.rank(1000, "missing"))
+	}
+}
+
+func TestZAdd(t *testing.T) {
+	t.Log("Testing ZAdd...")
+
+	cache := Alloc()
+
+	added, err := cache.ZAdd("zset", util.ZMember{Member: "m1", Score: 3}, util.ZMember{Member: "m2", Score: 1})
+	if err != nil || added != 2 {
+		t.Errorf("Expected 2, but it was %d (%v) instead.", added, err)
+	}
+
+	added, _ = cache.ZAdd("zset", util.ZMember{Member: "m1", Score: 0}, util.ZMember{Member: "m3", Score: 2})
+	if added != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", added)
+	}
+
+	v, _ := cache.Get("zset")
+	expected := util.ZSet{{Member: "m1", Score: 0}, {Member: "m2", Score: 1}, {Member: "m3", Score: 2}}
+	if !equalZSets(v.(util.ZSet), expected) {
+		t.Errorf("Expected %v, but it was %v instead.", expected, v)
+	}
+
+	cache.SetInt("int", 1)
+	if _, err := cache.ZAdd("int", util.ZMember{Member: "m1"}); err != util.ErrorWrongType {
+		t.Errorf("Expected ErrorWrongType, but it was %v instead.", err)
+	}
+
+	if cache.Stats().KeysByType["zset"] != 1 {
+		t.Errorf("Expected one zset, but it was %v instead.", cache.Stats().KeysByType)
+	}
+}
+
+func TestZIncrBy(t *testing.T) {
+	t.Log("Testing ZIncrBy...")
+
+	cache := Alloc()
+
+	score, _ := cache.ZIncrBy("zset", 5, "m1")
+	if score != 5 {
+		t.Errorf("Expected 5, but it was %v instead.", score)
+	}
+
+	cache.ZAdd("zset", util.ZMember{Member: "m2", Score: 7})
+	score, _ = cache.ZIncrBy("zset", 2.5, "m1")
+	if score != 7.5 {
+		t.Errorf("Expected 7.5, but it was %v instead.", score)
+	}
+
+	rank, _ := cache.ZRank("zset", "m1")
+	if rank != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", rank)
+	}
+}
+
+func TestZSetNaN(t *testing.T) {
+	t.Log("Testing NaN scores are rejected...")
+
+	cache := Alloc()
+	cache.ZAdd("zset", util.ZMember{Member: "m1", Score: math.Inf(1)})
+
+	if _, err := cache.ZAdd("zset", util.ZMember{Member: "m2", Score: 1}, util.ZMember{Member: "m3", Score: math.NaN()}); err != util.ErrorInvalidScore {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorInvalidScore, err)
+	}
+	if _, err := cache.ZIncrBy("zset", math.NaN(), "m1"); err != util.ErrorInvalidScore {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorInvalidScore, err)
+	}
+	if _, err := cache.ZIncrBy("zset", math.Inf(-1), "m1"); err != util.ErrorInvalidScore {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorInvalidScore, err)
+	}
+	if _, err := cache.ZIncrBy("other", math.NaN(), "m1"); err != util.ErrorInvalidScore {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorInvalidScore, err)
+	}
+
+	// Nothing is changed by rejected writes
+	v, _ := cache.Get("zset")
+	if !equalZSets(v.(util.ZSet), util.ZSet{{Member: "m1", Score: math.Inf(1)}}) {
+		t.Errorf("Expected only m1, but it was %v instead.", v)
+	}
+	if _, err := cache.Get("other"); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorKeyNotFound, err)
+	}
+}
+
+func TestZRange(t *testing.T) {
+	t.Log("Testing ZRange and ZRangeByScore...")
+
+	cache := Alloc()
+	for i := 0; i < 10; i++ {
+		cache.ZAdd("zset", util.ZMember{Member: "m" + strconv.Itoa(i), Score: float64(i)})
+	}
+
+	v, _ := cache.ZRange("zset", -3, -1)
+	if !equalZSets(v, util.ZSet{{Member: "m7", Score: 7}, {Member: "m8", Score: 8}, {Member: "m9", Score: 9}}) {
+		t.Errorf("Expected last three members, but it was %v instead.", v)
+	}
+
+	v, _ = cache.ZRange("zset", 8, 100)
+	if len(v) != 2 {
+		t.Errorf("Expected 2, but it was %d instead.", len(v))
+	}
+
+	v, _ = cache.ZRange("zset", 5, 2)
+	if len(v) != 0 {
+		t.Errorf("Expected 0, but it was %d instead.", len(v))
+	}
+
+	v, _ = cache.ZRangeByScore("zset", 2.5, 5)
+	if !equalZSets(v, util.ZSet{{Member: "m3", Score: 3}, {Member: "m4", Score: 4}, {Member: "m5", Score: 5}}) {
+		t.Errorf("Expected m3 to m5, but it was %v instead.", v)
+	}
+
+	v, _ = cache.ZRangeByScore("zset", math.Inf(-1), math.Inf(1))
+	if len(v) != 10 {
+		t.Errorf("Expected 10, but it was %d instead.", len(v))
+	}
+
+	if _, err := cache.ZRange("missing", 0, -1); err != util.ErrorKeyNotFound {
+		t.Errorf("Expected ErrorKeyNotFound, but it was %v instead.", err)
+	}
+}
+
+func TestZRem(t *testing.T) {
+	t.Log("Testing ZRem and ZRemRangeByScore...")
+
+	cache := Alloc()
+	for i := 0; i < 10; i++ {
+		cache.ZAdd("zset", util.ZMember{Member: "m" + strconv.Itoa(i), Score: float64(i)})
+	}
+
+	removed, _ := cache.ZRem("zset", "m0", "missing")
+	if removed != 1 {
+		t.Errorf("Expected 1, but it was %d instead.", removed)
+	}
+
+	if _, err := cache.ZScore("zset", "m0"); err != util.ErrorMemberNotFound {
+		t.Errorf("Expected ErrorMemberNotFound, but it was %v instead.", err)
+	}
+
+	removed, _ = cache.ZRemRangeByScore("zset", 3, 6)
+	if removed != 4 {
+		t.Errorf("Expected 4, but it was %d instead.", removed)
+	}
+
+	rank, _ := cache.ZRank("zset", "m7")
+	if rank != 2 {
+		t.Errorf("Expected 2, but it was %d instead.", rank)
+	}
+
+	cache.ZRemRangeByScore("zset", math.Inf(-1), math.Inf(1))
+	if cache.Len() != 0 {
+		t.Errorf("Expected 0, but it was %d instead.", cache.Len())
+	}
+}
+
+func TestZSetSize(t *testing.T) {
+	t.Log("Testing sorted set size accounting...")
+
+	cache := AllocWithConfig(Config{MaxBytes: 1 << 20})
+
+	cache.ZAdd("zset", util.ZMember{Member: "m1", Score: 1})
+	cache.ZAdd("zset", util.ZMember{Member: "m2", Score: 2})
+	cache.ZIncrBy("zset", 1, "m3")
+	cache.ZRem("zset", "m1")
+
+	z := cache.shard("zset").data["zset"].value
+	if cache.eviction.bytes != sizeOf("zset", z) {
+		t.Errorf("Expected %d, but it was %d instead.", sizeOf("zset", z), cache.eviction.bytes)
+	}
+}
+
+func equalZSets(a, b util.ZSet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
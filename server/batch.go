@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/anevsky/cachego/memory"
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+)
+
+// Queue batch operation into transaction, value is decoded by the operation
+type batchOp func(tx *memory.Tx, op util.BatchOp) error
+
+// Batch operations by name, the names follow CACHE methods
+var batchOps = map[string]batchOp{
+	"get": func(tx *memory.Tx, op util.BatchOp) error {
+		tx.Get(op.Key)
+		return nil
+	},
+	"get_list_element": func(tx *memory.Tx, op util.BatchOp) error {
+		var index int
+		return decodeBatchValue(op, &index, func() { tx.GetListElement(op.Key, index) })
+	},
+	"get_dict_element": func(tx *memory.Tx, op util.BatchOp) error {
+		tx.GetDictElement(op.Key, op.Field)
+		return nil
+	},
+	"set_string": func(tx *memory.Tx, op util.BatchOp) error {
+		var v string
+		return decodeBatchValue(op, &v, func() { tx.SetString(op.Key, v, op.TTL) })
+	},
+	"set_int": func(tx *memory.Tx, op util.BatchOp) error {
+		var v int
+		return decodeBatchValue(op, &v, func() { tx.SetInt(op.Key, v, op.TTL) })
+	},
+	"set_list": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.List
+		return decodeBatchValue(op, &v, func() { tx.SetList(op.Key, v, op.TTL) })
+	},
+	"set_dict": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.Dict
+		return decodeBatchValue(op, &v, func() { tx.SetDict(op.Key, v, op.TTL) })
+	},
+	"update_string": func(tx *memory.Tx, op util.BatchOp) error {
+		var v string
+		return decodeBatchValue(op, &v, func() { tx.UpdateString(op.Key, v) })
+	},
+	"update_int": func(tx *memory.Tx, op util.BatchOp) error {
+		var v int
+		return decodeBatchValue(op, &v, func() { tx.UpdateInt(op.Key, v) })
+	},
+	"update_list": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.List
+		return decodeBatchValue(op, &v, func() { tx.UpdateList(op.Key, v) })
+	},
+	"update_dict": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.Dict
+		return decodeBatchValue(op, &v, func() { tx.UpdateDict(op.Key, v) })
+	},
+	"remove": func(tx *memory.Tx, op util.BatchOp) error {
+		tx.Remove(op.Key)
+		return nil
+	},
+	"increment": func(tx *memory.Tx, op util.BatchOp) error {
+		tx.Increment(op.Key)
+		return nil
+	},
+	"append_to_list": func(tx *memory.Tx, op util.BatchOp) error {
+		var v string
+		return decodeBatchValue(op, &v, func() { tx.AppendToList(op.Key, v) })
+	},
+	"remove_from_list": func(tx *memory.Tx, op util.BatchOp) error {
+		var v string
+		return decodeBatchValue(op, &v, func() { tx.RemoveFromList(op.Key, v) })
+	},
+	"set_dict_element": func(tx *memory.Tx, op util.BatchOp) error {
+		var v string
+		return decodeBatchValue(op, &v, func() { tx.SetDictElement(op.Key, op.Field, v) })
+	},
+	"remove_from_dict": func(tx *memory.Tx, op util.BatchOp) error {
+		tx.RemoveFromDict(op.Key, op.Field)
+		return nil
+	},
+	"sadd": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.List
+		return decodeBatchValue(op, &v, func() { tx.SAdd(op.Key, v...) })
+	},
+	"srem": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.List
+		return decodeBatchValue(op, &v, func() { tx.SRem(op.Key, v...) })
+	},
+	"zadd": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.ZSet
+		return decodeBatchValue(op, &v, func() { tx.ZAdd(op.Key, v...) })
+	},
+	"zincrby": func(tx *memory.Tx, op util.BatchOp) error {
+		var v float64
+		return decodeBatchValue(op, &v, func() { tx.ZIncrBy(op.Key, v, op.Field) })
+	},
+	"zrem": func(tx *memory.Tx, op util.BatchOp) error {
+		var v util.List
+		return decodeBatchValue(op, &v, func() { tx.ZRem(op.Key, v...) })
+	},
+	"set_ttl": func(tx *memory.Tx, op util.BatchOp) error {
+		tx.SetTTL(op.Key, op.TTL)
+		return nil
+	},
+	"persist": func(tx *memory.Tx, op util.BatchOp) error {
+		tx.Persist(op.Key)
+		return nil
+	},
+}
+
+// Decode value of the operation into v, then queue it
+func decodeBatchValue(op util.BatchOp, v interface{}, queue func()) error {
+	if err := json.Unmarshal(op.Value, v); err != nil {
+		return util.ErrorBadRequest
+	}
+
+	queue()
+
+	return nil
+}
+
+// Run operations atomically, either all of them take effect or none
+// Returns results in the order of operations
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"ops":[{"op":"remove_from_list","key":"l1","value":"a"},{"op":"append_to_list","key":"l2","value":"a"}]}' localhost:8027/v1/batch
+func (server *SERVER) batch(c echo.Context) error {
+	value := new(util.BatchDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	tx := server.cache.Multi()
+	keys := make([]string, len(value.Ops))
+	for i, op := range value.Ops {
+		queue, ok := batchOps[op.Op]
+		if !ok {
+			return makeJSONError(c, util.ErrorUnknownBatchOp)
+		}
+		if err := queue(tx, op); err != nil {
+			return makeJSONError(c, err)
+		}
+		keys[i] = op.Key
+	}
+
+	return server.routeKeys(c, keys, func() error {
+		results, err := tx.Exec()
+		if err != nil {
+			return makeJSONError(c, err)
+		}
+
+		dto := util.BatchDTO{Results: make([]json.RawMessage, len(results))}
+		for i, result := range results {
+			if dto.Results[i], err = json.Marshal(result); err != nil {
+				return makeJSONError(c, err)
+			}
+		}
+
+		return c.JSON(http.StatusOK, dto)
+	})
+}
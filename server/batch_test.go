@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestBatch(t *testing.T) {
+	t.Log("Testing batch endpoint with client transactions...")
+
+	server := Create()
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	cli := clusterClient(ts.URL)
+	cli.SetList("todo", util.List{"a", "b"})
+	cli.SetList("done", util.List{})
+	cli.SetInt("counter", 5)
+
+	results, errs := cli.Multi().
+		RemoveFromList("todo", "a").
+		AppendToList("done", "a").
+		Increment("counter").
+		SetDictElement("dict", "k", "v").
+		GetDictElement("dict", "k").
+		ZIncrBy("zset", 1.5, "m").
+		Exec()
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	expected := []interface{}{0, nil, 6, true, "v", 1.5}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %v, but it was %v instead.", expected, results)
+	}
+
+	// Wrong type undoes the whole batch
+	_, errs = cli.Multi().
+		Increment("counter").
+		AppendToList("done", "b").
+		GetListElement("counter", 0).
+		Exec()
+	if errs == nil {
+		t.Errorf("Expected error, but it was nil instead.")
+	}
+	if v, _ := cli.GetInt("counter"); v != 6 {
+		t.Errorf("Expected 6, but it was %d instead.", v)
+	}
+	if v, _ := server.cache.Get("done"); !reflect.DeepEqual(v, util.List{"a"}) {
+		t.Errorf("Expected [a], but it was %v instead.", v)
+	}
+}
@@ -0,0 +1,695 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+)
+
+const (
+	// Every node exchanges its view with a random peer this often
+	gossipInterval = 100 * time.Millisecond
+	// Redirects to a node importing the slot carry this query parameter
+	askingParam = "asking"
+	// Keys moved to the target of a migration in one request
+	migrateBatch = 100
+)
+
+// Cluster state of a node
+// Slots are owned by the node claiming them with the highest epoch.
+// A node raises its epoch above all known ones whenever its slots change,
+// so gossip converges on the latest assignment.
+type cluster struct {
+	sync.RWMutex
+	self string
+	// Announced address of the Redis protocol listener of this node
+	selfRESP string
+	nodes    map[string]util.ClusterNode
+	owners   [util.SlotCount]string
+	// Slots being moved away by this node, by target
+	migrating map[int]string
+	// Slots being moved to this node
+	importing map[int]bool
+
+	// Held for reading by requests, for writing while keys move to another node
+	moving sync.RWMutex
+}
+
+func newCluster(self, selfRESP string) *cluster {
+	return &cluster{
+		self:      self,
+		selfRESP:  selfRESP,
+		nodes:     map[string]util.ClusterNode{self: {URL: self, RESPAddr: selfRESP, Slots: []util.SlotRange{}}},
+		migrating: map[int]string{},
+		importing: map[int]bool{},
+	}
+}
+
+// Join cluster as node reachable at url, e.g. http://10.0.0.1:8027
+// Node owns no slots until they are assigned or migrated to it
+// Its RESP listener is announced with the host of url when the listen address has none, e.g. :8028
+func (server *SERVER) EnableCluster(url string) {
+	url = strings.TrimSuffix(url, "/")
+	server.cluster = newCluster(url, announcedAddr(url, server.config.RESPAddr))
+}
+
+// Address other hosts reach listen address at, empty if listen is
+func announcedAddr(url, listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if u, err := neturl.Parse(url); err == nil {
+			host = u.Hostname()
+		}
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// Introduce node to another cluster member, gossip spreads the news
+func (server *SERVER) Meet(url string) error {
+	if server.cluster == nil {
+		return util.ErrorClusterDisabled
+	}
+
+	return server.exchangeViews(strings.TrimSuffix(url, "/"))
+}
+
+// Claim slots from start to stop inclusive, to set up a new cluster
+func (server *SERVER) AssignSlots(start, stop int) error {
+	if server.cluster == nil {
+		return util.ErrorClusterDisabled
+	}
+	if start < 0 || stop >= util.SlotCount || start > stop {
+		return util.ErrorIndexOutOfBounds
+	}
+
+	slots := []int{}
+	for slot := start; slot <= stop; slot++ {
+		slots = append(slots, slot)
+	}
+	server.cluster.claim(slots, 0)
+
+	return nil
+}
+
+// Move slot with its keys to target node, requests keep being served meanwhile
+// Keys still here are served here, others are redirected to the target.
+// A failed migration can be resumed by calling it again.
+func (server *SERVER) MigrateSlot(slot int, target string) error {
+	cl := server.cluster
+	if cl == nil {
+		return util.ErrorClusterDisabled
+	}
+	target = strings.TrimSuffix(target, "/")
+
+	cl.Lock()
+	if cl.owners[slot] != cl.self || target == cl.self {
+		cl.Unlock()
+		return util.ErrorSlotNotOwned
+	}
+	cl.Unlock()
+
+	if err := server.callNode(http.MethodPost, target+"/v1/cluster/import", util.SlotDTO{Slot: slot}, nil); err != nil {
+		return err
+	}
+
+	cl.Lock()
+	cl.migrating[slot] = target
+	cl.Unlock()
+
+	for keys := server.keysInSlot(slot); len(keys) > 0; keys = server.keysInSlot(slot) {
+		for start := 0; start < len(keys); start += migrateBatch {
+			end := start + migrateBatch
+			if end > len(keys) {
+				end = len(keys)
+			}
+
+			if err := server.moveKeys(keys[start:end], target); err != nil {
+				return err
+			}
+		}
+	}
+
+	var dto util.ClusterDTO
+	if err := server.callNode(http.MethodPost, target+"/v1/cluster/claim", util.SlotDTO{Slot: slot, Epoch: cl.epoch()}, &dto); err != nil {
+		return err
+	}
+	cl.merge(dto.Nodes)
+	cl.release(slot)
+
+	return nil
+}
+
+func (server *SERVER) keysInSlot(slot int) []string {
+	keys := []string{}
+	for _, key := range server.cache.Keys() {
+		if util.KeySlot(key) == slot {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Copy keys to target and remove them here, requests wait meanwhile
+func (server *SERVER) moveKeys(keys []string, target string) error {
+	cl := server.cluster
+	cl.moving.Lock()
+	defer cl.moving.Unlock()
+
+	var batch bytes.Buffer
+	moved := []string{}
+	for _, key := range keys {
+		// Key may be gone since it was listed
+		if data, err := server.cache.Dump(key); err == nil {
+			batch.Write(data)
+			moved = append(moved, key)
+		}
+	}
+	if len(moved) == 0 {
+		return nil
+	}
+
+	if err := server.callNode(http.MethodPost, target+"/v1/cluster/restore", &batch, nil); err != nil {
+		return err
+	}
+
+	for _, key := range moved {
+		server.cache.Remove(key)
+	}
+
+	return nil
+}
+
+// Exchange views with cluster members until stop is closed, nil stop means forever
+func (server *SERVER) gossip(stop <-chan struct{}) {
+	ticker := time.NewTicker(gossipInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if peer := server.cluster.randomPeer(); peer != "" {
+				// Unreachable peers are retried on later rounds
+				server.exchangeViews(peer)
+			}
+		}
+	}
+}
+
+func (server *SERVER) exchangeViews(peer string) error {
+	var dto util.ClusterDTO
+	err := server.callNode(http.MethodPost, peer+"/v1/cluster/gossip", util.ClusterDTO{Nodes: server.cluster.view()}, &dto)
+	if err != nil {
+		return err
+	}
+
+	server.cluster.merge(dto.Nodes)
+	return nil
+}
+
+// Call another node, body is sent as JSON unless it is a reader
+func (server *SERVER) callNode(method, url string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if r, ok := body.(io.Reader); ok {
+		reader = r
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.SetBasicAuth(server.nodeCredentials())
+
+	res, err := server.nodeClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var basic util.BasicDTO
+	if err := json.Unmarshal(data, &basic); err != nil {
+		return err
+	}
+	if basic.ErrorCode != 0 {
+		return util.DecodeError(basic.ErrorCode, basic.ErrorMessage)
+	}
+
+	if result != nil {
+		return json.Unmarshal(data, result)
+	}
+
+	return nil
+}
+
+///////////////////////////////////////
+// Cluster state
+///////////////////////////////////////
+
+// Highest known epoch
+// Caller must hold the lock
+func (cl *cluster) maxEpoch() uint64 {
+	var epoch uint64
+	for _, node := range cl.nodes {
+		if node.Epoch > epoch {
+			epoch = node.Epoch
+		}
+	}
+
+	return epoch
+}
+
+func (cl *cluster) epoch() uint64 {
+	cl.RLock()
+	defer cl.RUnlock()
+
+	return cl.maxEpoch()
+}
+
+// Nodes sorted by url
+func (cl *cluster) view() []util.ClusterNode {
+	cl.RLock()
+	defer cl.RUnlock()
+
+	nodes := make([]util.ClusterNode, 0, len(cl.nodes))
+	for _, node := range cl.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].URL < nodes[j].URL })
+
+	return nodes
+}
+
+func (cl *cluster) randomPeer() string {
+	cl.RLock()
+	defer cl.RUnlock()
+
+	peers := []string{}
+	for url := range cl.nodes {
+		if url != cl.self {
+			peers = append(peers, url)
+		}
+	}
+	if len(peers) == 0 {
+		return ""
+	}
+
+	return peers[rand.Intn(len(peers))]
+}
+
+// Take newer records of other nodes, only this node changes its own record
+func (cl *cluster) merge(nodes []util.ClusterNode) {
+	cl.Lock()
+	defer cl.Unlock()
+
+	changed := false
+	for _, node := range nodes {
+		if node.URL == cl.self || node.URL == "" || !validSlotRanges(node.Slots) {
+			continue
+		}
+
+		known, ok := cl.nodes[node.URL]
+		if !ok || node.Epoch > known.Epoch {
+			cl.nodes[node.URL] = node
+			changed = true
+		}
+	}
+
+	if changed {
+		cl.assignOwners()
+	}
+}
+
+// Records with slots out of the slot table are ignored, they can't be assigned
+func validSlotRanges(ranges []util.SlotRange) bool {
+	for _, r := range ranges {
+		if r.Start < 0 || r.Stop >= util.SlotCount || r.Start > r.Stop {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add slots to this node with an epoch above all known ones and atLeast
+func (cl *cluster) claim(slots []int, atLeast uint64) {
+	cl.Lock()
+	defer cl.Unlock()
+
+	owned := cl.ownedSlots()
+	for _, slot := range slots {
+		owned[slot] = true
+		delete(cl.importing, slot)
+	}
+	cl.updateSelf(owned, atLeast)
+}
+
+// Remove slot from this node once another one has claimed it
+func (cl *cluster) release(slot int) {
+	cl.Lock()
+	defer cl.Unlock()
+
+	owned := cl.ownedSlots()
+	owned[slot] = false
+	delete(cl.migrating, slot)
+	cl.updateSelf(owned, 0)
+}
+
+// Caller must hold the lock
+func (cl *cluster) ownedSlots() []bool {
+	owned := make([]bool, util.SlotCount)
+	for _, r := range cl.nodes[cl.self].Slots {
+		for slot := r.Start; slot <= r.Stop; slot++ {
+			owned[slot] = true
+		}
+	}
+
+	return owned
+}
+
+// Caller must hold the lock
+func (cl *cluster) updateSelf(owned []bool, atLeast uint64) {
+	epoch := cl.maxEpoch()
+	if atLeast > epoch {
+		epoch = atLeast
+	}
+
+	ranges := []util.SlotRange{}
+	for slot := 0; slot < util.SlotCount; slot++ {
+		if !owned[slot] {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].Stop == slot-1 {
+			ranges[n-1].Stop = slot
+		} else {
+			ranges = append(ranges, util.SlotRange{Start: slot, Stop: slot})
+		}
+	}
+
+	cl.nodes[cl.self] = util.ClusterNode{URL: cl.self, RESPAddr: cl.selfRESP, Epoch: epoch + 1, Slots: ranges}
+	cl.assignOwners()
+}
+
+// Rebuild slot table from node records
+// Caller must hold the lock
+func (cl *cluster) assignOwners() {
+	var epochs [util.SlotCount]uint64
+	for i := range cl.owners {
+		cl.owners[i] = ""
+	}
+
+	for _, node := range cl.nodes {
+		for _, r := range node.Slots {
+			for slot := r.Start; slot <= r.Stop && slot < util.SlotCount; slot++ {
+				owner := cl.owners[slot]
+				// Ties go to the smallest url, so every node picks the same owner
+				if owner == "" || node.Epoch > epochs[slot] || (node.Epoch == epochs[slot] && node.URL < owner) {
+					cl.owners[slot] = node.URL
+					epochs[slot] = node.Epoch
+				}
+			}
+		}
+	}
+}
+
+// Node serving keys of the slot, empty if it is this one
+// Keys being migrated are served here while they are still here.
+// Returns true with the node if the request must be marked as asking.
+func (cl *cluster) route(slot int, asking bool, here func() bool) (string, bool, error) {
+	cl.RLock()
+	defer cl.RUnlock()
+
+	owner := cl.owners[slot]
+	switch {
+	case owner == cl.self:
+		if target, ok := cl.migrating[slot]; ok && !here() {
+			return target, true, nil
+		}
+		return "", false, nil
+	case asking && cl.importing[slot]:
+		return "", false, nil
+	case owner == "":
+		return "", false, util.ErrorSlotNotOwned
+	default:
+		return owner, false, nil
+	}
+}
+
+// Announced RESP address of node, empty if unknown or disabled
+func (cl *cluster) respAddr(url string) string {
+	cl.RLock()
+	defer cl.RUnlock()
+
+	return cl.nodes[url].RESPAddr
+}
+
+// Slot shared by all keys
+func keysSlot(keys []string) (int, error) {
+	slot := util.KeySlot(keys[0])
+	for _, key := range keys[1:] {
+		if util.KeySlot(key) != slot {
+			return 0, util.ErrorCrossSlot
+		}
+	}
+
+	return slot, nil
+}
+
+///////////////////////////////////////
+// Handlers
+///////////////////////////////////////
+
+// Redirect requests for keys owned by other nodes
+func (server *SERVER) routed(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Param("key")
+		if key == "" {
+			return next(c)
+		}
+
+		return server.routeKeys(c, []string{key}, func() error {
+			return next(c)
+		})
+	}
+}
+
+// Serve request for keys here or redirect it to their node
+// Redirects keep method and body, so clients follow them transparently
+func (server *SERVER) routeKeys(c echo.Context, keys []string, serve func() error) error {
+	if err := server.authorize(c, "", false, keys); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	cl := server.cluster
+	if cl == nil || len(keys) == 0 {
+		return serve()
+	}
+
+	slot, err := keysSlot(keys)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	cl.moving.RLock()
+	defer cl.moving.RUnlock()
+
+	node, asking, err := cl.route(slot, c.QueryParam(askingParam) != "", func() bool {
+		for _, key := range keys {
+			if ok, _ := server.cache.HasKey(key); !ok {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+	if node == "" {
+		return serve()
+	}
+
+	u := *c.Request().URL
+	query := u.Query()
+	query.Del(askingParam)
+	if asking {
+		query.Set(askingParam, "1")
+	}
+	location := node + u.Path
+	if len(query) > 0 {
+		location += "?" + query.Encode()
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, location)
+	return c.JSON(http.StatusTemporaryRedirect,
+		util.BasicDTO{ErrorCode: util.ErrorMoved.Code, ErrorMessage: util.ErrorMoved.Error()})
+}
+
+// Get cluster nodes with their slots
+// curl -i -w "\n" --user alex:secret localhost:8027/v1/cluster/nodes
+func (server *SERVER) clusterNodes(c echo.Context) error {
+	if server.cluster == nil {
+		return makeJSONError(c, util.ErrorClusterDisabled)
+	}
+
+	return c.JSON(http.StatusOK, util.ClusterDTO{Nodes: server.cluster.view()})
+}
+
+// Merge view of another node, returns view of this one
+func (server *SERVER) clusterGossip(c echo.Context) error {
+	if server.cluster == nil {
+		return makeJSONError(c, util.ErrorClusterDisabled)
+	}
+
+	value := new(util.ClusterDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	server.cluster.merge(value.Nodes)
+
+	return c.JSON(http.StatusOK, util.ClusterDTO{Nodes: server.cluster.view()})
+}
+
+// Introduce node to another cluster member
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":"http://localhost:8029"}' localhost:8027/v1/cluster/meet
+func (server *SERVER) clusterMeet(c echo.Context) error {
+	value := new(util.StringDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	if err := server.Meet(value.Value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Claim range of slots for this node
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"start":0,"stop":16383}' localhost:8027/v1/cluster/slots
+func (server *SERVER) clusterSlots(c echo.Context) error {
+	value := new(util.RangeDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	if err := server.AssignSlots(value.Start, value.Stop); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Move slot with its keys to another node
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"slot":12182,"target":"http://localhost:8029"}' localhost:8027/v1/cluster/migrate
+func (server *SERVER) clusterMigrate(c echo.Context) error {
+	value := new(util.SlotDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+	if value.Slot < 0 || value.Slot >= util.SlotCount {
+		return makeJSONError(c, util.ErrorIndexOutOfBounds)
+	}
+
+	if err := server.MigrateSlot(value.Slot, value.Target); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Accept redirected requests for slot migrating to this node
+func (server *SERVER) clusterImport(c echo.Context) error {
+	if server.cluster == nil {
+		return makeJSONError(c, util.ErrorClusterDisabled)
+	}
+
+	value := new(util.SlotDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+	if value.Slot < 0 || value.Slot >= util.SlotCount {
+		return makeJSONError(c, util.ErrorIndexOutOfBounds)
+	}
+
+	server.cluster.Lock()
+	server.cluster.importing[value.Slot] = true
+	server.cluster.Unlock()
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Store keys dumped by the node migrating their slot here, one dump per line
+func (server *SERVER) clusterRestore(c echo.Context) error {
+	if server.cluster == nil {
+		return makeJSONError(c, util.ErrorClusterDisabled)
+	}
+
+	reader := bufio.NewReader(c.Request().Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := server.cache.Restore(line); err != nil {
+				return makeJSONError(c, err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return makeJSONError(c, err)
+		}
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Take over slot whose keys were all migrated here, returns view of this node
+func (server *SERVER) clusterClaim(c echo.Context) error {
+	if server.cluster == nil {
+		return makeJSONError(c, util.ErrorClusterDisabled)
+	}
+
+	value := new(util.SlotDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+	if value.Slot < 0 || value.Slot >= util.SlotCount {
+		return makeJSONError(c, util.ErrorIndexOutOfBounds)
+	}
+
+	server.cluster.claim([]int{value.Slot}, value.Epoch)
+
+	return c.JSON(http.StatusOK, util.ClusterDTO{Nodes: server.cluster.view()})
+}
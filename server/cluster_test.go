@@ -0,0 +1,223 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anevsky/cachego/client"
+	"github.com/anevsky/cachego/util"
+)
+
+// Nodes on localhost, slots are split between the first two
+// Node i announces RESP listener on port 7000+i
+func startCluster(t *testing.T, n int) ([]*SERVER, []*httptest.Server, func()) {
+	servers := make([]*SERVER, n)
+	listeners := make([]*httptest.Server, n)
+	stop := make(chan struct{})
+
+	for i := range servers {
+		config := DefaultConfig()
+		config.RESPAddr = ":" + strconv.Itoa(7000+i)
+		server := CreateWithConfig(config)
+		servers[i] = &server
+		listeners[i] = httptest.NewServer(server.handler())
+		server.EnableCluster(listeners[i].URL)
+	}
+
+	servers[0].AssignSlots(0, util.SlotCount/2-1)
+	servers[1].AssignSlots(util.SlotCount/2, util.SlotCount-1)
+	for i := 1; i < n; i++ {
+		if err := servers[i].Meet(listeners[0].URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, server := range servers {
+		go server.gossip(stop)
+	}
+
+	return servers, listeners, func() {
+		close(stop)
+		for _, ts := range listeners {
+			ts.Close()
+		}
+	}
+}
+
+// Wait until every node sees slot owned by the node
+func waitOwner(t *testing.T, servers []*SERVER, slot int, owner string) {
+	if !eventually(func() bool {
+		for _, server := range servers {
+			server.cluster.RLock()
+			ok := server.cluster.owners[slot] == owner && len(server.cluster.nodes) == len(servers)
+			server.cluster.RUnlock()
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Fatalf("Expected all nodes to see slot %d owned by %s, but they didn't.", slot, owner)
+	}
+}
+
+func clusterClient(url string) client.CLIENT {
+	cli := client.Create()
+	cli.Url = url
+	cli.APIUrl = "/v1"
+
+	return cli
+}
+
+func TestClusterRedirect(t *testing.T) {
+	t.Log("Testing cluster redirects requests to the owner of the key...")
+
+	servers, listeners, stop := startCluster(t, 3)
+	defer stop()
+
+	waitOwner(t, servers, util.KeySlot("foo"), listeners[1].URL)
+	waitOwner(t, servers, util.KeySlot("bar"), listeners[0].URL)
+
+	// All requests go to the node without slots
+	cli := clusterClient(listeners[2].URL)
+	for _, key := range []string{"foo", "bar"} {
+		if errs := cli.SetString(key, "v-"+key); errs != nil {
+			t.Fatal(errs)
+		}
+		if v, errs := cli.GetString(key); errs != nil || v != "v-"+key {
+			t.Errorf("Expected v-%s, but it was %s (%v) instead.", key, v, errs)
+		}
+	}
+
+	if ok, _ := servers[1].cache.HasKey("foo"); !ok {
+		t.Errorf("Expected 'foo' on the second node.")
+	}
+	if ok, _ := servers[0].cache.HasKey("bar"); !ok {
+		t.Errorf("Expected 'bar' on the first node.")
+	}
+	if servers[2].cache.Len() != 0 {
+		t.Errorf("Expected no keys on the third node, but it was %v instead.", servers[2].cache.Keys())
+	}
+
+	// Multi-key requests need keys in one slot
+	cli.SAdd("{s}1", "a", "b")
+	cli.SAdd("{s}2", "b", "c")
+	if v, errs := cli.SInter("{s}1", "{s}2"); errs != nil || !v.Has("b") || len(v) != 1 {
+		t.Errorf("Expected [b], but it was %v (%v) instead.", v, errs)
+	}
+	if _, errs := cli.SInter("foo", "bar"); errs == nil {
+		t.Errorf("Expected cross slot error, but it was nil instead.")
+	}
+
+	// RESP clients get the owner
+	c, stopRESP := serveRESP(t, servers[0])
+	defer stopRESP()
+	c.do(t, "AUTH", defaultPassword)
+	expected := "-MOVED " + strconv.Itoa(util.KeySlot("foo")) + " 127.0.0.1:7001"
+	if reply := c.do(t, "GET", "foo"); reply != expected {
+		t.Errorf("Expected %s, but it was %s instead.", expected, reply)
+	}
+	if reply := c.do(t, "GET", "bar"); reply != "v-bar" {
+		t.Errorf("Expected v-bar, but it was %s instead.", reply)
+	}
+	if reply := c.do(t, "DEL", "foo", "bar"); !strings.HasPrefix(reply, "-CROSSSLOT") {
+		t.Errorf("Expected CROSSSLOT error, but it was %s instead.", reply)
+	}
+}
+
+func TestClusterMigrateSlot(t *testing.T) {
+	t.Log("Testing slot migration between live nodes...")
+
+	servers, listeners, stop := startCluster(t, 3)
+	defer stop()
+
+	slot := util.KeySlot("foo")
+	waitOwner(t, servers, slot, listeners[1].URL)
+
+	cli := clusterClient(listeners[0].URL)
+	const keys = 250
+	for i := 0; i < keys; i++ {
+		if errs := cli.SetInt("{foo}"+strconv.Itoa(i), i); errs != nil {
+			t.Fatal(errs)
+		}
+	}
+	cli.SetInt("{foo}counter", 0)
+
+	// Writes keep going during migration
+	var wg sync.WaitGroup
+	wg.Add(1)
+	const increments = 200
+	go func() {
+		defer wg.Done()
+		writer := clusterClient(listeners[0].URL)
+		for i := 0; i < increments; i++ {
+			if _, errs := writer.Increment("{foo}counter"); errs != nil {
+				t.Error(errs)
+				return
+			}
+		}
+	}()
+
+	if err := servers[1].MigrateSlot(slot, listeners[2].URL); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if servers[1].cache.Len() != 0 {
+		t.Errorf("Expected no keys left on the source, but it was %v instead.", servers[1].cache.Keys())
+	}
+	if n := servers[2].cache.Len(); n != keys+1 {
+		t.Errorf("Expected %d keys on the target, but it was %d instead.", keys+1, n)
+	}
+
+	if v, errs := cli.GetInt("{foo}counter"); errs != nil || v != increments {
+		t.Errorf("Expected %d, but it was %d (%v) instead.", increments, v, errs)
+	}
+	if v, errs := cli.GetInt("{foo}42"); errs != nil || v != 42 {
+		t.Errorf("Expected 42, but it was %d (%v) instead.", v, errs)
+	}
+
+	waitOwner(t, servers, slot, listeners[2].URL)
+
+	if err := servers[1].MigrateSlot(slot, listeners[0].URL); err != util.ErrorSlotNotOwned {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorSlotNotOwned, err)
+	}
+}
+
+func TestAnnouncedAddr(t *testing.T) {
+	t.Log("Testing announced addresses of listeners...")
+
+	cases := [][]string{
+		{"http://10.0.0.1:8027", ":8028", "10.0.0.1:8028"},
+		{"https://node1.example.com:8027", "0.0.0.0:8028", "node1.example.com:8028"},
+		{"http://10.0.0.1:8027", "10.0.1.1:8028", "10.0.1.1:8028"},
+		{"http://10.0.0.1:8027", "", ""},
+	}
+
+	for _, c := range cases {
+		if addr := announcedAddr(c[0], c[1]); addr != c[2] {
+			t.Errorf("Expected %s for %s and %s, but it was %s instead.", c[2], c[0], c[1], addr)
+		}
+	}
+}
+
+func TestClusterMergeInvalidSlots(t *testing.T) {
+	t.Log("Testing gossiped records with slots out of the slot table are ignored...")
+
+	cl := newCluster("http://node0", "")
+	cl.merge([]util.ClusterNode{
+		{URL: "http://node1", Epoch: 1, Slots: []util.SlotRange{{Start: -1, Stop: 10}}},
+		{URL: "http://node2", Epoch: 1, Slots: []util.SlotRange{{Start: 0, Stop: util.SlotCount}}},
+		{URL: "http://node3", Epoch: 1, Slots: []util.SlotRange{{Start: 20, Stop: 10}}},
+		{URL: "http://node4", Epoch: 1, Slots: []util.SlotRange{{Start: 0, Stop: util.SlotCount - 1}}},
+	})
+
+	if len(cl.nodes) != 2 {
+		t.Errorf("Expected only node4 to be added, but nodes were %v instead.", cl.nodes)
+	}
+	if cl.owners[0] != "http://node4" || cl.owners[util.SlotCount-1] != "http://node4" {
+		t.Errorf("Expected node4 to own all slots, but it was %s instead.", cl.owners[0])
+	}
+}
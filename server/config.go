@@ -0,0 +1,472 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anevsky/cachego/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultHTTPAddr = ":8027"
+
+	defaultUsername = "alex"
+	defaultPassword = "secret"
+
+	defaultSnapshotInterval = 5 * time.Minute
+
+	// Prefix of environment variables overriding the config file
+	envPrefix = "CACHEGO_"
+)
+
+// Config Server settings
+// Defaults are overridden by a JSON file, then by CACHEGO_* environment variables, then by command-line flags
+type Config struct {
+	// Listen address of the HTTP API
+	HTTPAddr string `json:"http_addr"`
+	// Listen address of the Redis protocol, empty - disabled
+	RESPAddr string `json:"resp_addr"`
+	// Users allowed to access the API
+	// Cluster nodes talk to each other as the first one, it needs full access
+	Users       []User            `json:"users"`
+	TLS         TLSConfig         `json:"tls"`
+	Limits      LimitsConfig      `json:"limits"`
+	Persistence PersistenceConfig `json:"persistence"`
+	Replication ReplicationConfig `json:"replication"`
+	Cluster     ClusterConfig     `json:"cluster"`
+}
+
+// User Credentials and access rules of an API user
+type User struct {
+	Username string `json:"username"`
+	// Either plain password or its bcrypt hash, e.g. from cachego hash-password
+	Password     string `json:"password,omitempty"`
+	PasswordHash string `json:"password_hash,omitempty"`
+	// Disabled users are rejected until enabled at runtime
+	Disabled bool `json:"disabled,omitempty"`
+	ACL      ACL  `json:"acl"`
+}
+
+// ACL What a user may access, empty ACL allows everything
+type ACL struct {
+	// Writes of keys are rejected
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Allowed route groups: keys, keyspace, pubsub, admin, empty - all
+	Groups []string `json:"groups,omitempty"`
+	// Glob patterns of allowed keys, empty - all
+	// Users limited to some keys can't use the keyspace group
+	Keys []string `json:"keys,omitempty"`
+}
+
+// TLSConfig Certificate and key of the HTTP API and the Redis protocol, plain text when empty
+// The files are read again on SIGHUP
+type TLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// CA bundle clients have to present a certificate signed by, empty - no client certificates
+	// Cluster nodes present their own certificates to each other
+	ClientCAFile string `json:"client_ca_file"`
+	// CA bundle verifying certificates of other nodes, empty - client_ca_file,
+	// or the issuers following the certificate in cert_file when that is empty too
+	CAFile string `json:"ca_file"`
+}
+
+// LimitsConfig Bounds of the cache, see memory.Config
+type LimitsConfig struct {
+	// Maximum number of keys, 0 - unlimited
+	MaxEntries int `json:"max_entries"`
+	// Approximate memory budget in bytes, 0 - unlimited
+	MaxBytes int64 `json:"max_bytes"`
+	// One of lru, lfu, 2q, random, volatile-lru
+	EvictionPolicy string `json:"eviction_policy"`
+	// Number of independently locked shards, 16 if 0
+	Shards int `json:"shards"`
+}
+
+// PersistenceConfig Where the cache is saved
+type PersistenceConfig struct {
+	// Snapshot file, empty - no snapshots
+	SnapshotPath     string   `json:"snapshot_path"`
+	SnapshotInterval Duration `json:"snapshot_interval"`
+	// Append-only log replacing snapshots as the source of data on start, empty - disabled
+	AppendLogPath string `json:"append_log_path"`
+	// One of everysec, always, no
+	AppendLogFsync string `json:"append_log_fsync"`
+}
+
+// ReplicationConfig Leader this server follows, writes are rejected while following
+type ReplicationConfig struct {
+	// URL of the leader, e.g. http://leader:8027, empty - not a follower
+	LeaderURL string `json:"leader_url"`
+	// Credentials of a leader user allowed to replicate
+	LeaderUsername string `json:"leader_username"`
+	LeaderPassword string `json:"leader_password"`
+}
+
+// ClusterConfig Membership of the server in a cluster sharing hash slots
+type ClusterConfig struct {
+	// URL other nodes reach this one at, e.g. http://10.0.0.1:8027, empty - not clustered
+	URL string `json:"url"`
+	// URLs of members met on start, gossip spreads the rest
+	Peers []string `json:"peers"`
+}
+
+// Duration Time interval written as a Go duration string in JSON, e.g. "5m"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+
+	return nil
+}
+
+var evictionPolicies = map[string]func() memory.EvictionPolicy{
+	"lru":          memory.NewLRU,
+	"lfu":          memory.NewLFU,
+	"2q":           memory.New2Q,
+	"random":       memory.NewRandom,
+	"volatile-lru": memory.NewVolatileLRU,
+}
+
+var fsyncPolicies = map[string]memory.Fsync{
+	"everysec": memory.FsyncEverySecond,
+	"always":   memory.FsyncAlways,
+	"no":       memory.FsyncNever,
+}
+
+// Default settings, a server listening on port 8027 with one user,
+// the Redis protocol listener and snapshots are off until configured
+func DefaultConfig() Config {
+	return Config{
+		HTTPAddr: defaultHTTPAddr,
+		Users:    []User{{Username: defaultUsername, Password: defaultPassword}},
+		Limits: LimitsConfig{
+			EvictionPolicy: "lru",
+		},
+		Persistence: PersistenceConfig{
+			SnapshotInterval: Duration{defaultSnapshotInterval},
+			AppendLogFsync:   "everysec",
+		},
+	}
+}
+
+// Setting overridable by an environment variable and a flag
+type setting struct {
+	flag  string
+	usage string
+	set   func(config *Config, value string) error
+}
+
+// Environment variable of the setting, e.g. CACHEGO_HTTP_ADDR for http-addr
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.Replace(s.flag, "-", "_", -1))
+}
+
+var settings = []setting{
+	{"http-addr", "listen address of the HTTP API", func(config *Config, v string) error {
+		config.HTTPAddr = v
+		return nil
+	}},
+	{"resp-addr", "listen address of the Redis protocol, empty - disabled", func(config *Config, v string) error {
+		config.RESPAddr = v
+		return nil
+	}},
+	{"users", "comma separated username:password pairs of users with full access", func(config *Config, v string) error {
+		config.Users = nil
+		for _, pair := range strings.Split(v, ",") {
+			i := strings.Index(pair, ":")
+			if i < 0 {
+				return fmt.Errorf("user %q is not username:password", pair)
+			}
+			config.Users = append(config.Users, User{Username: pair[:i], Password: pair[i+1:]})
+		}
+		return nil
+	}},
+	{"tls-cert", "certificate file, enables TLS", func(config *Config, v string) error {
+		config.TLS.CertFile = v
+		return nil
+	}},
+	{"tls-key", "private key file of the certificate", func(config *Config, v string) error {
+		config.TLS.KeyFile = v
+		return nil
+	}},
+	{"tls-client-ca", "CA bundle of required client certificates", func(config *Config, v string) error {
+		config.TLS.ClientCAFile = v
+		return nil
+	}},
+	{"tls-ca", "CA bundle of other nodes' certificates, empty - client CA bundle or issuers in the certificate file", func(config *Config, v string) error {
+		config.TLS.CAFile = v
+		return nil
+	}},
+	{"max-entries", "maximum number of keys, 0 - unlimited", func(config *Config, v string) (err error) {
+		config.Limits.MaxEntries, err = strconv.Atoi(v)
+		return err
+	}},
+	{"max-bytes", "approximate memory budget in bytes, 0 - unlimited", func(config *Config, v string) (err error) {
+		config.Limits.MaxBytes, err = strconv.ParseInt(v, 10, 64)
+		return err
+	}},
+	{"eviction-policy", "one of lru, lfu, 2q, random, volatile-lru", func(config *Config, v string) error {
+		config.Limits.EvictionPolicy = v
+		return nil
+	}},
+	{"shards", "number of independently locked shards", func(config *Config, v string) (err error) {
+		config.Limits.Shards, err = strconv.Atoi(v)
+		return err
+	}},
+	{"snapshot-path", "snapshot file, empty - no snapshots", func(config *Config, v string) error {
+		config.Persistence.SnapshotPath = v
+		return nil
+	}},
+	{"snapshot-interval", "period of snapshots, e.g. 5m", func(config *Config, v string) (err error) {
+		config.Persistence.SnapshotInterval.Duration, err = time.ParseDuration(v)
+		return err
+	}},
+	{"append-log-path", "append-only log file, empty - disabled", func(config *Config, v string) error {
+		config.Persistence.AppendLogPath = v
+		return nil
+	}},
+	{"append-log-fsync", "one of everysec, always, no", func(config *Config, v string) error {
+		config.Persistence.AppendLogFsync = v
+		return nil
+	}},
+	{"leader-url", "URL of the leader to follow, empty - not a follower", func(config *Config, v string) error {
+		config.Replication.LeaderURL = v
+		return nil
+	}},
+	{"leader-username", "user replicating the leader", func(config *Config, v string) error {
+		config.Replication.LeaderUsername = v
+		return nil
+	}},
+	{"leader-password", "password of the user replicating the leader", func(config *Config, v string) error {
+		config.Replication.LeaderPassword = v
+		return nil
+	}},
+	{"cluster-url", "URL other cluster nodes reach this one at, empty - not clustered", func(config *Config, v string) error {
+		config.Cluster.URL = v
+		return nil
+	}},
+	{"cluster-peers", "comma separated URLs of cluster members met on start", func(config *Config, v string) error {
+		config.Cluster.Peers = strings.Split(v, ",")
+		return nil
+	}},
+}
+
+// Load config from the JSON file given by the -config flag or CACHEGO_CONFIG,
+// then override it with environment variables and flags
+// getenv is usually os.Getenv, the config is validated
+func LoadConfig(name string, args []string, getenv func(string) string) (Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", getenv(envPrefix+"CONFIG"), "JSON config file")
+	bySetting := map[string]setting{}
+	for _, s := range settings {
+		fs.String(s.flag, "", s.usage+" ($"+s.env()+")")
+		bySetting[s.flag] = s
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	config := DefaultConfig()
+	if *path != "" {
+		data, err := ioutil.ReadFile(*path)
+		if err != nil {
+			return Config{}, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			return Config{}, fmt.Errorf("%s: %v", *path, err)
+		}
+	}
+
+	for _, s := range settings {
+		if v := getenv(s.env()); v != "" {
+			if err := s.set(&config, v); err != nil {
+				return Config{}, fmt.Errorf("$%s: %v", s.env(), err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if s, ok := bySetting[f.Name]; ok && err == nil {
+			if e := s.set(&config, f.Value.String()); e != nil {
+				err = fmt.Errorf("-%s: %v", f.Name, e)
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, config.Validate()
+}
+
+// Check settings are complete and consistent
+func (config Config) Validate() error {
+	if _, _, err := net.SplitHostPort(config.HTTPAddr); err != nil {
+		return fmt.Errorf("http_addr: %v", err)
+	}
+	if config.RESPAddr != "" {
+		if _, _, err := net.SplitHostPort(config.RESPAddr); err != nil {
+			return fmt.Errorf("resp_addr: %v", err)
+		}
+	}
+
+	if len(config.Users) == 0 {
+		return fmt.Errorf("users: at least one user is required")
+	}
+	usernames := map[string]bool{}
+	for _, user := range config.Users {
+		switch {
+		case user.Username == "" || strings.Contains(user.Username, ":"):
+			return fmt.Errorf("users: invalid username %q", user.Username)
+		case (user.Password == "") == (user.PasswordHash == ""):
+			return fmt.Errorf("users: either password or password_hash of %s is required", user.Username)
+		case usernames[user.Username]:
+			return fmt.Errorf("users: duplicate user %s", user.Username)
+		}
+		usernames[user.Username] = true
+
+		if user.PasswordHash != "" {
+			if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+				return fmt.Errorf("users: password_hash of %s: %v", user.Username, err)
+			}
+		}
+		for _, group := range user.ACL.Groups {
+			if !routeGroups[group] {
+				return fmt.Errorf("users: unknown group %q of %s", group, user.Username)
+			}
+		}
+		for _, pattern := range user.ACL.Keys {
+			if pattern == "" {
+				return fmt.Errorf("users: empty key pattern of %s", user.Username)
+			}
+		}
+	}
+
+	if (config.TLS.CertFile == "") != (config.TLS.KeyFile == "") {
+		return fmt.Errorf("tls: both cert_file and key_file are required")
+	}
+	if config.TLS.ClientCAFile != "" && config.TLS.CertFile == "" {
+		return fmt.Errorf("tls: client_ca_file requires cert_file")
+	}
+	if config.TLS.CAFile != "" && config.TLS.CertFile == "" {
+		return fmt.Errorf("tls: ca_file requires cert_file")
+	}
+
+	limits := config.Limits
+	if limits.MaxEntries < 0 || limits.MaxBytes < 0 || limits.Shards < 0 {
+		return fmt.Errorf("limits: negative limit")
+	}
+	if _, ok := evictionPolicies[limits.EvictionPolicy]; !ok {
+		return fmt.Errorf("limits: unknown eviction_policy %q", limits.EvictionPolicy)
+	}
+
+	persistence := config.Persistence
+	if persistence.SnapshotPath != "" && persistence.SnapshotInterval.Duration <= 0 {
+		return fmt.Errorf("persistence: snapshot_interval must be positive")
+	}
+	if _, ok := fsyncPolicies[persistence.AppendLogFsync]; !ok {
+		return fmt.Errorf("persistence: unknown append_log_fsync %q", persistence.AppendLogFsync)
+	}
+
+	replication := config.Replication
+	if replication.LeaderURL != "" {
+		if !validNodeURL(replication.LeaderURL) {
+			return fmt.Errorf("replication: invalid leader_url %q", replication.LeaderURL)
+		}
+		if replication.LeaderUsername == "" || replication.LeaderPassword == "" {
+			return fmt.Errorf("replication: leader_username and leader_password are required")
+		}
+	}
+
+	cluster := config.Cluster
+	if cluster.URL != "" {
+		if !validNodeURL(cluster.URL) {
+			return fmt.Errorf("cluster: invalid url %q", cluster.URL)
+		}
+		if replication.LeaderURL != "" {
+			return fmt.Errorf("cluster: followers can't be cluster nodes")
+		}
+	}
+	if len(cluster.Peers) > 0 && cluster.URL == "" {
+		return fmt.Errorf("cluster: peers require url")
+	}
+	for _, peer := range cluster.Peers {
+		if !validNodeURL(peer) {
+			return fmt.Errorf("cluster: invalid peer %q", peer)
+		}
+	}
+
+	return nil
+}
+
+// Absolute http or https URL of another server
+func validNodeURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Copy of the config with plain passwords hidden, safe to print
+func (config Config) Redacted() Config {
+	users := make([]User, len(config.Users))
+	for i, user := range config.Users {
+		if user.Password != "" {
+			user.Password = "********"
+		}
+		users[i] = user
+	}
+	config.Users = users
+	if config.Replication.LeaderPassword != "" {
+		config.Replication.LeaderPassword = "********"
+	}
+
+	return config
+}
+
+// bcrypt hash of password for the password_hash setting
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// Cache bounds of the config
+func (limits LimitsConfig) cacheConfig() memory.Config {
+	return memory.Config{
+		MaxEntries:     limits.MaxEntries,
+		MaxBytes:       limits.MaxBytes,
+		EvictionPolicy: evictionPolicies[limits.EvictionPolicy],
+		Shards:         limits.Shards,
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/client"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Log("Testing config from file, environment and flags...")
+
+	dir, err := ioutil.TempDir("", "cachego")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cachego.json")
+	file := `{"http_addr": ":9000", "resp_addr": ":9001", "users": [{"username": "bob", "password": "pw"}],
+		"limits": {"max_entries": 100, "eviction_policy": "lfu"}, "persistence": {"snapshot_interval": "1m"}}`
+	if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"CACHEGO_CONFIG":      path,
+		"CACHEGO_RESP_ADDR":   ":9002",
+		"CACHEGO_MAX_ENTRIES": "200",
+	}
+	getenv := func(name string) string { return env[name] }
+
+	config, err := LoadConfig("cachego", []string{"-max-entries", "300", "-resp-addr", ""}, getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.HTTPAddr != ":9000" {
+		t.Errorf("Expected :9000 from file, but it was %s instead.", config.HTTPAddr)
+	}
+	if config.RESPAddr != "" {
+		t.Errorf("Expected RESP disabled by flag, but it was %s instead.", config.RESPAddr)
+	}
+	if config.Limits.MaxEntries != 300 {
+		t.Errorf("Expected 300 from flag, but it was %d instead.", config.Limits.MaxEntries)
+	}
+	if config.Limits.EvictionPolicy != "lfu" || config.Persistence.SnapshotInterval.Duration != time.Minute {
+		t.Errorf("Expected lfu and 1m from file, but it was %s and %v instead.",
+			config.Limits.EvictionPolicy, config.Persistence.SnapshotInterval)
+	}
+	if config.Persistence.SnapshotPath != "" {
+		t.Errorf("Expected snapshots disabled by default, but it was %s instead.", config.Persistence.SnapshotPath)
+	}
+	if len(config.Users) != 1 || config.Users[0].Username != "bob" || config.Users[0].Password != "pw" {
+		t.Errorf("Expected bob from file, but it was %v instead.", config.Users)
+	}
+
+	env["CACHEGO_USERS"] = "ann:1,joe:2"
+	config, err = LoadConfig("cachego", nil, getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Users) != 2 || config.Users[1].Username != "joe" || config.Users[1].Password != "2" {
+		t.Errorf("Expected ann and joe from environment, but it was %v instead.", config.Users)
+	}
+
+	// Dumped config loads back as is
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadConfig("cachego", []string{"-config", path}, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := json.Marshal(reloaded); string(again) != string(data) {
+		t.Errorf("Expected %s, but it was %s instead.", data, again)
+	}
+
+	if redacted := config.Redacted(); redacted.Users[0].Password == "1" || config.Users[0].Password != "1" {
+		t.Errorf("Expected passwords hidden in a copy only.")
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	t.Log("Testing invalid configs are rejected...")
+
+	noenv := func(string) string { return "" }
+	cases := [][]string{
+		{"-http-addr", "8027"},
+		{"-users", "alex"},
+		{"-users", "alex:"},
+		{"-users", "alex:1,alex:2"},
+		{"-tls-cert", "cert.pem"},
+		{"-max-entries", "-1"},
+		{"-max-entries", "many"},
+		{"-eviction-policy", "mru"},
+		{"-snapshot-path", "cachego.snapshot", "-snapshot-interval", "0s"},
+		{"-append-log-fsync", "sometimes"},
+		{"-leader-url", "leader:8027", "-leader-username", "bob", "-leader-password", "pw"},
+		{"-leader-url", "http://leader:8027"},
+		{"-cluster-url", "10.0.0.1:8027"},
+		{"-cluster-peers", "http://10.0.0.2:8027"},
+		{"-cluster-url", "http://10.0.0.1:8027", "-cluster-peers", "http://10.0.0.2:8027,"},
+		{"-cluster-url", "http://10.0.0.1:8027", "-leader-url", "http://leader:8027", "-leader-username", "bob", "-leader-password", "pw"},
+		{"-unknown", "1"},
+		{"extra"},
+	}
+
+	for _, args := range cases {
+		if _, err := LoadConfig("cachego", args, noenv); err == nil {
+			t.Errorf("Expected error for %v, but it was nil instead.", args)
+		}
+	}
+
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected valid default config, but it was %v instead.", err)
+	}
+}
+
+func TestReplicationAndClusterConfig(t *testing.T) {
+	t.Log("Testing followers and cluster nodes are set up from config...")
+
+	noenv := func(string) string { return "" }
+	config, err := LoadConfig("cachego", []string{"-leader-url", "http://leader:8027", "-leader-username", "bob", "-leader-password", "pw"}, noenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Redacted().Replication.LeaderPassword == "pw" {
+		t.Errorf("Expected leader password hidden.")
+	}
+	follower := CreateWithConfig(config)
+	if follower.leaderURL != "http://leader:8027" || follower.leaderUsername != "bob" || follower.leaderPassword != "pw" {
+		t.Errorf("Expected follower of http://leader:8027, but it was %s as %s instead.", follower.leaderURL, follower.leaderUsername)
+	}
+
+	config, err = LoadConfig("cachego", []string{"-cluster-url", "http://10.0.0.1:8027", "-cluster-peers", "http://10.0.0.2:8027,http://10.0.0.3:8027"}, noenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Cluster.Peers) != 2 {
+		t.Errorf("Expected 2 peers, but it was %v instead.", config.Cluster.Peers)
+	}
+	node := CreateWithConfig(config)
+	if node.cluster == nil || node.cluster.self != "http://10.0.0.1:8027" {
+		t.Errorf("Expected cluster node http://10.0.0.1:8027, but it was %v instead.", node.cluster)
+	}
+}
+
+func TestConfiguredUsers(t *testing.T) {
+	t.Log("Testing server accepts configured users only...")
+
+	config := DefaultConfig()
+	config.Users = []User{{Username: "bob", Password: "pw"}}
+	server := CreateWithConfig(config)
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	cli := client.CreateWithCredentials(client.Credentials{Username: "bob", Password: "pw"})
+	cli.Url, cli.APIUrl = ts.URL, "/v1"
+	if _, errs := cli.Len(); errs != nil {
+		t.Errorf("Expected bob to be accepted, but it was %v instead.", errs)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/len", nil)
+	req.SetBasicAuth(defaultUsername, defaultPassword)
+	rec := httptest.NewRecorder()
+	server.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d for default user, but it was %d instead.", http.StatusUnauthorized, rec.Code)
+	}
+
+	c, stop := serveRESP(t, &server)
+	defer stop()
+	if reply := c.do(t, "AUTH", defaultPassword); !strings.HasPrefix(reply, "-WRONGPASS") {
+		t.Errorf("Expected -WRONGPASS, but it was %s instead.", reply)
+	}
+	if reply := c.do(t, "AUTH", "pw"); reply != "+OK" {
+		t.Errorf("Expected +OK, but it was %s instead.", reply)
+	}
+}
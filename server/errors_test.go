@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anevsky/cachego/client"
+	"github.com/anevsky/cachego/util"
+)
+
+func TestErrorStatuses(t *testing.T) {
+	t.Log("Testing errors are returned with HTTP statuses matching their codes...")
+
+	server := aclServer(t)
+	server.cache.SetString("user:1", "v")
+	server.cache.SetInt("iii", 1)
+
+	tests := []struct {
+		username, password, method, path, body string
+		status                                 int
+		err                                    util.CacheError
+	}{
+		{"alex", "secret", "GET", "/v1/get/missing", "", http.StatusNotFound, util.ErrorKeyNotFound},
+		{"alex", "secret", "PUT", "/v1/string/iii", `{"value":"s"}`, http.StatusConflict, util.ErrorWrongType},
+		{"alex", "secret", "POST", "/v1/ttl/iii", `{"value":-1}`, http.StatusUnprocessableEntity, util.ErrorInvalidTTLValue},
+		{"alex", "wrong", "GET", "/v1/get/iii", "", http.StatusUnauthorized, util.ErrorUnauthorized},
+		{"reader", "pw2", "GET", "/v1/get/iii", "", http.StatusForbidden, util.ErrorPermissionDenied},
+		{"alex", "secret", "POST", "/v1/users/nobody/enable", "", http.StatusNotFound, util.ErrorUserNotFound},
+		{"alex", "secret", "GET", "/v1/scan?cursor=x", "", http.StatusBadRequest, util.ErrorBadRequest},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(test.username, test.password)
+		rec := httptest.NewRecorder()
+		server.handler().ServeHTTP(rec, req)
+
+		var dto util.BasicDTO
+		json.Unmarshal(rec.Body.Bytes(), &dto)
+		if rec.Code != test.status || dto.ErrorCode != test.err.Code {
+			t.Errorf("Expected %d %v for %s %s, but it was %d %v instead.", test.status, test.err, test.method, test.path, rec.Code, dto)
+		}
+	}
+
+	// Routing errors have a code too
+	req := httptest.NewRequest("GET", "/v2/get/iii", nil)
+	rec := httptest.NewRecorder()
+	server.handler().ServeHTTP(rec, req)
+	var dto util.BasicDTO
+	json.Unmarshal(rec.Body.Bytes(), &dto)
+	if rec.Code != http.StatusNotFound || dto.ErrorCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown route, but it was %d %v instead.", rec.Code, dto)
+	}
+}
+
+func TestDroppedConsumerStatus(t *testing.T) {
+	t.Log("Testing dropped slow consumers are not reported as rate limited...")
+
+	for _, err := range []util.CacheError{util.ErrorReplicaTooSlow, util.ErrorSubscriberTooSlow, util.ErrorWatcherTooSlow} {
+		if status := errorStatus(err); status != http.StatusServiceUnavailable {
+			t.Errorf("Expected %d for %v, but it was %d instead.", http.StatusServiceUnavailable, err, status)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Log("Testing client decodes errors of the server...")
+
+	server := aclServer(t)
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+	server.cache.SetString("sss", "v")
+
+	cli := clusterClient(ts.URL)
+	if _, errs := cli.GetString("missing"); len(errs) != 1 || errs[0] != util.ErrorKeyNotFound {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorKeyNotFound, errs)
+	}
+	if _, errs := cli.Increment("sss"); len(errs) != 1 || errorCode(errs[0]) != util.ErrorWrongType.Code {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorWrongType, errs)
+	}
+
+	stranger := client.CreateWithCredentials(client.Credentials{Username: "alex", Password: "wrong"})
+	stranger.Url, stranger.APIUrl = ts.URL, "/v1"
+	if _, errs := stranger.Len(); len(errs) != 1 || errs[0] != util.ErrorUnauthorized {
+		t.Errorf("Expected %v, but it was %v instead.", util.ErrorUnauthorized, errs)
+	}
+}
+
+func errorCode(err error) int {
+	e, _ := err.(util.CacheError)
+	return e.Code
+}
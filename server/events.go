@@ -0,0 +1,48 @@
+package server
+
+import (
+	"time"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+)
+
+// Stream keyspace events of keys with prefix as Server-Sent Events named by event type
+// A dropped watch ends with an "error" event
+// curl -N --user alex:secret 'localhost:8027/v1/watch?prefix=user:'
+func (server *SERVER) watch(c echo.Context) error {
+	w := server.cache.Watch(c.QueryParam("prefix"))
+	defer w.Close()
+
+	res := startEventStream(c)
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case e, ok := <-w.Events():
+			if !ok {
+				// Only watchers falling behind are dropped
+				e := util.ErrorWatcherTooSlow
+				writeEvent(res, "error", util.BasicDTO{ErrorCode: e.Code, ErrorMessage: e.Error()})
+				res.Flush()
+				return nil
+			}
+			err = writeEvent(res, e.Type, e)
+		case <-ticker.C:
+			_, err = res.Write([]byte(": ping\n\n"))
+		case <-c.Request().Context().Done():
+			return nil
+		}
+
+		if err != nil {
+			return nil
+		}
+
+		// Events ready together are flushed together
+		if len(w.Events()) == 0 {
+			res.Flush()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestWatch(t *testing.T) {
+	t.Log("Testing keyspace events over Server-Sent Events...")
+
+	server := Create()
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	cli := clusterClient(ts.URL)
+	events, stop, errs := cli.Watch("user:")
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	cli.SetDict("user:1", util.Dict{"name": "alex"})
+	cli.SetString("other", "v")
+	cli.Remove("user:1")
+
+	for _, expected := range []util.Event{
+		{Type: util.EventSet, Key: "user:1", ValueType: "dict"},
+		{Type: util.EventRemove, Key: "user:1", ValueType: "dict"},
+	} {
+		select {
+		case e := <-events:
+			if e != expected {
+				t.Errorf("Expected %v, but it was %v instead.", expected, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %v, but got nothing.", expected)
+		}
+	}
+
+	stop()
+	if _, ok := <-events; ok {
+		t.Errorf("Expected closed channel of events.")
+	}
+}
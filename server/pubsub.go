@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+)
+
+// Idle event streams get a comment this often, so proxies keep them open
+const streamPingInterval = 15 * time.Second
+
+// Send message to subscribers of the channel on this node
+// Returns number of deliveries
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":"hello"}' localhost:8027/v1/publish/news
+func (server *SERVER) publish(c echo.Context) error {
+	channel := c.Param("channel")
+
+	value := new(util.StringDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: server.cache.Publish(channel, value.Value)})
+}
+
+// Stream messages of channels and channel patterns as Server-Sent Events
+// Every message is a "message" event with JSON data, a dropped subscription ends with an "error" event
+// curl -N --user alex:secret 'localhost:8027/v1/subscribe?channel=news&pattern=news.*'
+func (server *SERVER) subscribe(c echo.Context) error {
+	query := c.QueryParams()
+	channels, patterns := query["channel"], query["pattern"]
+	if len(channels) == 0 && len(patterns) == 0 {
+		return makeJSONError(c, util.ErrorBadRequest)
+	}
+
+	sub := server.cache.Subscribe(channels...)
+	sub.PSubscribe(patterns...)
+	defer sub.Close()
+
+	res := startEventStream(c)
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case m, ok := <-sub.Messages():
+			if !ok {
+				// Only subscribers falling behind are dropped
+				e := util.ErrorSubscriberTooSlow
+				writeEvent(res, "error", util.BasicDTO{ErrorCode: e.Code, ErrorMessage: e.Error()})
+				res.Flush()
+				return nil
+			}
+			err = writeEvent(res, "message", m)
+		case <-ticker.C:
+			_, err = res.Write([]byte(": ping\n\n"))
+		case <-c.Request().Context().Done():
+			return nil
+		}
+
+		if err != nil {
+			return nil
+		}
+
+		// Messages ready together are flushed together
+		if len(sub.Messages()) == 0 {
+			res.Flush()
+		}
+	}
+}
+
+// Reply with Server-Sent Events stream
+func startEventStream(c echo.Context) *echo.Response {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	return res
+}
+
+// Write Server-Sent Event with JSON data
+func writeEvent(res *echo.Response, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = res.Write([]byte("event: " + event + "\ndata: " + string(b) + "\n\n"))
+	return err
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestPubSub(t *testing.T) {
+	t.Log("Testing pub/sub over Server-Sent Events...")
+
+	server := Create()
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	cli := clusterClient(ts.URL)
+	messages, stop, errs := cli.PSubscribe("news.*")
+	if errs != nil {
+		t.Fatal(errs)
+	}
+
+	for _, payload := range []string{"m1", "m2"} {
+		if n, errs := cli.Publish("news.sport", payload); errs != nil || n != 1 {
+			t.Errorf("Expected 1 delivery, but it was %d (%v) instead.", n, errs)
+		}
+	}
+
+	for _, payload := range []string{"m1", "m2"} {
+		expected := util.Message{Channel: "news.sport", Pattern: "news.*", Payload: payload}
+		select {
+		case m := <-messages:
+			if m != expected {
+				t.Errorf("Expected %v, but it was %v instead.", expected, m)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %v, but got nothing.", expected)
+		}
+	}
+
+	stop()
+	if _, ok := <-messages; ok {
+		t.Errorf("Expected closed channel of messages.")
+	}
+	if !eventually(func() bool { return server.cache.NumSubscribers("news.sport") == 0 }) {
+		t.Errorf("Expected subscription to end with the stream.")
+	}
+
+	// RESP clients publish too
+	sub := server.cache.Subscribe("chat")
+	defer sub.Close()
+	c, stopRESP := serveRESP(t, &server)
+	defer stopRESP()
+	c.do(t, "AUTH", defaultPassword)
+	if reply := c.do(t, "PUBLISH", "chat", "hi"); reply != ":1" {
+		t.Errorf("Expected :1, but it was %s instead.", reply)
+	}
+	if m := <-sub.Messages(); m.Payload != "hi" {
+		t.Errorf("Expected hi, but it was %s instead.", m.Payload)
+	}
+}
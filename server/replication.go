@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+)
+
+const (
+	// Leader pings idle replicas this often
+	replicaPingInterval = time.Second
+	// Follower reconnects when the leader is silent for this long
+	replicaTimeout = 5 * time.Second
+	// Delays between reconnects to the leader
+	replicaMinBackoff = 100 * time.Millisecond
+	replicaMaxBackoff = 10 * time.Second
+)
+
+// Replicate leader at url, e.g. http://leader:8027, writes are rejected afterwards
+// Call before StartUp, the follower resyncs from scratch after every disconnect
+func (server *SERVER) Follow(url, username, password string) {
+	server.leaderURL = strings.TrimSuffix(url, "/")
+	server.leaderUsername = username
+	server.leaderPassword = password
+}
+
+// Reject writes on followers
+func (server *SERVER) writable(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if server.leaderURL != "" {
+			return makeJSONError(c, util.ErrorReadOnlyReplica)
+		}
+		if err := server.authorize(c, "", true, nil); err != nil {
+			return makeJSONError(c, err)
+		}
+
+		return next(c)
+	}
+}
+
+// Stream full copy of the cache followed by all writes as JSON lines
+// curl -N --user alex:secret localhost:8027/v1/replicate
+func (server *SERVER) replicate(c echo.Context) error {
+	feed := server.cache.Feed()
+	defer feed.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(replicaPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				feed.Ping()
+			case <-c.Request().Context().Done():
+				// Wakes up the writer blocked on an idle feed
+				feed.Close()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		line, err := feed.Next()
+		if err != nil {
+			return nil
+		}
+
+		if _, err := res.Write(line); err != nil {
+			return nil
+		}
+
+		// Ops ready together are flushed together
+		if feed.Len() == 0 {
+			res.Flush()
+		}
+	}
+}
+
+// Follow the leader until stop is closed, nil stop means forever
+func (server *SERVER) followLeader(stop <-chan struct{}) {
+	backoff := replicaMinBackoff
+	for {
+		connected, err := server.syncWithLeader(stop)
+		if connected {
+			backoff = replicaMinBackoff
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		log.Printf("replication from %s interrupted: %v", server.leaderURL, err)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > replicaMaxBackoff {
+			backoff = replicaMaxBackoff
+		}
+	}
+}
+
+// Resync with the leader and apply its writes until the connection breaks
+// Returns true if the leader accepted the replica
+func (server *SERVER) syncWithLeader(stop <-chan struct{}) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodGet, server.leaderURL+"/v1/replicate", nil)
+	if err != nil {
+		return false, err
+	}
+	req.SetBasicAuth(server.leaderUsername, server.leaderPassword)
+
+	res, err := server.nodeClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("leader responded with %s", res.Status)
+	}
+
+	// Silent leader is as good as gone, it pings idle replicas
+	watchdog := time.AfterFunc(replicaTimeout, cancel)
+	defer watchdog.Stop()
+
+	return true, server.cache.Follow(&idleReader{res.Body, watchdog})
+}
+
+// Reader resetting the watchdog on every read
+type idleReader struct {
+	io.Reader
+	watchdog *time.Timer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.watchdog.Reset(replicaTimeout)
+	}
+
+	return n, err
+}
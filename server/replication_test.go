@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Poll condition until it holds or time is out
+func eventually(condition func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return true
+}
+
+func TestReplication(t *testing.T) {
+	t.Log("Testing leader/follower replication...")
+
+	leader := Create()
+	leader.cache.SetString("k1", "v1")
+	leader.cache.SAdd("s1", "m1", "m2")
+
+	ts := httptest.NewServer(leader.handler())
+	defer ts.Close()
+
+	follower := Create()
+	follower.cache.SetString("stale", "v")
+	follower.Follow(ts.URL, defaultUsername, defaultPassword)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go follower.followLeader(stop)
+
+	// Full resync
+	if !eventually(func() bool {
+		v, err := follower.cache.Get("k1")
+		return err == nil && v == "v1" && follower.cache.Len() == 2
+	}) {
+		t.Errorf("Expected follower to copy leader keys, but it had %v instead.", follower.cache.Keys())
+	}
+
+	// Stream of writes
+	leader.cache.SetInt("k2", 7)
+	leader.cache.Remove("k1")
+	if !eventually(func() bool {
+		v, err := follower.cache.Get("k2")
+		ok, _ := follower.cache.HasKey("k1")
+		return err == nil && v == 7 && !ok
+	}) {
+		t.Errorf("Expected follower to apply leader writes, but it had %v instead.", follower.cache.Keys())
+	}
+
+	// Network split, writes meanwhile are picked up by the resync
+	ts.CloseClientConnections()
+	leader.cache.SetString("k3", "v3")
+	leader.cache.Remove("s1")
+	if !eventually(func() bool {
+		v, err := follower.cache.Get("k3")
+		ok, _ := follower.cache.HasKey("s1")
+		return err == nil && v == "v3" && !ok
+	}) {
+		t.Errorf("Expected follower to resync after reconnect, but it had %v instead.", follower.cache.Keys())
+	}
+}
+
+func TestFollowerReadOnly(t *testing.T) {
+	t.Log("Testing follower rejects writes...")
+
+	follower := Create()
+	follower.cache.SetString("k1", "v1")
+	follower.Follow("http://127.0.0.1:1", defaultUsername, defaultPassword)
+
+	ts := httptest.NewServer(follower.handler())
+	defer ts.Close()
+
+	request := func(method, path, body string) util.StringDTO {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth(defaultUsername, defaultPassword)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		var dto util.StringDTO
+		json.NewDecoder(res.Body).Decode(&dto)
+		return dto
+	}
+
+	if dto := request(http.MethodPost, "/v1/string/k2", `{"value":"v2"}`); dto.ErrorCode != util.ErrorReadOnlyReplica.Code {
+		t.Errorf("Expected error code %d, but it was %d instead.", util.ErrorReadOnlyReplica.Code, dto.ErrorCode)
+	}
+	if dto := request(http.MethodDelete, "/v1/remove/k1", ""); dto.ErrorCode != util.ErrorReadOnlyReplica.Code {
+		t.Errorf("Expected error code %d, but it was %d instead.", util.ErrorReadOnlyReplica.Code, dto.ErrorCode)
+	}
+	if dto := request(http.MethodGet, "/v1/get/k1", ""); dto.ErrorCode != 0 || dto.Value != "v1" {
+		t.Errorf("Expected v1, but it was %q (%d) instead.", dto.Value, dto.ErrorCode)
+	}
+
+	c, stop := serveRESP(t, &follower)
+	defer stop()
+
+	c.do(t, "AUTH", defaultPassword)
+	if reply := c.do(t, "SET", "k2", "v2"); !strings.HasPrefix(reply, "-READONLY") {
+		t.Errorf("Expected READONLY error, but it was %s instead.", reply)
+	}
+	if reply := c.do(t, "GET", "k1"); reply != "v1" {
+		t.Errorf("Expected v1, but it was %s instead.", reply)
+	}
+}
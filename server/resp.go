@@ -0,0 +1,713 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Max size of a single bulk string or array in a RESP request
+const respMaxLen = 512 * 1024 * 1024
+
+// Limits of requests before AUTH, like those of Redis, so unauthenticated clients can't make the server allocate much
+const (
+	respMaxUnauthArgs = 10
+	respMaxUnauthLen  = 16 * 1024
+)
+
+// Max size of an inline command or of a header line
+const respMaxInlineLen = 64 * 1024
+
+var errRESPProtocol = errors.New("ERR Protocol error")
+
+// RESP command handler, args[0] is the command name
+type respHandler func(server *SERVER, w *respWriter, args []string)
+
+type respCommand struct {
+	// Number of args including the command name, negative means at least -arity
+	arity   int
+	handler respHandler
+	// Writes are rejected by followers
+	write bool
+	// Key args routed in cluster mode and checked against ACL: 0 - none, 1 - args[1], -1 - all args after the name
+	keys int
+	// Route group users need to be allowed to, empty - any user
+	group string
+}
+
+var respCommands map[string]respCommand
+
+func init() {
+	respCommands = map[string]respCommand{
+		"PING":    {-1, respPing, false, 0, ""},
+		"COMMAND": {-1, respCommandInfo, false, 0, ""},
+		"GET":     {2, respGet, false, 1, GroupKeys},
+		"SET":     {-3, respSet, true, 1, GroupKeys},
+		"DEL":     {-2, respDel, true, -1, GroupKeys},
+		"EXISTS":  {-2, respExists, false, -1, GroupKeys},
+		"KEYS":    {2, respKeys, false, 0, GroupKeyspace},
+		"INCR":    {2, respIncr, true, 1, GroupKeys},
+		"EXPIRE":  {3, respExpire, true, 1, GroupKeys},
+		"LINDEX":  {3, respLIndex, false, 1, GroupKeys},
+		"RPUSH":   {-3, respRPush, true, 1, GroupKeys},
+		"LREM":    {4, respLRem, true, 1, GroupKeys},
+		"HGET":    {3, respHGet, false, 1, GroupKeys},
+		"HSET":    {-4, respHSet, true, 1, GroupKeys},
+		"HDEL":    {-3, respHDel, true, 1, GroupKeys},
+		"DBSIZE":  {1, respDBSize, false, 0, GroupKeyspace},
+		"INFO":    {-1, respInfo, false, 0, GroupKeyspace},
+		"PUBLISH": {3, respPublish, false, 0, GroupPubSub},
+		"SCAN":    {-2, respScan, false, 0, GroupKeyspace},
+	}
+}
+
+// Listen for RESP (Redis serialization protocol) clients, e.g. redis-cli
+// Connections are encrypted like HTTP ones when TLS is enabled
+// redis-cli -p 8028 --user alex --pass secret
+func (server *SERVER) ListenRESP(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return server.ServeRESP(server.tlsListener(l))
+}
+
+// Serve RESP clients on listener until it is closed
+func (server *SERVER) ServeRESP(l net.Listener) error {
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go server.serveRESPConn(conn)
+	}
+}
+
+func (server *SERVER) serveRESPConn(conn net.Conn) {
+	defer conn.Close()
+
+	r := &respReader{bufio.NewReader(conn)}
+	w := &respWriter{bufio.NewWriter(conn)}
+	// Empty until AUTH succeeds
+	username := ""
+
+	for {
+		args, err := r.readCommand(username != "")
+		if err != nil {
+			if err == errRESPProtocol {
+				w.writeError(err.Error())
+				w.Flush()
+			}
+			return
+		}
+
+		if len(args) > 0 {
+			name := strings.ToUpper(args[0])
+			switch {
+			case name == "QUIT":
+				w.writeSimple("OK")
+				w.Flush()
+				return
+			case name == "AUTH":
+				if user, ok := server.respAuth(w, args); ok {
+					username = user
+				}
+			case username == "":
+				w.writeError("NOAUTH Authentication required.")
+			default:
+				server.execRESP(w, username, name, args)
+			}
+		}
+
+		// Replies to pipelined commands are flushed together
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (server *SERVER) execRESP(w *respWriter, username, name string, args []string) {
+	command, ok := respCommands[name]
+	if !ok {
+		w.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+
+	if (command.arity > 0 && len(args) != command.arity) || (command.arity < 0 && len(args) < -command.arity) {
+		w.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	if command.write && server.leaderURL != "" {
+		w.writeError("READONLY You can't write against a read only replica.")
+		return
+	}
+
+	if command.group != "" {
+		if err := server.users.authorize(username, command.group, command.write, commandKeys(command, args)); err != nil {
+			w.writeError(fmt.Sprintf("NOPERM this user has no permissions to run the '%s' command or access its keys", strings.ToLower(name)))
+			return
+		}
+	}
+
+	if server.cluster != nil && command.keys != 0 {
+		server.routeRESP(w, command, args)
+		return
+	}
+
+	command.handler(server, w, args)
+}
+
+// Run command here or reply with the node owning its keys, like Redis Cluster
+// Nodes are reported by the host:port of their RESP listeners
+func (server *SERVER) routeRESP(w *respWriter, command respCommand, args []string) {
+	keys := commandKeys(command, args)
+
+	slot, err := keysSlot(keys)
+	if err != nil {
+		w.writeError("CROSSSLOT Keys in request don't hash to the same slot")
+		return
+	}
+
+	cl := server.cluster
+	cl.moving.RLock()
+	defer cl.moving.RUnlock()
+
+	node, asking, err := cl.route(slot, false, func() bool {
+		for _, key := range keys {
+			if ok, _ := server.cache.HasKey(key); !ok {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		w.writeError("CLUSTERDOWN Hash slot not served")
+		return
+	}
+	if node == "" {
+		command.handler(server, w, args)
+		return
+	}
+
+	addr := cl.respAddr(node)
+	switch {
+	case addr == "":
+		w.writeError("CLUSTERDOWN Hash slot served by a node without Redis protocol listener")
+	case asking:
+		w.writeError(fmt.Sprintf("ASK %d %s", slot, addr))
+	default:
+		w.writeError(fmt.Sprintf("MOVED %d %s", slot, addr))
+	}
+}
+
+// Key args of command
+func commandKeys(command respCommand, args []string) []string {
+	switch command.keys {
+	case 0:
+		return nil
+	case 1:
+		return args[1:2]
+	default:
+		return args[1:]
+	}
+}
+
+// AUTH [username] password, the first configured user when username is omitted
+// AUTH token authenticates by API token
+// Returns the authenticated username
+func (server *SERVER) respAuth(w *respWriter, args []string) (string, bool) {
+	var username, password string
+	switch len(args) {
+	case 2:
+		// API token or password of the first user
+		if user, ok := server.tokens.check(args[1]); ok && server.users.enabled(user) {
+			w.writeSimple("OK")
+			return user, true
+		}
+		username, _ = server.nodeCredentials()
+		password = args[1]
+	case 3:
+		username, password = args[1], args[2]
+	default:
+		w.writeError("ERR wrong number of arguments for 'auth' command")
+		return "", false
+	}
+
+	if !server.checkCredentials(username, password) {
+		w.writeError("WRONGPASS invalid username-password pair")
+		return "", false
+	}
+
+	w.writeSimple("OK")
+	return username, true
+}
+
+///////////////////////////////////////
+// Protocol
+///////////////////////////////////////
+
+type respReader struct {
+	*bufio.Reader
+}
+
+// Read command as array of bulk strings or as inline command
+// Declared lengths are trusted only as far as the data actually sent, and are capped until the client authenticates
+func (r *respReader) readCommand(authenticated bool) ([]string, error) {
+	maxArgs, maxLen := respMaxLen, respMaxLen
+	if !authenticated {
+		maxArgs, maxLen = respMaxUnauthArgs, respMaxUnauthLen
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	n, err := parseRESPLen(line[1:], maxArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{}
+	for i := 0; i < n; i++ {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errRESPProtocol
+		}
+
+		size, err := parseRESPLen(line[1:], maxLen)
+		if err != nil {
+			return nil, err
+		}
+
+		// Buffer grows with the data read
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, r, int64(size)+2); err != nil {
+			return nil, err
+		}
+		data := buf.Bytes()
+		if data[size] != '\r' || data[size+1] != '\n' {
+			return nil, errRESPProtocol
+		}
+
+		args = append(args, string(data[:size]))
+	}
+
+	return args, nil
+}
+
+// Read line of at most respMaxInlineLen bytes
+func (r *respReader) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > respMaxInlineLen {
+			return "", errRESPProtocol
+		}
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return "", err
+		}
+	}
+
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func parseRESPLen(s string, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > max {
+		return 0, errRESPProtocol
+	}
+
+	return n, nil
+}
+
+type respWriter struct {
+	*bufio.Writer
+}
+
+func (w *respWriter) writeSimple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func (w *respWriter) writeError(s string) {
+	w.WriteString("-" + s + "\r\n")
+}
+
+func (w *respWriter) writeInt(n int) {
+	w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func (w *respWriter) writeBulk(s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func (w *respWriter) writeNull() {
+	w.WriteString("$-1\r\n")
+}
+
+func (w *respWriter) writeArray(values []string) {
+	w.WriteString("*" + strconv.Itoa(len(values)) + "\r\n")
+	for _, v := range values {
+		w.writeBulk(v)
+	}
+}
+
+// Write cache error in Redis error format
+func (w *respWriter) writeCacheError(err error) {
+	switch err {
+	case util.ErrorWrongType:
+		w.writeError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	default:
+		w.writeError("ERR " + err.Error())
+	}
+}
+
+///////////////////////////////////////
+// Commands
+///////////////////////////////////////
+
+// PING [message]
+func respPing(server *SERVER, w *respWriter, args []string) {
+	if len(args) > 1 {
+		w.writeBulk(args[1])
+	} else {
+		w.writeSimple("PONG")
+	}
+}
+
+// COMMAND is sent by redis-cli on connect, no command docs are provided
+func respCommandInfo(server *SERVER, w *respWriter, args []string) {
+	w.writeArray(nil)
+}
+
+// GET key
+// Ints are returned as strings like in Redis
+func respGet(server *SERVER, w *respWriter, args []string) {
+	value, err := server.cache.Get(args[1])
+	if err == util.ErrorKeyNotFound {
+		w.writeNull()
+		return
+	}
+	if err != nil {
+		w.writeCacheError(err)
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		w.writeBulk(v)
+	case int:
+		w.writeBulk(strconv.Itoa(v))
+	default:
+		w.writeCacheError(util.ErrorWrongType)
+	}
+}
+
+// SET key value [EX seconds | PX milliseconds]
+// Values in canonical integer form are stored as ints, so INCR works on them
+func respSet(server *SERVER, w *respWriter, args []string) {
+	ttl := 0
+	for i := 3; i < len(args); i += 2 {
+		option := strings.ToUpper(args[i])
+		if (option != "EX" && option != "PX") || i+1 >= len(args) {
+			w.writeError("ERR syntax error")
+			return
+		}
+
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil || n <= 0 {
+			w.writeError("ERR invalid expire time in 'set' command")
+			return
+		}
+
+		ttl = n
+		if option == "EX" {
+			ttl = n * 1000
+		}
+	}
+
+	key, value := args[1], args[2]
+
+	var err error
+	if n, convErr := strconv.Atoi(value); convErr == nil && strconv.Itoa(n) == value {
+		err = server.cache.SetInt(key, n, ttl)
+	} else {
+		err = server.cache.SetString(key, value, ttl)
+	}
+	if err != nil {
+		w.writeCacheError(err)
+		return
+	}
+
+	w.writeSimple("OK")
+}
+
+// DEL key [key ...]
+func respDel(server *SERVER, w *respWriter, args []string) {
+	w.writeInt(server.cache.RemoveKeys(args[1:]...))
+}
+
+// EXISTS key [key ...]
+func respExists(server *SERVER, w *respWriter, args []string) {
+	found := 0
+	for _, key := range args[1:] {
+		if ok, _ := server.cache.HasKey(key); ok {
+			found++
+		}
+	}
+
+	w.writeInt(found)
+}
+
+// KEYS pattern
+func respKeys(server *SERVER, w *respWriter, args []string) {
+	keys := []string{}
+	for _, key := range server.cache.Keys() {
+		if util.MatchGlob(args[1], key) {
+			keys = append(keys, key)
+		}
+	}
+
+	w.writeArray(keys)
+}
+
+// INCR key
+// Missing key is created with 1, like in Redis
+func respIncr(server *SERVER, w *respWriter, args []string) {
+	v, err := server.cache.IncrementOrCreate(args[1])
+	if err != nil {
+		w.writeCacheError(err)
+		return
+	}
+
+	w.writeInt(v)
+}
+
+// EXPIRE key seconds
+// Non-positive seconds remove the key
+func respExpire(server *SERVER, w *respWriter, args []string) {
+	seconds, err := strconv.Atoi(args[2])
+	if err != nil {
+		w.writeError("ERR value is not an integer or out of range")
+		return
+	}
+
+	if seconds <= 0 {
+		respDel(server, w, args[:2])
+		return
+	}
+
+	if err := server.cache.SetTTL(args[1], seconds*1000); err != nil {
+		w.writeInt(0)
+		return
+	}
+
+	w.writeInt(1)
+}
+
+// LINDEX key index
+// Negative index counts from the end
+func respLIndex(server *SERVER, w *respWriter, args []string) {
+	index, err := strconv.Atoi(args[2])
+	if err != nil {
+		w.writeError("ERR value is not an integer or out of range")
+		return
+	}
+
+	element, err := server.cache.ListIndex(args[1], index)
+	switch err {
+	case nil:
+		w.writeBulk(element)
+	case util.ErrorKeyNotFound, util.ErrorIndexOutOfBounds:
+		w.writeNull()
+	default:
+		w.writeCacheError(err)
+	}
+}
+
+// RPUSH key element [element ...]
+// Missing key is created, returns the new length of the list
+func respRPush(server *SERVER, w *respWriter, args []string) {
+	n, err := server.cache.PushToList(args[1], args[2:]...)
+	if err != nil {
+		w.writeCacheError(err)
+		return
+	}
+
+	w.writeInt(n)
+}
+
+// LREM key count element
+// Occurrences are removed from the head, from the tail for negative count, count 0 removes all of them
+func respLRem(server *SERVER, w *respWriter, args []string) {
+	count, err := strconv.Atoi(args[2])
+	if err != nil {
+		w.writeError("ERR value is not an integer or out of range")
+		return
+	}
+
+	removed, err := server.cache.RemoveCountFromList(args[1], args[3], count)
+	if err != nil && err != util.ErrorKeyNotFound {
+		w.writeCacheError(err)
+		return
+	}
+
+	w.writeInt(removed)
+}
+
+// HGET key field
+func respHGet(server *SERVER, w *respWriter, args []string) {
+	element, err := server.cache.GetDictElement(args[1], args[2])
+	switch err {
+	case nil:
+		w.writeBulk(element)
+	case util.ErrorKeyNotFound, util.ErrorDictKeyNotFound:
+		w.writeNull()
+	default:
+		w.writeCacheError(err)
+	}
+}
+
+// HSET key field value [field value ...]
+// Returns number of added fields
+func respHSet(server *SERVER, w *respWriter, args []string) {
+	if len(args)%2 != 0 {
+		w.writeError("ERR wrong number of arguments for 'hset' command")
+		return
+	}
+
+	// Later values of a repeated field win
+	elements := util.Dict{}
+	for i := 2; i < len(args); i += 2 {
+		elements[args[i]] = args[i+1]
+	}
+
+	added, err := server.cache.SetDictElements(args[1], elements)
+	if err != nil {
+		w.writeCacheError(err)
+		return
+	}
+
+	w.writeInt(added)
+}
+
+// HDEL key field [field ...]
+// Returns number of removed fields
+func respHDel(server *SERVER, w *respWriter, args []string) {
+	removed, err := server.cache.RemoveDictElements(args[1], args[2:]...)
+	if err != nil && err != util.ErrorKeyNotFound {
+		w.writeCacheError(err)
+		return
+	}
+
+	w.writeInt(removed)
+}
+
+// DBSIZE
+func respDBSize(server *SERVER, w *respWriter, args []string) {
+	w.writeInt(server.cache.Len())
+}
+
+// INFO [section]
+// Cache stats in Redis INFO format, the section is ignored
+func respInfo(server *SERVER, w *respWriter, args []string) {
+	stats := server.cache.Stats()
+
+	types := make([]string, 0, len(stats.KeysByType))
+	for name, n := range stats.KeysByType {
+		types = append(types, fmt.Sprintf("%s=%d", name, n))
+	}
+	sort.Strings(types)
+	keyspace := append([]string{"keys=" + strconv.Itoa(stats.Keys)}, types...)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Memory\r\n")
+	fmt.Fprintf(&b, "used_memory:%d\r\n", stats.MemoryAlloc)
+	fmt.Fprintf(&b, "used_memory_dataset:%d\r\n", stats.UsedBytes)
+	fmt.Fprintf(&b, "eviction_policy:%s\r\n", stats.EvictionPolicy)
+	fmt.Fprintf(&b, "\r\n# Stats\r\n")
+	fmt.Fprintf(&b, "keyspace_hits:%d\r\n", stats.Hits)
+	fmt.Fprintf(&b, "keyspace_misses:%d\r\n", stats.Misses)
+	fmt.Fprintf(&b, "expired_keys:%d\r\n", stats.Expirations)
+	fmt.Fprintf(&b, "evicted_keys:%d\r\n", stats.Evictions)
+	fmt.Fprintf(&b, "total_gets:%d\r\n", stats.Gets)
+	fmt.Fprintf(&b, "total_sets:%d\r\n", stats.Sets)
+	fmt.Fprintf(&b, "total_deletes:%d\r\n", stats.Deletes)
+	fmt.Fprintf(&b, "wrong_type:%d\r\n", stats.WrongType)
+	fmt.Fprintf(&b, "\r\n# Keyspace\r\n")
+	fmt.Fprintf(&b, "db0:%s\r\n", strings.Join(keyspace, ","))
+
+	w.writeBulk(b.String())
+}
+
+// PUBLISH channel message
+func respPublish(server *SERVER, w *respWriter, args []string) {
+	w.writeInt(server.cache.Publish(args[1], args[2]))
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func respScan(server *SERVER, w *respWriter, args []string) {
+	cursor, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		w.writeError("ERR invalid cursor")
+		return
+	}
+
+	var options util.ScanOptions
+	for i := 2; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			w.writeError("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			options.Match = args[i+1]
+		case "COUNT":
+			if options.Count, err = strconv.Atoi(args[i+1]); err != nil || options.Count < 1 {
+				w.writeError("ERR syntax error")
+				return
+			}
+		case "TYPE":
+			options.Type = strings.ToLower(args[i+1])
+		default:
+			w.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	keys, next, err := server.cache.Scan(cursor, options)
+	if err != nil {
+		w.writeCacheError(err)
+		return
+	}
+
+	w.WriteString("*2\r\n")
+	w.writeBulk(strconv.FormatUint(next, 10))
+	w.writeArray(keys)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type respTestClient struct {
+	conn net.Conn
+	r    *respReader
+}
+
+func startRESP(t *testing.T) (*respTestClient, func()) {
+	server := Create()
+
+	return serveRESP(t, &server)
+}
+
+func serveRESP(t *testing.T, server *SERVER) (*respTestClient, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeRESP(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &respTestClient{conn, &respReader{bufio.NewReader(conn)}}, func() {
+		conn.Close()
+		l.Close()
+	}
+}
+
+// Send command as array of bulk strings and read reply in a printable form
+func (c *respTestClient) do(t *testing.T, args ...string) string {
+	w := &respWriter{bufio.NewWriter(c.conn)}
+	w.writeArray(args)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c.readReply(t)
+}
+
+func (c *respTestClient) readReply(t *testing.T) string {
+	line, err := c.r.readLine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch line[0] {
+	case '$':
+		size, _ := strconv.Atoi(line[1:])
+		if size < 0 {
+			return "(nil)"
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			t.Fatal(err)
+		}
+		return string(data[:size])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		elements := make([]string, n)
+		for i := range elements {
+			elements[i] = c.readReply(t)
+		}
+		return "[" + strings.Join(elements, " ") + "]"
+	default:
+		return line
+	}
+}
+
+func TestRESPAuth(t *testing.T) {
+	t.Log("Testing RESP authentication...")
+
+	c, stop := startRESP(t)
+	defer stop()
+
+	if reply := c.do(t, "GET", "k"); !strings.HasPrefix(reply, "-NOAUTH") {
+		t.Errorf("Expected NOAUTH, but it was %s instead.", reply)
+	}
+
+	if reply := c.do(t, "AUTH", "alex", "wrong"); !strings.HasPrefix(reply, "-WRONGPASS") {
+		t.Errorf("Expected WRONGPASS, but it was %s instead.", reply)
+	}
+
+	if reply := c.do(t, "AUTH", "secret"); reply != "+OK" {
+		t.Errorf("Expected +OK, but it was %s instead.", reply)
+	}
+
+	if reply := c.do(t, "PING"); reply != "+PONG" {
+		t.Errorf("Expected +PONG, but it was %s instead.", reply)
+	}
+}
+
+func TestRESPCommands(t *testing.T) {
+	t.Log("Testing RESP commands...")
+
+	c, stop := startRESP(t)
+	defer stop()
+
+	c.do(t, "AUTH", "alex", "secret")
+
+	cases := [][]string{
+		{"SET", "s", "hello", "+OK"},
+		{"GET", "s", "hello"},
+		{"GET", "missing", "(nil)"},
+		{"SET", "n", "41", "+OK"},
+		{"INCR", "n", ":42"},
+		{"GET", "n", "42"},
+		{"INCR", "counter", ":1"},
+		{"INCR", "s", "-WRONGTYPE Operation against a key holding the wrong kind of value"},
+		{"RPUSH", "l", "a", "b", "a", "c", ":4"},
+		{"LINDEX", "l", "1", "b"},
+		{"LINDEX", "l", "-1", "c"},
+		{"LINDEX", "l", "10", "(nil)"},
+		{"LREM", "l", "0", "a", ":2"},
+		{"LINDEX", "l", "0", "b"},
+		{"HSET", "h", "f1", "v1", "f2", "v2", ":2"},
+		{"HSET", "h", "f1", "v3", ":0"},
+		{"HGET", "h", "f1", "v3"},
+		{"HDEL", "h", "f1", "f9", ":1"},
+		{"HGET", "h", "f1", "(nil)"},
+		{"EXISTS", "s", "h", "missing", ":2"},
+		{"KEYS", "[hx]", "[h]"},
+		{"EXPIRE", "s", "100", ":1"},
+		{"EXPIRE", "missing", "100", ":0"},
+		{"DEL", "s", "missing", ":1"},
+		{"DBSIZE", ":4"},
+		{"GET", "h", "-WRONGTYPE Operation against a key holding the wrong kind of value"},
+		{"GET", "-ERR wrong number of arguments for 'get' command"},
+		{"FLUSHALL", "-ERR unknown command 'FLUSHALL'"},
+	}
+
+	for _, command := range cases {
+		args, expected := command[:len(command)-1], command[len(command)-1]
+		if reply := c.do(t, args...); reply != expected {
+			t.Errorf("Expected %s for %v, but it was %s instead.", expected, args, reply)
+		}
+	}
+
+	if reply := c.do(t, "INFO"); !strings.Contains(reply, "db0:keys=4,") {
+		t.Errorf("Expected keyspace info, but it was %s instead.", reply)
+	}
+}
+
+func TestRESPInline(t *testing.T) {
+	t.Log("Testing RESP inline commands and pipelining...")
+
+	c, stop := startRESP(t)
+	defer stop()
+
+	io.WriteString(c.conn, "AUTH alex secret\r\nSET k 1\r\nINCR k\r\n")
+
+	for _, expected := range []string{"+OK", "+OK", ":2"} {
+		if reply := c.readReply(t); reply != expected {
+			t.Errorf("Expected %s, but it was %s instead.", expected, reply)
+		}
+	}
+}
+
+func TestRESPLimits(t *testing.T) {
+	t.Log("Testing RESP requests are limited before AUTH...")
+
+	for _, request := range []string{"*536870912\r\n", "*1\r\n$536870912\r\n", "*11\r\n"} {
+		c, stop := startRESP(t)
+		io.WriteString(c.conn, request)
+		if reply := c.readReply(t); reply != "-ERR Protocol error" {
+			t.Errorf("Expected protocol error for %q, but it was %s instead.", request, reply)
+		}
+		stop()
+	}
+
+	c, stop := startRESP(t)
+	defer stop()
+
+	c.do(t, "AUTH", "alex", "secret")
+	value := strings.Repeat("v", 100*1024)
+	if reply := c.do(t, "SET", "k", value); reply != "+OK" {
+		t.Errorf("Expected +OK, but it was %s instead.", reply)
+	}
+	if reply := c.do(t, "GET", "k"); reply != value {
+		t.Errorf("Expected value of %d bytes, but it was %d bytes instead.", len(value), len(reply))
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestScan(t *testing.T) {
+	t.Log("Testing scan over HTTP and RESP...")
+
+	server := Create()
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	for i := 0; i < 50; i++ {
+		server.cache.SetString("user:"+strconv.Itoa(i), "v")
+	}
+	server.cache.SetInt("counter", 1)
+
+	cli := clusterClient(ts.URL)
+	seen := map[string]bool{}
+	it := cli.Scan(util.ScanOptions{Match: "user:*", Count: 7})
+	for it.Next() {
+		if seen[it.Key()] {
+			t.Errorf("Expected %s once.", it.Key())
+		}
+		seen[it.Key()] = true
+	}
+	if errs := it.Errs(); errs != nil {
+		t.Fatal(errs)
+	}
+	if len(seen) != 50 {
+		t.Errorf("Expected 50 keys, but it was %d instead.", len(seen))
+	}
+
+	it = cli.Scan(util.ScanOptions{Type: "blob"})
+	if it.Next() || it.Errs() == nil {
+		t.Errorf("Expected %v.", util.ErrorUnknownType)
+	}
+
+	c, stop := serveRESP(t, &server)
+	defer stop()
+	c.do(t, "AUTH", defaultPassword)
+
+	if reply := c.do(t, "SCAN", "0", "TYPE", "int", "COUNT", "1000"); reply != "[0 [counter]]" {
+		t.Errorf("Expected [0 [counter]], but it was %s instead.", reply)
+	}
+	if reply := c.do(t, "SCAN", "0", "MATCH"); !strings.HasPrefix(reply, "-ERR") {
+		t.Errorf("Expected error, but it was %s instead.", reply)
+	}
+}
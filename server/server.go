@@ -1,7 +1,12 @@
 package server
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/anevsky/cachego/memory"
 	"github.com/anevsky/cachego/util"
@@ -12,26 +17,149 @@ import (
 
 // Server with cache
 type SERVER struct {
-	cache memory.CACHE
+	cache  memory.CACHE
+	config Config
+	users  *users
+	tokens *tokens
+	certs  *certificates
+	// Append-only log replaces snapshots as the source of data on start when set
+	appendLogPath  string
+	appendLogFsync memory.Fsync
+	// Leader replicated by this server, empty for a leader
+	leaderURL      string
+	leaderUsername string
+	leaderPassword string
+	// Cluster membership, nil when not clustered
+	cluster *cluster
 }
 
-// Allocate server instance
+// Allocate server instance with default config
 func Create() SERVER {
+	return CreateWithConfig(DefaultConfig())
+}
+
+// Allocate server instance, the config is expected to be valid
+func CreateWithConfig(config Config) SERVER {
 	server := SERVER{
-		cache: memory.Alloc(),
+		cache:  memory.AllocWithConfig(config.Limits.cacheConfig()),
+		config: config,
+		users:  newUsers(config.Users),
+		tokens: newTokens(),
+		certs:  &certificates{},
+	}
+
+	if config.Persistence.AppendLogPath != "" {
+		server.UseAppendLog(config.Persistence.AppendLogPath, fsyncPolicies[config.Persistence.AppendLogFsync])
+	}
+	if config.Replication.LeaderURL != "" {
+		server.Follow(config.Replication.LeaderURL, config.Replication.LeaderUsername, config.Replication.LeaderPassword)
+	}
+	if config.Cluster.URL != "" {
+		server.EnableCluster(config.Cluster.URL)
 	}
 
 	return server
 }
 
+// Record every write to append-only log at path, the log is replayed on start up
+func (server *SERVER) UseAppendLog(path string, fsync memory.Fsync) {
+	server.appendLogPath = path
+	server.appendLogFsync = fsync
+}
+
 // Setup and start a server
 func (server *SERVER) StartUp() {
 	// Setup
-	e := echo.New()
-	e.Server.Addr = ":8027"
+	e := server.handler()
+	if err := server.config.Validate(); err != nil {
+		e.Logger.Fatal(err)
+	}
+	e.Server.Addr = server.config.HTTPAddr
+
+	// HTTPS when a certificate is configured, renewed certificates are picked up on SIGHUP
+	if err := server.LoadCertificates(); err != nil {
+		e.Logger.Fatal(err)
+	}
+	if config := server.serverTLSConfig(); config != nil {
+		e.Server.TLSConfig = config
+		server.reloadCertificatesOnSignal()
+	}
+
+	snapshotPath := server.config.Persistence.SnapshotPath
+
+	if server.leaderURL != "" {
+		// Followers get their data from the leader
+		go server.followLeader(nil)
+	} else if server.appendLogPath != "" {
+		// Restore cache from the append-only log or the last snapshot
+		if err := server.cache.OpenAppendLog(server.appendLogPath, server.appendLogFsync); err != nil {
+			e.Logger.Fatal(err)
+		}
+	} else if snapshotPath != "" {
+		if err := server.cache.LoadFile(snapshotPath); err != nil && !os.IsNotExist(err) {
+			e.Logger.Fatal(err)
+		}
+	}
+
+	// Cluster members gossip in the background
+	if server.cluster != nil {
+		go server.gossip(nil)
+
+		// Configured peers are met once, the rest is learned from gossip
+		for _, peer := range server.config.Cluster.Peers {
+			go func(peer string) {
+				if err := server.Meet(peer); err != nil {
+					e.Logger.Errorf("meeting %s: %v", peer, err)
+				}
+			}(peer)
+		}
+	}
 
 	// Middleware
 	e.Use(middleware.Logger())
+
+	// Redis protocol next to HTTP
+	if server.config.RESPAddr != "" {
+		go func() {
+			e.Logger.Fatal(server.ListenRESP(server.config.RESPAddr))
+		}()
+	}
+
+	// Snapshot on schedule
+	if snapshotPath != "" {
+		go func() {
+			for range time.Tick(server.config.Persistence.SnapshotInterval.Duration) {
+				if err := server.cache.SaveFile(snapshotPath); err != nil {
+					e.Logger.Error(err)
+				}
+			}
+		}()
+	}
+
+	// Serve it like a boss
+	err := gracehttp.Serve(e.Server)
+
+	// Snapshot on graceful shutdown
+	if snapshotPath != "" {
+		if err := server.cache.SaveFile(snapshotPath); err != nil {
+			e.Logger.Error(err)
+		}
+	}
+	if err := server.cache.CloseAppendLog(); err != nil {
+		e.Logger.Error(err)
+	}
+
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+}
+
+// HTTP routes of the server
+func (server *SERVER) handler() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = httpErrorHandler
+
+	// Middleware
 	e.Use(middleware.Recover())
 
 	// Route => handler
@@ -39,58 +167,163 @@ func (server *SERVER) StartUp() {
 		return c.String(http.StatusOK, "Hello, Network!\n")
 	})
 
-	// Group level middleware
-	api := e.Group("/v1", middleware.BasicAuth(
-		func(username, password string, c echo.Context) bool {
-			if username == "alex" && password == "secret" {
-				return true
-			}
-			return false
-		}))
+	// Group level middleware, users are checked against their ACL,
+	// keys owned by other cluster nodes are redirected to them
+	v1 := e.Group("/v1", server.authenticate)
+	api := v1.Group("", server.allow(GroupKeys), server.routed)
+	keyspace := v1.Group("", server.allow(GroupKeyspace))
+	pubsub := v1.Group("", server.allow(GroupPubSub))
+	admin := v1.Group("", server.allow(GroupAdmin))
+
+	// Mutators are rejected by followers and read-only users
+	write := server.writable
 
 	// core
-	api.GET("/len", server.len)
-	api.GET("/keys", server.keys)
-	api.GET("/stats", server.stats)
+	keyspace.GET("/len", server.len)
+	keyspace.GET("/keys", server.keys)
+	keyspace.GET("/scan", server.scan)
+	keyspace.GET("/stats", server.stats)
+	keyspace.GET("/watch", server.watch)
+	admin.POST("/save", server.save)
+	admin.POST("/rewrite", server.rewrite)
+	admin.GET("/replicate", server.replicate)
+	// users
+	admin.GET("/users", server.listUsers)
+	admin.POST("/users/:username/enable", server.enableUser)
+	admin.POST("/users/:username/disable", server.disableUser)
+	// API tokens
+	admin.POST("/tokens", server.createToken)
+	admin.GET("/tokens", server.listTokens)
+	admin.POST("/tokens/:id/expire", server.expireToken)
+	admin.DELETE("/tokens/:id", server.revokeToken)
+	// transactions
+	api.POST("/batch", server.batch, write)
+	// pub/sub
+	pubsub.POST("/publish/:channel", server.publish)
+	pubsub.GET("/subscribe", server.subscribe)
+	// cluster
+	admin.GET("/cluster/nodes", server.clusterNodes)
+	admin.POST("/cluster/gossip", server.clusterGossip)
+	admin.POST("/cluster/meet", server.clusterMeet)
+	admin.POST("/cluster/slots", server.clusterSlots)
+	admin.POST("/cluster/migrate", server.clusterMigrate, write)
+	admin.POST("/cluster/import", server.clusterImport, write)
+	admin.POST("/cluster/restore", server.clusterRestore, write)
+	admin.POST("/cluster/claim", server.clusterClaim, write)
 	// accessors - read
 	api.GET("/get/:key", server.get)
 	api.GET("/key/:key", server.hasKey)
+	api.GET("/ttl/:key", server.getTTL)
 	api.POST("/list/element/:key", server.getListElement)
 	api.POST("/dict/element/:key", server.getDictElement)
+	api.POST("/set/element/:key", server.sIsMember)
+	api.GET("/set/:key", server.sMembers)
+	api.POST("/sets/inter", server.sInter)
+	api.POST("/sets/union", server.sUnion)
+	api.POST("/sets/diff", server.sDiff)
+	api.POST("/zset/element/:key", server.zScore)
+	api.POST("/zset/rank/:key", server.zRank)
+	api.POST("/zset/range/:key", server.zRange)
+	api.POST("/zset/score/:key", server.zRangeByScore)
 	// mutators - create
-	api.POST("/string/:key", server.setString)
-	api.POST("/int/:key", server.setInt)
-	api.POST("/list/:key", server.setList)
-	api.POST("/dict/:key", server.setDict)
-	api.POST("/ttl/:key", server.setTTL)
+	api.POST("/string/:key", server.setString, write)
+	api.POST("/int/:key", server.setInt, write)
+	api.POST("/list/:key", server.setList, write)
+	api.POST("/dict/:key", server.setDict, write)
+	api.POST("/ttl/:key", server.setTTL, write)
+	api.POST("/expireat/:key", server.expireAt, write)
 	// mutators - update
-	api.PUT("/string/:key", server.updateString)
-	api.PUT("/int/:key", server.updateInt)
-	api.PUT("/list/:key", server.updateList)
-	api.PUT("/dict/:key", server.updateDict)
-	api.PUT("/list/element/:key", server.appendToList)
-	api.PUT("/int/increment/:key", server.increment)
+	api.PUT("/string/:key", server.updateString, write)
+	api.PUT("/int/:key", server.updateInt, write)
+	api.PUT("/list/:key", server.updateList, write)
+	api.PUT("/dict/:key", server.updateDict, write)
+	api.PUT("/list/element/:key", server.appendToList, write)
+	api.PUT("/int/increment/:key", server.increment, write)
+	api.PUT("/set/element/:key", server.sAdd, write)
+	api.PUT("/zset/element/:key", server.zAdd, write)
+	api.PUT("/zset/increment/:key", server.zIncrBy, write)
 	// mutators - delete
-	api.DELETE("/remove/:key", server.remove)
-	api.DELETE("/list/element/:key", server.removeFromList)
-	api.DELETE("/dict/element/:key", server.removeFromDict)
+	api.DELETE("/remove/:key", server.remove, write)
+	api.DELETE("/list/element/:key", server.removeFromList, write)
+	api.DELETE("/dict/element/:key", server.removeFromDict, write)
+	api.DELETE("/set/element/:key", server.sRem, write)
+	api.DELETE("/zset/element/:key", server.zRem, write)
+	api.DELETE("/zset/score/:key", server.zRemRangeByScore, write)
+	api.DELETE("/ttl/:key", server.persist, write)
+
+	return e
+}
 
-	// Serve it like a boss
-	e.Logger.Fatal(gracehttp.Serve(e.Server))
+// Credentials cluster nodes use to talk to each other, those of the first user
+func (server *SERVER) nodeCredentials() (username, password string) {
+	if len(server.config.Users) == 0 {
+		return "", ""
+	}
+
+	return server.config.Users[0].Username, server.config.Users[0].Password
+}
+
+// HTTP statuses of errors with codes which are not statuses themselves
+var errorStatuses = map[int]int{
+	util.ErrorWrongType.Code:         http.StatusConflict,
+	util.ErrorIndexOutOfBounds.Code:  http.StatusNotFound,
+	util.ErrorInvalidTTLValue.Code:   http.StatusUnprocessableEntity,
+	util.ErrorAppendLogClosed.Code:   http.StatusServiceUnavailable,
+	util.ErrorRewriteInProgress.Code: http.StatusConflict,
+	util.ErrorCrossSlot.Code:         http.StatusBadRequest,
+	util.ErrorSlotNotOwned.Code:      http.StatusMisdirectedRequest,
+	util.ErrorClusterDisabled.Code:   http.StatusConflict,
+	util.ErrorInvalidDump.Code:       http.StatusUnprocessableEntity,
+	util.ErrorUnknownBatchOp.Code:    http.StatusUnprocessableEntity,
+	util.ErrorUnknownType.Code:       http.StatusUnprocessableEntity,
+	util.ErrorUserNotFound.Code:      http.StatusNotFound,
+	util.ErrorTokenNotFound.Code:     http.StatusNotFound,
+	util.ErrorInvalidScore.Code:      http.StatusUnprocessableEntity,
+	// Consumers dropped for not keeping up, not rate limited: 429 is kept for a rate limiter
+	util.ErrorReplicaTooSlow.Code:    http.StatusServiceUnavailable,
+	util.ErrorSubscriberTooSlow.Code: http.StatusServiceUnavailable,
+	util.ErrorWatcherTooSlow.Code:    http.StatusServiceUnavailable,
+}
+
+// HTTP status of error, codes from 300 to 599 are statuses already
+// Codes without a status are internal errors
+func errorStatus(err util.CacheError) int {
+	if status, ok := errorStatuses[err.Code]; ok {
+		return status
+	}
+	if err.Code >= 300 && err.Code < 600 {
+		return err.Code
+	}
+
+	return http.StatusInternalServerError
 }
 
-// Tramsform error object to JSON response
+// Tramsform error object to JSON response, error_code of the body is the code of the error
 func makeJSONError(c echo.Context, err error) error {
-	var errorCode int
+	var cacheError util.CacheError
 	switch err := err.(type) {
 	case util.CacheError:
-		errorCode = err.Code
+		cacheError = err
+	case *echo.HTTPError:
+		// Binding, routing and middleware errors
+		cacheError = util.CacheError{What: fmt.Sprint(err.Message), Code: err.Code}
 	default:
-		errorCode = util.ErrorBadRequest.Code
+		cacheError = util.CacheError{What: err.Error(), Code: util.ErrorInternal.Code}
 	}
 
-	return c.JSON(http.StatusBadRequest,
-		util.BasicDTO{ErrorCode: errorCode, ErrorMessage: err.Error()})
+	return c.JSON(errorStatus(cacheError),
+		util.BasicDTO{ErrorCode: cacheError.Code, ErrorMessage: cacheError.Error()})
+}
+
+// Report errors of routing and middleware the way handlers do
+func httpErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	if err := makeJSONError(c, err); err != nil {
+		c.Logger().Error(err)
+	}
 }
 
 ///////////////////////////////////////
@@ -109,23 +342,77 @@ func (server *SERVER) keys(c echo.Context) error {
 	return c.JSON(http.StatusOK, util.KeysDTO{Keys: server.cache.Keys()})
 }
 
+// Get page of keys, start with cursor 0 and pass the returned cursor until it is 0
+// curl -i -w "\n" --user alex:secret 'localhost:8027/v1/scan?cursor=0&match=user:*&count=100&type=dict'
+func (server *SERVER) scan(c echo.Context) error {
+	var cursor uint64
+	var options util.ScanOptions
+	var err error
+
+	if v := c.QueryParam("cursor"); v != "" {
+		if cursor, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return makeJSONError(c, util.ErrorBadRequest)
+		}
+	}
+	if v := c.QueryParam("count"); v != "" {
+		if options.Count, err = strconv.Atoi(v); err != nil {
+			return makeJSONError(c, util.ErrorBadRequest)
+		}
+	}
+	options.Match = c.QueryParam("match")
+	options.Type = c.QueryParam("type")
+
+	keys, next, err := server.cache.Scan(cursor, options)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.ScanDTO{Cursor: next, Keys: keys})
+}
+
 // Get cache stats
 // curl -i -w "\n" --user alex:secret localhost:8027/v1/stats
 func (server *SERVER) stats(c echo.Context) error {
 	return c.JSON(http.StatusOK, util.StatsDTO{Stats: server.cache.Stats()})
 }
 
+// Save snapshot of the cache to disk, fails when snapshots are disabled
+// curl -i -w "\n" -X POST --user alex:secret localhost:8027/v1/save
+func (server *SERVER) save(c echo.Context) error {
+	snapshotPath := server.config.Persistence.SnapshotPath
+	if snapshotPath == "" {
+		return makeJSONError(c, util.ErrorBadRequest)
+	}
+
+	if err := server.cache.SaveFile(snapshotPath); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Compact append-only log in the background of writers
+// curl -i -w "\n" -X POST --user alex:secret localhost:8027/v1/rewrite
+func (server *SERVER) rewrite(c echo.Context) error {
+	if err := server.cache.RewriteAppendLog(); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
 // Get value from cache by key
 // Auto type conversion
-// Returns value or ErrorWrongType if not supported value type
+// Returns value or ErrorWrongType if not supported value type, version of value in ETag header
 // curl -i -w "\n" --user alex:secret localhost:8027/v1/get/vvv
 func (server *SERVER) get(c echo.Context) error {
 	key := c.Param("key")
 
-	value, err := server.cache.Get(key)
+	value, version, err := server.cache.GetWithVersion(key)
 	if err != nil {
 		return makeJSONError(c, err)
 	}
+	setETag(c, version)
 
 	switch v := value.(type) {
 	case int:
@@ -136,6 +423,10 @@ func (server *SERVER) get(c echo.Context) error {
 		return c.JSON(http.StatusOK, util.ListDTO{Value: v})
 	case util.Dict:
 		return c.JSON(http.StatusOK, util.DictDTO{Value: v})
+	case util.Set:
+		return c.JSON(http.StatusOK, util.SetDTO{Value: v})
+	case util.ZSet:
+		return c.JSON(http.StatusOK, util.ZSetDTO{Value: v})
 	default:
 		return makeJSONError(c, util.ErrorWrongType)
 	}
@@ -191,7 +482,9 @@ func (server *SERVER) hasKey(c echo.Context) error {
 }
 
 // Set string
+// Optional ttl in milliseconds is applied atomically with the value
 // curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":"s1"}' localhost:8027/v1/string/sss
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":"s1","ttl":5000}' localhost:8027/v1/string/sss
 func (server *SERVER) setString(c echo.Context) error {
 	key := c.Param("key")
 
@@ -200,7 +493,7 @@ func (server *SERVER) setString(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	err := server.cache.SetString(key, value.Value)
+	err := server.cache.SetString(key, value.Value, value.TTL)
 	if err != nil {
 		return makeJSONError(c, err)
 	}
@@ -218,7 +511,7 @@ func (server *SERVER) setInt(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	err := server.cache.SetInt(key, value.Value)
+	err := server.cache.SetInt(key, value.Value, value.TTL)
 	if err != nil {
 		return makeJSONError(c, err)
 	}
@@ -236,7 +529,7 @@ func (server *SERVER) setList(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	err := server.cache.SetList(key, value.Value)
+	err := server.cache.SetList(key, value.Value, value.TTL)
 	if err != nil {
 		return makeJSONError(c, err)
 	}
@@ -254,7 +547,7 @@ func (server *SERVER) setDict(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	err := server.cache.SetDict(key, value.Value)
+	err := server.cache.SetDict(key, value.Value, value.TTL)
 	if err != nil {
 		return makeJSONError(c, err)
 	}
@@ -273,12 +566,14 @@ func (server *SERVER) updateString(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	v, err := server.cache.UpdateString(key, value.Value)
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.UpdateString(key, value.Value)
+	})
 	if err != nil {
 		return makeJSONError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, util.StringDTO{Value: v})
+	return c.JSON(http.StatusOK, util.StringDTO{Value: result.(string)})
 }
 
 // Update int by key
@@ -292,12 +587,14 @@ func (server *SERVER) updateInt(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	v, err := server.cache.UpdateInt(key, value.Value)
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.UpdateInt(key, value.Value)
+	})
 	if err != nil {
 		return makeJSONError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, util.IntDTO{Value: v})
+	return c.JSON(http.StatusOK, util.IntDTO{Value: result.(int)})
 }
 
 // Update list by key
@@ -311,12 +608,14 @@ func (server *SERVER) updateList(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	v, err := server.cache.UpdateList(key, value.Value)
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.UpdateList(key, value.Value)
+	})
 	if err != nil {
 		return makeJSONError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, util.ListDTO{Value: v})
+	return c.JSON(http.StatusOK, util.ListDTO{Value: result.(util.List)})
 }
 
 // Update dict by key
@@ -330,12 +629,14 @@ func (server *SERVER) updateDict(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	v, err := server.cache.UpdateDict(key, value.Value)
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.UpdateDict(key, value.Value)
+	})
 	if err != nil {
 		return makeJSONError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, util.DictDTO{Value: v})
+	return c.JSON(http.StatusOK, util.DictDTO{Value: result.(util.Dict)})
 }
 
 // Append to list a string element
@@ -349,7 +650,9 @@ func (server *SERVER) appendToList(c echo.Context) error {
 		return makeJSONError(c, err)
 	}
 
-	err := server.cache.AppendToList(key, value.Value)
+	_, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.AppendToList(key, value.Value)
+	})
 	if err != nil {
 		return makeJSONError(c, err)
 	}
@@ -363,12 +666,14 @@ func (server *SERVER) appendToList(c echo.Context) error {
 func (server *SERVER) increment(c echo.Context) error {
 	key := c.Param("key")
 
-	v, err := server.cache.Increment(key)
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.Increment(key)
+	})
 	if err != nil {
 		return makeJSONError(c, err)
 	}
 
-	return c.JSON(http.StatusOK, util.IntDTO{Value: v})
+	return c.JSON(http.StatusOK, util.IntDTO{Value: result.(int)})
 }
 
 // Remove object from cache by key
@@ -422,7 +727,7 @@ func (server *SERVER) removeFromDict(c echo.Context) error {
 	return c.JSON(http.StatusOK, util.BasicDTO{})
 }
 
-// Set TTL (time-to-live) in nanoseconds for object by key
+// Set TTL (time-to-live) in milliseconds for object by key
 // curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":5211}' localhost:8027/v1/ttl/iii
 func (server *SERVER) setTTL(c echo.Context) error {
 	key := c.Param("key")
@@ -439,3 +744,320 @@ func (server *SERVER) setTTL(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, util.BasicDTO{})
 }
+
+// Get remaining TTL (time-to-live) in milliseconds for object by key
+// Returns -1 if object has no TTL
+// curl -i -w "\n" --user alex:secret localhost:8027/v1/ttl/iii
+func (server *SERVER) getTTL(c echo.Context) error {
+	key := c.Param("key")
+
+	v, err := server.cache.GetTTL(key)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: v})
+}
+
+// Remove TTL (time-to-live) for object by key
+// Returns false if object had no TTL
+// curl -i -w "\n" -X DELETE --user alex:secret localhost:8027/v1/ttl/iii
+func (server *SERVER) persist(c echo.Context) error {
+	key := c.Param("key")
+
+	v, err := server.cache.Persist(key)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BoolDTO{Value: v})
+}
+
+// Set expiration time as unix timestamp in milliseconds for object by key
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":1893456000000}' localhost:8027/v1/expireat/iii
+func (server *SERVER) expireAt(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.IntDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	err := server.cache.ExpireAt(key, value.Value)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Add members to set, the set is created if missing
+// Returns number of added members
+// curl -i -w "\n" -X PUT --user alex:secret -H 'Content-Type: application/json' -d '{"value":["m1", "m2"]}' localhost:8027/v1/set/element/ttt
+func (server *SERVER) sAdd(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.ListDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.SAdd(key, value.Value...)
+	})
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: result.(int)})
+}
+
+// Remove members from set
+// Returns number of removed members
+// curl -i -w "\n" -X DELETE --user alex:secret -H 'Content-Type: application/json' -d '{"value":["m1"]}' localhost:8027/v1/set/element/ttt
+func (server *SERVER) sRem(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.ListDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	v, err := server.cache.SRem(key, value.Value...)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: v})
+}
+
+// Check if member is in set
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":"m2"}' localhost:8027/v1/set/element/ttt
+func (server *SERVER) sIsMember(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.StringDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	v, err := server.cache.SIsMember(key, value.Value)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BoolDTO{Value: v})
+}
+
+// Get set members
+// curl -i -w "\n" --user alex:secret localhost:8027/v1/set/ttt
+func (server *SERVER) sMembers(c echo.Context) error {
+	key := c.Param("key")
+
+	v, err := server.cache.SMembers(key)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.SetDTO{Value: v})
+}
+
+// Intersect sets by keys
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":["ttt", "ttt2"]}' localhost:8027/v1/sets/inter
+func (server *SERVER) sInter(c echo.Context) error {
+	return server.combineSets(c, server.cache.SInter)
+}
+
+// Union sets by keys
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":["ttt", "ttt2"]}' localhost:8027/v1/sets/union
+func (server *SERVER) sUnion(c echo.Context) error {
+	return server.combineSets(c, server.cache.SUnion)
+}
+
+// Subtract other sets from the first one by keys
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":["ttt", "ttt2"]}' localhost:8027/v1/sets/diff
+func (server *SERVER) sDiff(c echo.Context) error {
+	return server.combineSets(c, server.cache.SDiff)
+}
+
+func (server *SERVER) combineSets(c echo.Context, combine func(keys ...string) (util.Set, error)) error {
+	value := new(util.ListDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return server.routeKeys(c, value.Value, func() error {
+		v, err := combine(value.Value...)
+		if err != nil {
+			return makeJSONError(c, err)
+		}
+
+		return c.JSON(http.StatusOK, util.SetDTO{Value: v})
+	})
+}
+
+// Add members to sorted set or update their scores, the sorted set is created if missing
+// Returns number of added members
+// curl -i -w "\n" -X PUT --user alex:secret -H 'Content-Type: application/json' -d '{"value":[{"member":"m1","score":10}, {"member":"m2","score":20.5}]}' localhost:8027/v1/zset/element/zzz
+func (server *SERVER) zAdd(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.ZSetDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.ZAdd(key, value.Value...)
+	})
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: result.(int)})
+}
+
+// Increment score of sorted set member by the given score
+// Returns the new score
+// curl -i -w "\n" -X PUT --user alex:secret -H 'Content-Type: application/json' -d '{"value":{"member":"m1","score":2.5}}' localhost:8027/v1/zset/increment/zzz
+func (server *SERVER) zIncrBy(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.ZMemberDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	result, err := server.versioned(c, key, func(tx *memory.Tx) {
+		tx.ZIncrBy(key, value.Value.Score, value.Value.Member)
+	})
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.FloatDTO{Value: result.(float64)})
+}
+
+// Remove members from sorted set
+// Returns number of removed members
+// curl -i -w "\n" -X DELETE --user alex:secret -H 'Content-Type: application/json' -d '{"value":["m1"]}' localhost:8027/v1/zset/element/zzz
+func (server *SERVER) zRem(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.ListDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	v, err := server.cache.ZRem(key, value.Value...)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: v})
+}
+
+// Remove sorted set members by score range, missing bound means infinity
+// Returns number of removed members
+// curl -i -w "\n" -X DELETE --user alex:secret -H 'Content-Type: application/json' -d '{"max":15}' localhost:8027/v1/zset/score/zzz
+func (server *SERVER) zRemRangeByScore(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.ScoreRangeDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	min, max := scoreBounds(value)
+	v, err := server.cache.ZRemRangeByScore(key, min, max)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: v})
+}
+
+// Get score of sorted set member
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":"m2"}' localhost:8027/v1/zset/element/zzz
+func (server *SERVER) zScore(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.StringDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	v, err := server.cache.ZScore(key, value.Value)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.FloatDTO{Value: v})
+}
+
+// Get 0-based rank of sorted set member, lowest score first
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":"m2"}' localhost:8027/v1/zset/rank/zzz
+func (server *SERVER) zRank(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.StringDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	v, err := server.cache.ZRank(key, value.Value)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.IntDTO{Value: v})
+}
+
+// Get sorted set members by rank range, negative ranks count from the end
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"start":0,"stop":-1}' localhost:8027/v1/zset/range/zzz
+func (server *SERVER) zRange(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.RangeDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	v, err := server.cache.ZRange(key, value.Start, value.Stop)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.ZSetDTO{Value: v})
+}
+
+// Get sorted set members by score range, missing bound means infinity
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"min":10,"max":20}' localhost:8027/v1/zset/score/zzz
+func (server *SERVER) zRangeByScore(c echo.Context) error {
+	key := c.Param("key")
+
+	value := new(util.ScoreRangeDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	min, max := scoreBounds(value)
+	v, err := server.cache.ZRangeByScore(key, min, max)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.ZSetDTO{Value: v})
+}
+
+func scoreBounds(value *util.ScoreRangeDTO) (float64, float64) {
+	min, max := math.Inf(-1), math.Inf(1)
+	if value.Min != nil {
+		min = *value.Min
+	}
+	if value.Max != nil {
+		max = *value.Max
+	}
+
+	return min, max
+}
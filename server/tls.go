@@ -0,0 +1,188 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Certificates of the server, swapped on reload without dropping connections
+type certificates struct {
+	sync.RWMutex
+	cert *tls.Certificate
+	// CA bundle of client certificates, nil - clients are not asked for one
+	clientCAs *x509.CertPool
+	// Client of other nodes
+	client *http.Client
+}
+
+// Load certificate, key and CA bundles from the configured files
+// Call it again, e.g. on SIGHUP, to pick up renewed files: new connections get them,
+// connections in progress keep the old ones. On error the old ones stay in use.
+func (server *SERVER) LoadCertificates() error {
+	config := server.config.TLS
+	if config.CertFile == "" {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if config.ClientCAFile != "" {
+		if clientCAs, err = loadCertPool(config.ClientCAFile); err != nil {
+			return err
+		}
+	}
+
+	// Other nodes are verified with the explicit bundle, the client one or the issuers of our own certificate,
+	// nil falls back to system roots
+	nodeCAs := clientCAs
+	switch {
+	case config.CAFile != "":
+		if nodeCAs, err = loadCertPool(config.CAFile); err != nil {
+			return err
+		}
+	case nodeCAs == nil:
+		if nodeCAs, err = issuerPool(cert); err != nil {
+			return err
+		}
+	}
+
+	certs := server.certs
+	certs.Lock()
+	defer certs.Unlock()
+
+	if certs.client != nil {
+		certs.client.Transport.(*http.Transport).CloseIdleConnections()
+	}
+	certs.cert = &cert
+	certs.clientCAs = clientCAs
+	certs.client = &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				RootCAs:      nodeCAs,
+				Certificates: []tls.Certificate{cert},
+			},
+		},
+	}
+
+	return nil
+}
+
+// CA bundle from PEM file
+func loadCertPool(name string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", name)
+	}
+
+	return pool, nil
+}
+
+// Issuers following the leaf in the certificate chain, nil if the chain holds only the leaf
+func issuerPool(cert tls.Certificate) (*x509.CertPool, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, nil
+	}
+
+	pool := x509.NewCertPool()
+	for _, der := range cert.Certificate[1:] {
+		issuer, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		pool.AddCert(issuer)
+	}
+
+	return pool, nil
+}
+
+// Reload certificates on SIGHUP until the process exits
+func (server *SERVER) reloadCertificatesOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			if err := server.LoadCertificates(); err != nil {
+				log.Printf("certificates not reloaded: %v", err)
+			} else {
+				log.Printf("certificates reloaded")
+			}
+		}
+	}()
+}
+
+// TLS config of the listeners, nil if TLS is disabled
+// Clients have to present a certificate signed by the client CA bundle when it is set
+func (server *SERVER) serverTLSConfig() *tls.Config {
+	if !server.tlsEnabled() {
+		return nil
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certs := server.certs
+			certs.RLock()
+			defer certs.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*certs.cert},
+			}
+			if certs.clientCAs != nil {
+				config.ClientCAs = certs.clientCAs
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return config, nil
+		},
+	}
+}
+
+// Client for calls to other nodes, it presents the server certificate when TLS is enabled
+// and verifies nodes with the node CA bundle
+func (server *SERVER) nodeClient() *http.Client {
+	server.certs.RLock()
+	defer server.certs.RUnlock()
+
+	if server.certs.client == nil {
+		return http.DefaultClient
+	}
+
+	return server.certs.client
+}
+
+// Wrap listener with TLS when it is enabled
+func (server *SERVER) tlsListener(l net.Listener) net.Listener {
+	if config := server.serverTLSConfig(); config != nil {
+		return tls.NewListener(l, config)
+	}
+
+	return l
+}
+
+func (server *SERVER) tlsEnabled() bool {
+	server.certs.RLock()
+	defer server.certs.RUnlock()
+
+	return server.certs.cert != nil
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anevsky/cachego/client"
+)
+
+// Self-signed CA issuing certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	dir, err := ioutil.TempDir("", "cachego-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &testCA{dir: dir}
+	ca.cert, ca.key = ca.issue(t, "ca", nil, nil)
+
+	return ca
+}
+
+// Write certificate signed by the CA and its key to dir/name.pem and dir/name.key
+// Nil parent makes a self-signed CA certificate
+func (ca *testCA) issue(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		// Nodes present their server certificates to each other
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(ca.path(name+".pem"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(ca.path(name+".key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+// Serial number of the certificate presented by the server
+func serverSerial(t *testing.T, addr string, config *tls.Config) *big.Int {
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber
+}
+
+func TestTLS(t *testing.T) {
+	t.Log("Testing mutual TLS for HTTP and RESP...")
+
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	ca.issue(t, "server", ca.cert, ca.key)
+	ca.issue(t, "client", ca.cert, ca.key)
+
+	config := DefaultConfig()
+	config.TLS = TLSConfig{CertFile: ca.path("server.pem"), KeyFile: ca.path("server.key"), ClientCAFile: ca.path("ca.pem")}
+	server := CreateWithConfig(config)
+	if err := server.LoadCertificates(); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(server.handler())
+	ts.TLS = server.serverTLSConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	cli := clusterClient(ts.URL)
+	if err := cli.UseTLS(client.TLSOptions{CAFile: ca.path("ca.pem"), CertFile: ca.path("client.pem"), KeyFile: ca.path("client.key")}); err != nil {
+		t.Fatal(err)
+	}
+	if errs := cli.SetString("k", "v"); errs != nil {
+		t.Fatal(errs)
+	}
+	if v, errs := cli.GetString("k"); errs != nil || v != "v" {
+		t.Errorf("Expected v, but it was %s (%v) instead.", v, errs)
+	}
+	events, stop, errs := cli.Watch("")
+	if errs != nil {
+		t.Fatalf("Expected stream over TLS, but it was %v instead.", errs)
+	}
+	stop()
+	for range events {
+	}
+
+	// Client certificate is required, server certificate is verified
+	anonymous := clusterClient(ts.URL)
+	anonymous.UseTLS(client.TLSOptions{CAFile: ca.path("ca.pem")})
+	if _, errs := anonymous.GetString("k"); errs == nil {
+		t.Errorf("Expected client without certificate to be rejected.")
+	}
+	untrusting := clusterClient(ts.URL)
+	untrusting.UseTLS(client.TLSOptions{CertFile: ca.path("client.pem"), KeyFile: ca.path("client.key")})
+	if _, errs := untrusting.GetString("k"); errs == nil {
+		t.Errorf("Expected server certificate of unknown authority to be rejected.")
+	}
+
+	// RESP over TLS
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go server.ServeRESP(server.tlsListener(l))
+
+	clientCert, err := tls.LoadX509KeyPair(ca.path("client.pem"), ca.path("client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientConfig := &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &respTestClient{conn, &respReader{bufio.NewReader(conn)}}
+	c.do(t, "AUTH", defaultPassword)
+	if reply := c.do(t, "GET", "k"); reply != "v" {
+		t.Errorf("Expected v, but it was %s instead.", reply)
+	}
+
+	// Renewed certificate is served to new connections, the open one keeps working
+	before := serverSerial(t, l.Addr().String(), clientConfig)
+	renewed, _ := ca.issue(t, "server", ca.cert, ca.key)
+	if err := server.LoadCertificates(); err != nil {
+		t.Fatal(err)
+	}
+	if after := serverSerial(t, l.Addr().String(), clientConfig); after.Cmp(renewed.SerialNumber) != 0 || after.Cmp(before) == 0 {
+		t.Errorf("Expected renewed certificate %v, but it was %v instead.", renewed.SerialNumber, after)
+	}
+	if reply := c.do(t, "GET", "k"); reply != "v" {
+		t.Errorf("Expected v, but it was %s instead.", reply)
+	}
+
+	// Broken files keep the current certificate
+	ioutil.WriteFile(ca.path("server.pem"), []byte("garbage"), 0600)
+	if err := server.LoadCertificates(); err == nil {
+		t.Errorf("Expected error for broken certificate.")
+	}
+	if after := serverSerial(t, l.Addr().String(), clientConfig); after.Cmp(renewed.SerialNumber) != 0 {
+		t.Errorf("Expected certificate %v, but it was %v instead.", renewed.SerialNumber, after)
+	}
+}
+
+func TestTLSNodeClient(t *testing.T) {
+	t.Log("Testing nodes verify each other without client certificates...")
+
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	ca.issue(t, "server", ca.cert, ca.key)
+
+	// Chain of the server certificate followed by its issuer
+	leaf, _ := ioutil.ReadFile(ca.path("server.pem"))
+	issuer, _ := ioutil.ReadFile(ca.path("ca.pem"))
+	ioutil.WriteFile(ca.path("chain.pem"), append(leaf, issuer...), 0600)
+
+	configs := map[string]TLSConfig{
+		"ca_file":      {CertFile: ca.path("server.pem"), KeyFile: ca.path("server.key"), CAFile: ca.path("ca.pem")},
+		"issuer":       {CertFile: ca.path("chain.pem"), KeyFile: ca.path("server.key")},
+		"system roots": {CertFile: ca.path("server.pem"), KeyFile: ca.path("server.key")},
+	}
+
+	for name, tlsConfig := range configs {
+		config := DefaultConfig()
+		config.TLS = tlsConfig
+		server := CreateWithConfig(config)
+		if err := server.LoadCertificates(); err != nil {
+			t.Fatal(err)
+		}
+
+		ts := httptest.NewUnstartedServer(server.handler())
+		ts.TLS = server.serverTLSConfig()
+		ts.StartTLS()
+
+		res, err := server.nodeClient().Get(ts.URL)
+		if name == "system roots" {
+			if err == nil {
+				res.Body.Close()
+				t.Errorf("Expected private CA to be unknown to system roots.")
+			}
+		} else if err != nil {
+			t.Errorf("Expected node call with %s, but it was %v instead.", name, err)
+		} else {
+			res.Body.Close()
+		}
+		ts.Close()
+	}
+}
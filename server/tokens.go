@@ -0,0 +1,246 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+)
+
+// Header with API token as an alternative to Authorization: Bearer
+const headerAPIKey = "X-API-Key"
+
+// API tokens, each acting on behalf of a user with the user's ACL
+// Tokens live in memory, they are lost on restart
+type tokens struct {
+	sync.RWMutex
+	byID map[string]*token
+}
+
+type token struct {
+	id, name, username string
+	// Digest of the secret part, the token itself is shown once on creation
+	digest    [sha256.Size]byte
+	createdAt time.Time
+	// Zero - never
+	expiresAt time.Time
+}
+
+func newTokens() *tokens {
+	return &tokens{byID: map[string]*token{}}
+}
+
+// Issue token for user, ttl 0 - never expires
+// Returns the token in the form id.secret
+func (ts *tokens) create(username, name string, ttl time.Duration) (util.TokenDTO, error) {
+	random := make([]byte, 40)
+	if _, err := rand.Read(random); err != nil {
+		return util.TokenDTO{}, err
+	}
+	id, secret := hex.EncodeToString(random[:8]), hex.EncodeToString(random[8:])
+
+	t := &token{
+		id:        id,
+		name:      name,
+		username:  username,
+		digest:    sha256.Sum256([]byte(secret)),
+		createdAt: time.Now(),
+	}
+	if ttl > 0 {
+		t.expiresAt = t.createdAt.Add(ttl)
+	}
+
+	ts.Lock()
+	defer ts.Unlock()
+
+	ts.byID[id] = t
+	dto := t.dto()
+	dto.Token = id + "." + secret
+
+	return dto, nil
+}
+
+// Username of a valid token
+func (ts *tokens) check(value string) (string, bool) {
+	i := strings.Index(value, ".")
+	if i < 0 {
+		return "", false
+	}
+	digest := sha256.Sum256([]byte(value[i+1:]))
+
+	ts.RLock()
+	defer ts.RUnlock()
+
+	t, ok := ts.byID[value[:i]]
+	if !ok || t.expired(time.Now()) || subtle.ConstantTimeCompare(digest[:], t.digest[:]) != 1 {
+		return "", false
+	}
+
+	return t.username, true
+}
+
+// Let token expire after ttl, e.g. to give clients time to switch to its replacement
+func (ts *tokens) expire(id string, ttl time.Duration) (util.TokenDTO, error) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	t, ok := ts.byID[id]
+	if !ok {
+		return util.TokenDTO{}, util.ErrorTokenNotFound
+	}
+	t.expiresAt = time.Now().Add(ttl)
+
+	return t.dto(), nil
+}
+
+// Revoke token right away
+func (ts *tokens) revoke(id string) error {
+	ts.Lock()
+	defer ts.Unlock()
+
+	if _, ok := ts.byID[id]; !ok {
+		return util.ErrorTokenNotFound
+	}
+	delete(ts.byID, id)
+
+	return nil
+}
+
+// Tokens without secrets, oldest first, expired ones are dropped
+func (ts *tokens) list() []util.TokenDTO {
+	ts.Lock()
+	defer ts.Unlock()
+
+	at := time.Now()
+	result := make([]util.TokenDTO, 0, len(ts.byID))
+	for id, t := range ts.byID {
+		if t.expired(at) {
+			delete(ts.byID, id)
+			continue
+		}
+		result = append(result, t.dto())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt < result[j].CreatedAt })
+
+	return result
+}
+
+func (t *token) expired(at time.Time) bool {
+	return !t.expiresAt.IsZero() && !at.Before(t.expiresAt)
+}
+
+func (t *token) dto() util.TokenDTO {
+	dto := util.TokenDTO{
+		ID:        t.id,
+		Name:      t.name,
+		Username:  t.username,
+		CreatedAt: t.createdAt.UnixNano() / int64(time.Millisecond),
+	}
+	if !t.expiresAt.IsZero() {
+		dto.ExpiresAt = t.expiresAt.UnixNano() / int64(time.Millisecond)
+	}
+
+	return dto
+}
+
+// Middleware authenticating requests by API token or BasicAuth
+// Token comes in Authorization: Bearer or X-API-Key header
+func (server *SERVER) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		value := req.Header.Get(headerAPIKey)
+		if auth := req.Header.Get(echo.HeaderAuthorization); strings.HasPrefix(auth, "Bearer ") {
+			value = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		if value != "" {
+			username, ok := server.tokens.check(value)
+			if !ok || !server.users.enabled(username) {
+				return makeJSONError(c, util.ErrorUnauthorized)
+			}
+			c.Set(userContextKey, username)
+			return next(c)
+		}
+
+		username, password, ok := req.BasicAuth()
+		if !ok || !server.checkCredentials(username, password) {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "basic realm=Restricted")
+			return makeJSONError(c, util.ErrorUnauthorized)
+		}
+		c.Set(userContextKey, username)
+
+		return next(c)
+	}
+}
+
+///////////////////////////////////////
+// Handlers
+///////////////////////////////////////
+
+// Issue API token for user, ttl in milliseconds, 0 - never expires
+// The token is returned only once
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"username":"alex","name":"ci","ttl":86400000}' localhost:8027/v1/tokens
+func (server *SERVER) createToken(c echo.Context) error {
+	value := new(util.TokenDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	if value.TTL < 0 {
+		return makeJSONError(c, util.ErrorInvalidTTLValue)
+	}
+	if !server.users.exists(value.Username) {
+		return makeJSONError(c, util.ErrorUserNotFound)
+	}
+
+	dto, err := server.tokens.create(value.Username, value.Name, time.Duration(value.TTL)*time.Millisecond)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto)
+}
+
+// Get API tokens without their secrets
+// curl -i -w "\n" --user alex:secret localhost:8027/v1/tokens
+func (server *SERVER) listTokens(c echo.Context) error {
+	return c.JSON(http.StatusOK, util.TokensDTO{Tokens: server.tokens.list()})
+}
+
+// Let API token expire after ttl in milliseconds, e.g. once its replacement is rolled out
+// curl -i -w "\n" -X POST --user alex:secret -H 'Content-Type: application/json' -d '{"value":60000}' localhost:8027/v1/tokens/0123456789abcdef/expire
+func (server *SERVER) expireToken(c echo.Context) error {
+	value := new(util.IntDTO)
+	if err := c.Bind(value); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	if value.Value < 0 {
+		return makeJSONError(c, util.ErrorInvalidTTLValue)
+	}
+
+	dto, err := server.tokens.expire(c.Param("id"), time.Duration(value.Value)*time.Millisecond)
+	if err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, dto)
+}
+
+// Revoke API token right away
+// curl -i -w "\n" -X DELETE --user alex:secret localhost:8027/v1/tokens/0123456789abcdef
+func (server *SERVER) revokeToken(c echo.Context) error {
+	if err := server.tokens.revoke(c.Param("id")); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
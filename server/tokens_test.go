@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anevsky/cachego/client"
+	"github.com/anevsky/cachego/util"
+)
+
+func TestTokens(t *testing.T) {
+	t.Log("Testing API token authentication and rotation...")
+
+	server := aclServer(t)
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	admin := clusterClient(ts.URL)
+	issued, errs := admin.CreateToken("reader", "web", 0)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	if issued.Token == "" || issued.Username != "reader" || issued.ExpiresAt != 0 {
+		t.Errorf("Expected never expiring token of reader, but it was %v instead.", issued)
+	}
+	if _, errs := admin.CreateToken("nobody", "", 0); errs == nil {
+		t.Errorf("Expected %v.", util.ErrorUserNotFound)
+	}
+
+	// Token acts with ACL of its user
+	server.cache.SetString("user:1", "v")
+	cli := client.CreateWithToken(issued.Token)
+	cli.Url, cli.APIUrl = ts.URL, "/v1"
+	if v, errs := cli.GetString("user:1"); errs != nil || v != "v" {
+		t.Errorf("Expected v, but it was %v (%v) instead.", v, errs)
+	}
+	if errs := cli.SetString("user:1", "w"); errs == nil {
+		t.Errorf("Expected %v for read-only user.", util.ErrorPermissionDenied)
+	}
+
+	// X-API-Key works as well as Authorization: Bearer
+	status := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/get/user:1", nil)
+		req.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		server.handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := status(headerAPIKey, issued.Token); code != http.StatusOK {
+		t.Errorf("Expected %d, but it was %d instead.", http.StatusOK, code)
+	}
+	if code := status("Authorization", "Bearer "+issued.ID+".forged"); code != http.StatusUnauthorized {
+		t.Errorf("Expected %d, but it was %d instead.", http.StatusUnauthorized, code)
+	}
+
+	// Rotation: the new token works, the old one is expired
+	rotated, errs := admin.CreateToken("reader", "web", 60000)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	if errs := admin.ExpireToken(issued.ID, 0); errs != nil {
+		t.Fatal(errs)
+	}
+	if code := status(headerAPIKey, issued.Token); code != http.StatusUnauthorized {
+		t.Errorf("Expected %d for expired token, but it was %d instead.", http.StatusUnauthorized, code)
+	}
+	if code := status(headerAPIKey, rotated.Token); code != http.StatusOK {
+		t.Errorf("Expected %d, but it was %d instead.", http.StatusOK, code)
+	}
+
+	list, errs := admin.Tokens()
+	if errs != nil || len(list) != 1 || list[0].ID != rotated.ID || list[0].Token != "" || list[0].ExpiresAt == 0 {
+		t.Errorf("Expected only the rotated token without secret, but it was %v (%v) instead.", list, errs)
+	}
+
+	// RESP clients authenticate with the token as password
+	c, stop := serveRESP(t, &server)
+	defer stop()
+	if reply := c.do(t, "AUTH", rotated.Token); reply != "+OK" {
+		t.Errorf("Expected +OK, but it was %s instead.", reply)
+	}
+	if reply := c.do(t, "SET", "user:1", "w"); !strings.HasPrefix(reply, "-NOPERM") {
+		t.Errorf("Expected -NOPERM, but it was %s instead.", reply)
+	}
+
+	if errs := admin.RevokeToken(rotated.ID); errs != nil {
+		t.Fatal(errs)
+	}
+	if code := status(headerAPIKey, rotated.Token); code != http.StatusUnauthorized {
+		t.Errorf("Expected %d for revoked token, but it was %d instead.", http.StatusUnauthorized, code)
+	}
+	if errs := admin.RevokeToken(rotated.ID); errs == nil {
+		t.Errorf("Expected %v.", util.ErrorTokenNotFound)
+	}
+
+	// Tokens are managed by admins only
+	issued, _ = admin.CreateToken("reader", "", 0)
+	cli = client.CreateWithToken(issued.Token)
+	cli.Url, cli.APIUrl = ts.URL, "/v1"
+	if _, errs := cli.Tokens(); errs == nil {
+		t.Errorf("Expected %v.", util.ErrorPermissionDenied)
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Route groups users can be allowed to
+const (
+	// Reads and writes of keys by name
+	GroupKeys = "keys"
+	// Listing and watching keys, counts and stats, only for users with access to all keys
+	GroupKeyspace = "keyspace"
+	// Publishing and subscribing to channels
+	GroupPubSub = "pubsub"
+	// Persistence, replication, cluster and user management
+	GroupAdmin = "admin"
+)
+
+var routeGroups = map[string]bool{GroupKeys: true, GroupKeyspace: true, GroupPubSub: true, GroupAdmin: true}
+
+// Context key of the authenticated username
+const userContextKey = "user"
+
+// Users allowed to access the server
+type users struct {
+	sync.RWMutex
+	accounts map[string]*account
+}
+
+type account struct {
+	// bcrypt hash of the password, nil if it was configured in plain text
+	hash []byte
+	// Digest of the password last matching the hash, saves bcrypt cost on every request
+	verified [sha256.Size]byte
+	disabled bool
+	acl      ACL
+}
+
+func newUsers(configured []User) *users {
+	us := &users{accounts: map[string]*account{}}
+	for _, user := range configured {
+		a := &account{disabled: user.Disabled, acl: user.ACL}
+		if user.PasswordHash != "" {
+			a.hash = []byte(user.PasswordHash)
+		} else {
+			a.verified = sha256.Sum256([]byte(user.Password))
+		}
+		us.accounts[user.Username] = a
+	}
+
+	return us
+}
+
+// Check password of an enabled user
+func (us *users) check(username, password string) bool {
+	digest := sha256.Sum256([]byte(password))
+
+	us.RLock()
+	a, ok := us.accounts[username]
+	if !ok || a.disabled {
+		us.RUnlock()
+		return false
+	}
+	hash, verified := a.hash, a.verified
+	us.RUnlock()
+
+	if subtle.ConstantTimeCompare(digest[:], verified[:]) == 1 {
+		return true
+	}
+	if hash == nil || bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
+	}
+
+	us.Lock()
+	a.verified = digest
+	us.Unlock()
+
+	return true
+}
+
+// Check user exists, enabled or not
+func (us *users) exists(username string) bool {
+	us.RLock()
+	defer us.RUnlock()
+
+	_, ok := us.accounts[username]
+	return ok
+}
+
+// Check user exists and is enabled
+func (us *users) enabled(username string) bool {
+	us.RLock()
+	defer us.RUnlock()
+
+	a, ok := us.accounts[username]
+	return ok && !a.disabled
+}
+
+// Check user may call a route of the group, write keys if write is set, and access keys
+// Empty group is not checked
+func (us *users) authorize(username, group string, write bool, keys []string) error {
+	us.RLock()
+	defer us.RUnlock()
+
+	a, ok := us.accounts[username]
+	if !ok || a.disabled {
+		return util.ErrorPermissionDenied
+	}
+
+	if group != "" && len(a.acl.Groups) > 0 {
+		allowed := false
+		for _, g := range a.acl.Groups {
+			allowed = allowed || g == group
+		}
+		if !allowed {
+			return util.ErrorPermissionDenied
+		}
+	}
+
+	if write && a.acl.ReadOnly {
+		return util.ErrorPermissionDenied
+	}
+
+	if len(a.acl.Keys) > 0 {
+		if group == GroupKeyspace {
+			return util.ErrorPermissionDenied
+		}
+		for _, key := range keys {
+			if !a.acl.allowsKey(key) {
+				return util.ErrorPermissionDenied
+			}
+		}
+	}
+
+	return nil
+}
+
+func (acl ACL) allowsKey(key string) bool {
+	for _, pattern := range acl.Keys {
+		if util.MatchGlob(pattern, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Enable or disable user, requests of a disabled user are rejected right away
+func (us *users) enable(username string, enabled bool) error {
+	us.Lock()
+	defer us.Unlock()
+
+	a, ok := us.accounts[username]
+	if !ok {
+		return util.ErrorUserNotFound
+	}
+	a.disabled = !enabled
+
+	return nil
+}
+
+// Users sorted by name
+func (us *users) list() []util.UserDTO {
+	us.RLock()
+	defer us.RUnlock()
+
+	result := make([]util.UserDTO, 0, len(us.accounts))
+	for username, a := range us.accounts {
+		result = append(result, util.UserDTO{
+			Username: username,
+			Disabled: a.disabled,
+			ReadOnly: a.acl.ReadOnly,
+			Groups:   a.acl.Groups,
+			Keys:     a.acl.Keys,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Username < result[j].Username })
+
+	return result
+}
+
+// Enable or disable user at runtime
+func (server *SERVER) EnableUser(username string, enabled bool) error {
+	return server.users.enable(username, enabled)
+}
+
+// Check password of a configured user
+func (server *SERVER) checkCredentials(username, password string) bool {
+	return server.users.check(username, password)
+}
+
+// Middleware rejecting requests of users not allowed to the route group
+func (server *SERVER) allow(group string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := server.authorize(c, group, false, nil); err != nil {
+				return makeJSONError(c, err)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Check authenticated user of the request
+func (server *SERVER) authorize(c echo.Context, group string, write bool, keys []string) error {
+	username, _ := c.Get(userContextKey).(string)
+	return server.users.authorize(username, group, write, keys)
+}
+
+///////////////////////////////////////
+// Handlers
+///////////////////////////////////////
+
+// Get users with their access rules
+// curl -i -w "\n" --user alex:secret localhost:8027/v1/users
+func (server *SERVER) listUsers(c echo.Context) error {
+	return c.JSON(http.StatusOK, util.UsersDTO{Users: server.users.list()})
+}
+
+// Enable user
+// curl -i -w "\n" -X POST --user alex:secret localhost:8027/v1/users/bob/enable
+func (server *SERVER) enableUser(c echo.Context) error {
+	if err := server.EnableUser(c.Param("username"), true); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
+
+// Disable user, the user is rejected until enabled again
+// curl -i -w "\n" -X POST --user alex:secret localhost:8027/v1/users/bob/disable
+func (server *SERVER) disableUser(c echo.Context) error {
+	if err := server.EnableUser(c.Param("username"), false); err != nil {
+		return makeJSONError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, util.BasicDTO{})
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+// Server with an admin, a read-only user of user:* keys and a pub/sub only user with hashed password
+func aclServer(t *testing.T) SERVER {
+	hash, err := HashPassword("pw3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.Users = append(config.Users,
+		User{Username: "reader", Password: "pw2", ACL: ACL{ReadOnly: true, Groups: []string{GroupKeys}, Keys: []string{"user:*"}}},
+		User{Username: "publisher", PasswordHash: hash, ACL: ACL{Groups: []string{GroupPubSub}}},
+	)
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return CreateWithConfig(config)
+}
+
+// Error code of the request, 0 on success
+func requestAs(server *SERVER, username, password, method, path, body string) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(username, password)
+	rec := httptest.NewRecorder()
+	server.handler().ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusUnauthorized {
+		return http.StatusUnauthorized
+	}
+	if rec.Code == http.StatusOK {
+		return 0
+	}
+
+	var dto util.BasicDTO
+	if err := json.Unmarshal(rec.Body.Bytes(), &dto); err != nil {
+		return -1
+	}
+	return dto.ErrorCode
+}
+
+func TestACL(t *testing.T) {
+	t.Log("Testing users are limited to their groups, keys and writes...")
+
+	server := aclServer(t)
+	server.cache.SetString("user:1", "v")
+	server.cache.SetString("order:1", "v")
+
+	denied := util.ErrorPermissionDenied.Code
+	cases := []struct {
+		username, password, method, path, body string
+		expected                               int
+	}{
+		{"alex", "secret", "GET", "/v1/keys", "", 0},
+		{"alex", "secret", "PUT", "/v1/string/order:1", `{"value":"w"}`, 0},
+		{"reader", "pw2", "GET", "/v1/get/user:1", "", 0},
+		{"reader", "pw2", "GET", "/v1/get/order:1", "", denied},
+		{"reader", "pw2", "PUT", "/v1/string/user:1", `{"value":"w"}`, denied},
+		{"reader", "pw2", "POST", "/v1/sets/inter", `{"value":["user:1","order:1"]}`, denied},
+		{"reader", "pw2", "GET", "/v1/keys", "", denied},
+		{"reader", "pw2", "POST", "/v1/publish/news", `{"value":"m"}`, denied},
+		{"reader", "wrong", "GET", "/v1/get/user:1", "", http.StatusUnauthorized},
+		{"publisher", "pw3", "POST", "/v1/publish/news", `{"value":"m"}`, 0},
+		{"publisher", "pw3", "GET", "/v1/get/user:1", "", denied},
+		{"publisher", "pw3", "GET", "/v1/users", "", denied},
+	}
+
+	for _, c := range cases {
+		if code := requestAs(&server, c.username, c.password, c.method, c.path, c.body); code != c.expected {
+			t.Errorf("Expected %d for %s %s by %s, but it was %d instead.", c.expected, c.method, c.path, c.username, code)
+		}
+	}
+
+	conn, stop := serveRESP(t, &server)
+	defer stop()
+	conn.do(t, "AUTH", "reader", "pw2")
+	for _, command := range [][]string{
+		{"GET", "user:1", "v"},
+		{"GET", "order:1", "-NOPERM"},
+		{"SET", "user:1", "w", "-NOPERM"},
+		{"DEL", "user:1", "order:1", "-NOPERM"},
+		{"DBSIZE", "-NOPERM"},
+		{"PING", "+PONG"},
+	} {
+		args, expected := command[:len(command)-1], command[len(command)-1]
+		if reply := conn.do(t, args...); !strings.HasPrefix(reply, expected) {
+			t.Errorf("Expected %s for %v, but it was %s instead.", expected, args, reply)
+		}
+	}
+}
+
+func TestDisableUser(t *testing.T) {
+	t.Log("Testing disabled users are rejected until enabled...")
+
+	server := aclServer(t)
+	conn, stop := serveRESP(t, &server)
+	defer stop()
+	conn.do(t, "AUTH", "reader", "pw2")
+
+	if code := requestAs(&server, "alex", "secret", "POST", "/v1/users/reader/disable", ""); code != 0 {
+		t.Fatalf("Expected disable to succeed, but it was %d instead.", code)
+	}
+	if code := requestAs(&server, "reader", "pw2", "GET", "/v1/get/user:1", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected %d, but it was %d instead.", http.StatusUnauthorized, code)
+	}
+	if reply := conn.do(t, "GET", "user:1"); !strings.HasPrefix(reply, "-NOPERM") {
+		t.Errorf("Expected -NOPERM on open connection, but it was %s instead.", reply)
+	}
+
+	if code := requestAs(&server, "alex", "secret", "POST", "/v1/users/nobody/disable", ""); code != util.ErrorUserNotFound.Code {
+		t.Errorf("Expected %d, but it was %d instead.", util.ErrorUserNotFound.Code, code)
+	}
+
+	server.EnableUser("reader", true)
+	if code := requestAs(&server, "reader", "pw2", "GET", "/v1/get/user:1", ""); code != util.ErrorKeyNotFound.Code {
+		t.Errorf("Expected %d, but it was %d instead.", util.ErrorKeyNotFound.Code, code)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/anevsky/cachego/memory"
+	"github.com/anevsky/cachego/util"
+	"github.com/labstack/echo"
+)
+
+// Conditional request headers, echo has no constants for them
+const (
+	headerIfMatch = "If-Match"
+	headerETag    = "ETag"
+)
+
+// Run write of the key atomically with its version check
+// The write is applied only if the key version matches If-Match header when it is set,
+// the new version is returned in ETag header
+func (server *SERVER) versioned(c echo.Context, key string, write func(tx *memory.Tx)) (interface{}, error) {
+	tx := server.cache.Multi()
+	if match := c.Request().Header.Get(headerIfMatch); match != "" && match != "*" {
+		version, err := parseETag(match)
+		if err != nil {
+			return nil, err
+		}
+		tx.IfVersion(key, version)
+	}
+	write(tx)
+	tx.Version(key)
+
+	results, err := tx.Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	setETag(c, results[len(results)-1].(uint64))
+
+	return results[len(results)-2], nil
+}
+
+func setETag(c echo.Context, version uint64) {
+	c.Response().Header().Set(headerETag, `"`+strconv.FormatUint(version, 10)+`"`)
+}
+
+// Version from ETag, quotes are optional
+func parseETag(tag string) (uint64, error) {
+	version, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(tag, "W/"), `"`), 10, 64)
+	if err != nil {
+		return 0, util.ErrorBadRequest
+	}
+
+	return version, nil
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anevsky/cachego/util"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	t.Log("Testing versions with ETag and If-Match...")
+
+	server := Create()
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	cli := clusterClient(ts.URL)
+	cli.SetDict("dict", util.Dict{"k1": "v1"})
+
+	version, errs := cli.GetVersion("dict")
+	if errs != nil || version == 0 {
+		t.Fatalf("Expected version, but it was %d (%v) instead.", version, errs)
+	}
+
+	newVersion, errs := cli.CompareAndSwapDict("dict", util.Dict{"k1": "v2"}, version)
+	if errs != nil || newVersion <= version {
+		t.Fatalf("Expected version above %d, but it was %d (%v) instead.", version, newVersion, errs)
+	}
+
+	// Stale version is rejected
+	if _, errs := cli.CompareAndSwapDict("dict", util.Dict{"k1": "v3"}, version); errs == nil {
+		t.Errorf("Expected version mismatch, but it was nil instead.")
+	}
+	if v, _ := cli.GetDictElement("dict", "k1"); v != "v2" {
+		t.Errorf("Expected v2, but it was %s instead.", v)
+	}
+
+	// Writes without If-Match are applied and return the new version as well
+	if _, errs := cli.UpdateDict("dict", util.Dict{"k1": "v4"}); errs != nil {
+		t.Fatal(errs)
+	}
+	if v, _ := cli.GetVersion("dict"); v <= newVersion {
+		t.Errorf("Expected version above %d, but it was %d instead.", newVersion, v)
+	}
+}
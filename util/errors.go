@@ -2,6 +2,7 @@ package util
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -31,7 +32,38 @@ var (
 	ErrorIndexOutOfBounds  = CacheError{"Index out of Bounds", 998}
 	ErrorInvalidTTLValue   = CacheError{"Invalid ttl value", 997}
 	ErrorResponseOrBodyNil = CacheError{"Response or body nil", 996}
+	ErrorInvalidSnapshot   = CacheError{"Invalid snapshot", 995}
+	ErrorSnapshotVersion   = CacheError{"Unsupported snapshot version", 994}
+	ErrorInvalidAppendLog  = CacheError{"Invalid append-only log", 993}
+	ErrorAppendLogClosed   = CacheError{"Append-only log is not open", 992}
+	ErrorRewriteInProgress = CacheError{"Append-only log rewrite in progress", 991}
+	ErrorFeedClosed        = CacheError{"Replication feed closed", 990}
+	ErrorReplicaTooSlow    = CacheError{"Replica is too slow, feed dropped", 989}
+	ErrorCrossSlot         = CacheError{"Keys belong to different slots", 988}
+	ErrorSlotNotOwned      = CacheError{"Slot is not owned by this node", 987}
+	ErrorClusterDisabled   = CacheError{"Cluster mode is disabled", 986}
+	ErrorInvalidDump       = CacheError{"Invalid dump", 985}
+	ErrorUnknownBatchOp    = CacheError{"Unknown batch operation", 984}
+	ErrorSubscriberTooSlow = CacheError{"Subscriber is too slow, subscription dropped", 983}
+	ErrorWatcherTooSlow    = CacheError{"Watcher is too slow, watch dropped", 982}
+	ErrorUnknownType       = CacheError{"Unknown value type", 981}
+	ErrorUserNotFound      = CacheError{"User not found", 980}
+	ErrorTokenNotFound     = CacheError{"Token not found", 979}
+	ErrorInvalidScore      = CacheError{"Score is not a valid float", 978}
+	ErrorMoved             = CacheError{"Key is served by another node", 307}
 	ErrorBadRequest        = CacheError{"Bad request", 400}
+	ErrorUnauthorized      = CacheError{"Unauthorized", 401}
+	ErrorPermissionDenied  = CacheError{"Permission denied", 403}
+	ErrorVersionMismatch   = CacheError{"Version mismatch", 412}
+	ErrorReadOnlyReplica   = CacheError{"Read-only replica", 405}
 	ErrorKeyNotFound       = CacheError{"Key not found", 404}
 	ErrorDictKeyNotFound   = CacheError{"Key not found in dictionary", 404}
+	ErrorMemberNotFound    = CacheError{"Member not found in sorted set", 404}
+	ErrorInternal          = CacheError{"Internal error", 500}
 )
+
+// Error of a response, the message carries the code as formatted by Error
+// so the result equals the error the server returned, e.g. ErrorKeyNotFound
+func DecodeError(code int, message string) CacheError {
+	return CacheError{strings.TrimSuffix(message, fmt.Sprintf(", code: %d", code)), code}
+}
@@ -0,0 +1,38 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	t.Log("Testing glob patterns...")
+
+	cases := []struct {
+		pattern, s string
+		expected   bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"user:*", "user:42", true},
+		{"user:*", "users:42", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"*:*:end", "a:b:c:end", true},
+		{"a*b", "acbd", false},
+	}
+
+	for _, c := range cases {
+		if MatchGlob(c.pattern, c.s) != c.expected {
+			t.Errorf("Expected %v for '%s' against '%s', but it was %v instead.", c.expected, c.s, c.pattern, !c.expected)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package util
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Set Unordered set of unique strings
+// Encoded to JSON as a sorted array
+type Set map[string]struct{}
+
+func NewSet(members ...string) Set {
+	set := make(Set, len(members))
+	for _, member := range members {
+		set[member] = struct{}{}
+	}
+
+	return set
+}
+
+func (set Set) Has(member string) bool {
+	_, ok := set[member]
+	return ok
+}
+
+// Sorted members
+func (set Set) Members() []string {
+	result := make([]string, 0, len(set))
+	for member := range set {
+		result = append(result, member)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func (set Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(set.Members())
+}
+
+func (set *Set) UnmarshalJSON(data []byte) error {
+	var members []string
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*set = NewSet(members...)
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package util
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSetJSON(t *testing.T) {
+	t.Log("Testing Set JSON encoding...")
+
+	set := NewSet("b", "a", "b")
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != `["a","b"]` {
+		t.Errorf("Expected %s, but it was %s instead.", `["a","b"]`, data)
+	}
+
+	var decoded Set
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(decoded, set) {
+		t.Errorf("Expected %v, but it was %v instead.", set, decoded)
+	}
+
+	if !decoded.Has("a") || decoded.Has("c") {
+		t.Errorf("Expected members a and b, but it was %v instead.", decoded.Members())
+	}
+}
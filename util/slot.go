@@ -0,0 +1,37 @@
+package util
+
+import "strings"
+
+// Number of hash slots the keyspace of a cluster is split into
+const SlotCount = 16384
+
+// KeySlot Redis-compatible hash slot of key
+// Only the part inside the first non-empty {...} is hashed when present,
+// so keys like {user1}.name and {user1}.email share a slot
+// @see https://redis.io/topics/cluster-spec
+func KeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16(key)) % SlotCount
+}
+
+// CRC16-CCITT (XMODEM)
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
@@ -0,0 +1,40 @@
+package util
+
+import "testing"
+
+func TestKeySlot(t *testing.T) {
+	t.Log("Testing key hash slots...")
+
+	if v := crc16("123456789"); v != 0x31C3 {
+		t.Errorf("Expected crc16 0x31C3, but it was %#x instead.", v)
+	}
+
+	slots := map[string]int{
+		"":          0,
+		"foo":       12182,
+		"bar":       5061,
+		"123456789": 12739,
+	}
+	for key, slot := range slots {
+		if v := KeySlot(key); v != slot {
+			t.Errorf("Expected slot %d for '%s', but it was %d instead.", slot, key, v)
+		}
+	}
+
+	same := [][2]string{
+		{"{user1000}.following", "{user1000}.followers"},
+		{"foo{bar}", "bar"},
+		{"foo{{bar}}zap", "{bar"},
+		{"foo{bar}{zap}", "bar"},
+	}
+	for _, keys := range same {
+		if KeySlot(keys[0]) != KeySlot(keys[1]) {
+			t.Errorf("Expected '%s' and '%s' in the same slot, but they were in %d and %d instead.",
+				keys[0], keys[1], KeySlot(keys[0]), KeySlot(keys[1]))
+		}
+	}
+
+	if KeySlot("foo{}{bar}") == KeySlot("bar") {
+		t.Errorf("Expected empty hash tag to hash the whole key.")
+	}
+}
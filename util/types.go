@@ -1,7 +1,19 @@
 package util
 
+import "encoding/json"
+
 type List []string
 type Dict map[string]string
+
+// ZMember Sorted set member with its score
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ZSet Sorted set members ordered by score, then by member
+type ZSet []ZMember
+
 type Stats struct {
 	MemoryAlloc       uint64 `json:"memory_alloc"`
 	MemoryTotalAlloc  uint64 `json:"memory_total_alloc"`
@@ -12,6 +24,20 @@ type Stats struct {
 	MemoryFrees       uint64 `json:"memory_frees"`
 	GCPauseTotalNs    uint64 `json:"gc_pause_total_ns"`
 	NumGC             uint32 `json:"num_gc"`
+	EvictionPolicy    string `json:"eviction_policy"`
+	// Cache counters since allocation
+	Gets        uint64 `json:"gets"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Sets        uint64 `json:"sets"`
+	Deletes     uint64 `json:"deletes"`
+	Expirations uint64 `json:"expirations"`
+	Evictions   uint64 `json:"evictions"`
+	WrongType   uint64 `json:"wrong_type"`
+	// Current keys and their approximate size
+	Keys       int            `json:"keys"`
+	KeysByType map[string]int `json:"keys_by_type"`
+	UsedBytes  int64          `json:"used_bytes"`
 }
 
 type BasicDTO struct {
@@ -37,24 +63,183 @@ type StatsDTO struct {
 type StringDTO struct {
 	BasicDTO
 	Value string `json:"value"`
+	TTL   int    `json:"ttl,omitempty"`
 }
 
 type IntDTO struct {
 	BasicDTO
 	Value int `json:"value"`
+	TTL   int `json:"ttl,omitempty"`
 }
 
 type ListDTO struct {
 	BasicDTO
 	Value List `json:"value"`
+	TTL   int  `json:"ttl,omitempty"`
 }
 
 type DictDTO struct {
 	BasicDTO
 	Value Dict `json:"value"`
+	TTL   int  `json:"ttl,omitempty"`
+}
+
+type SetDTO struct {
+	BasicDTO
+	Value Set `json:"value"`
+}
+
+type ZSetDTO struct {
+	BasicDTO
+	Value ZSet `json:"value"`
+}
+
+type ZMemberDTO struct {
+	BasicDTO
+	Value ZMember `json:"value"`
+}
+
+type FloatDTO struct {
+	BasicDTO
+	Value float64 `json:"value"`
+}
+
+// Range of ranks, negative ranks count from the end
+type RangeDTO struct {
+	BasicDTO
+	Start int `json:"start"`
+	Stop  int `json:"stop"`
+}
+
+// Range of scores, missing bound means infinity
+type ScoreRangeDTO struct {
+	BasicDTO
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
 }
 
 type BoolDTO struct {
 	BasicDTO
 	Value bool `json:"value"`
 }
+
+// SlotRange Hash slots from Start to Stop inclusive
+type SlotRange struct {
+	Start int `json:"start"`
+	Stop  int `json:"stop"`
+}
+
+// ClusterNode Cluster member with its slots, the record with the highest epoch wins
+type ClusterNode struct {
+	URL string `json:"url"`
+	// host:port of the Redis protocol listener, empty if it is disabled
+	RESPAddr string      `json:"resp_addr,omitempty"`
+	Epoch    uint64      `json:"epoch"`
+	Slots    []SlotRange `json:"slots"`
+}
+
+type ClusterDTO struct {
+	BasicDTO
+	Nodes []ClusterNode `json:"nodes"`
+}
+
+// Slot moved between nodes
+type SlotDTO struct {
+	BasicDTO
+	Slot   int    `json:"slot"`
+	Target string `json:"target,omitempty"`
+	Epoch  uint64 `json:"epoch,omitempty"`
+}
+
+// Message Pub/Sub message, Pattern is set for subscribers by pattern
+type Message struct {
+	Channel string `json:"channel"`
+	Pattern string `json:"pattern,omitempty"`
+	Payload string `json:"payload"`
+}
+
+// ScanOptions Filters of a scan, zero value returns all keys in pages of the default size
+type ScanOptions struct {
+	// Glob pattern keys must match, e.g. user:*
+	Match string `json:"match,omitempty"`
+	// Hint of the number of keys in a page
+	Count int `json:"count,omitempty"`
+	// Value type: string, int, list, dict, set or zset
+	Type string `json:"type,omitempty"`
+}
+
+// Page of a scan, cursor 0 means the scan is over
+type ScanDTO struct {
+	BasicDTO
+	Cursor uint64   `json:"cursor"`
+	Keys   []string `json:"keys"`
+}
+
+// UserDTO User with its access rules, without password
+type UserDTO struct {
+	Username string   `json:"username"`
+	Disabled bool     `json:"disabled"`
+	ReadOnly bool     `json:"read_only"`
+	Groups   []string `json:"groups,omitempty"`
+	Keys     []string `json:"keys,omitempty"`
+}
+
+type UsersDTO struct {
+	BasicDTO
+	Users []UserDTO `json:"users"`
+}
+
+// TokenDTO API token, Token holds the secret only when it is created
+// Times are unix milliseconds, TTL in milliseconds is given on creation, 0 - never expires
+type TokenDTO struct {
+	BasicDTO
+	ID        string `json:"id,omitempty"`
+	Token     string `json:"token,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Username  string `json:"username"`
+	TTL       int64  `json:"ttl,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+type TokensDTO struct {
+	BasicDTO
+	Tokens []TokenDTO `json:"tokens"`
+}
+
+// Keyspace event types
+const (
+	// Key created or its value replaced by Set
+	EventSet = "set"
+	// Value of existing key changed
+	EventUpdate = "update"
+	EventRemove = "remove"
+	EventExpire = "expire"
+	EventEvict  = "evict"
+)
+
+// Event Change of a key with the type of its value
+type Event struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	ValueType string `json:"value_type"`
+}
+
+// BatchOp Operation of a transaction
+// Value holds the argument of the matching CACHE method: a string, an int, a list,
+// a dict, set members, sorted set members, a list index or a score increment.
+// Field is the dict element key or the sorted set member.
+type BatchOp struct {
+	Op    string          `json:"op"`
+	Key   string          `json:"key"`
+	Field string          `json:"field,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	TTL   int             `json:"ttl,omitempty"`
+}
+
+// Operations run atomically and their results in the same order
+type BatchDTO struct {
+	BasicDTO
+	Ops     []BatchOp         `json:"ops,omitempty"`
+	Results []json.RawMessage `json:"results,omitempty"`
+}